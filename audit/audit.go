@@ -0,0 +1,233 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Sources of entries
+const (
+	// SourceAPI is a mutating request to the api server
+	SourceAPI = "api"
+	// SourceManager is an action the Manager took, on its own or for an operation
+	SourceManager = "manager"
+)
+
+// Outcomes of entries
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeSuppressed is an action a safeguard stopped, such as a failover the FailoverPolicy suppressed
+	OutcomeSuppressed = "suppressed"
+)
+
+// System is the actor of actions nobody asked for, such as automatic failovers
+const System = "system:kubongo"
+
+// Defaults for a Log
+const (
+	DefaultMaxSize    = 10 * 1024 * 1024
+	DefaultMaxBackups = 5
+)
+
+// Entry is a single audited action
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Actor  string    `json:"actor"`
+	// Action is the method and path for api requests and what the Manager did otherwise
+	Action  string          `json:"action"`
+	Target  string          `json:"target,omitempty"`
+	Request json.RawMessage `json:"request,omitempty"`
+	Outcome string          `json:"outcome"`
+	// Status is the http status api requests were answered with
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Log appends entries as JSON lines to Path, moving it to Path.1 and older files to Path.2 and so on once it reaches MaxSize
+type Log struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// NewLog creates a Log at path with the default size and backups
+func NewLog(path string) *Log {
+	return &Log{Path: path, MaxSize: DefaultMaxSize, MaxBackups: DefaultMaxBackups}
+}
+
+// Record appends entry, filling in its time if it has none. Errors are logged, auditing never fails the action.
+// A nil Log records nothing.
+func (l *Log) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, jErr := json.Marshal(&entry)
+	if jErr != nil {
+		log.Println("audit:94 could not encode entry:", jErr)
+		return
+	}
+	line = append(line, '\n')
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	writeErr := l.write(line)
+	if writeErr != nil {
+		log.Println("audit:102 could not write entry:", writeErr)
+	}
+}
+
+// write appends line, rotating first if it would take the file past MaxSize, the caller holds the lock
+func (l *Log) write(line []byte) error {
+	if l.file == nil {
+		openErr := l.open()
+		if openErr != nil {
+			return openErr
+		}
+	}
+	if l.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.MaxSize {
+		rotateErr := l.rotate()
+		if rotateErr != nil {
+			return rotateErr
+		}
+	}
+	n, writeErr := l.file.Write(line)
+	l.size += int64(n)
+	return writeErr
+}
+
+func (l *Log) open() error {
+	mkErr := os.MkdirAll(filepath.Dir(l.Path), 0700)
+	if mkErr != nil {
+		return mkErr
+	}
+	file, openErr := os.OpenFile(l.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if openErr != nil {
+		return openErr
+	}
+	info, statErr := file.Stat()
+	if statErr != nil {
+		file.Close()
+		return statErr
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *Log) backup(n int) string {
+	return fmt.Sprintf("%s.%d", l.Path, n)
+}
+
+// rotate shifts every backup up by one, dropping the oldest, and starts a new file, the caller holds the lock
+func (l *Log) rotate() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(l.backup(l.MaxBackups))
+	for n := l.MaxBackups - 1; n >= 1; n-- {
+		os.Rename(l.backup(n), l.backup(n+1))
+	}
+	if l.MaxBackups > 0 {
+		renameErr := os.Rename(l.Path, l.backup(1))
+		if renameErr != nil {
+			return renameErr
+		}
+	} else {
+		os.Remove(l.Path)
+	}
+	return l.open()
+}
+
+// Close closes the current file, the next Record opens it again
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	closeErr := l.file.Close()
+	l.file = nil
+	return closeErr
+}
+
+// Query selects entries, zero fields match everything
+type Query struct {
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+	Source string
+	// Limit keeps only the newest Limit entries
+	Limit int
+}
+
+func (q *Query) matches(entry *Entry) bool {
+	return (q.Since.IsZero() || !entry.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || entry.Time.Before(q.Until)) &&
+		(q.Actor == "" || entry.Actor == q.Actor) &&
+		(q.Source == "" || entry.Source == q.Source)
+}
+
+// Query returns the entries matching q oldest first, reading every backup that is still kept
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entries := []Entry{}
+	paths := []string{}
+	for n := l.MaxBackups; n >= 1; n-- {
+		paths = append(paths, l.backup(n))
+	}
+	for _, path := range append(paths, l.Path) {
+		file, openErr := os.Open(path)
+		if os.IsNotExist(openErr) {
+			continue
+		}
+		if openErr != nil {
+			return nil, openErr
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			entry := Entry{}
+			if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+				continue
+			}
+			if q.matches(&entry) {
+				entries = append(entries, entry)
+			}
+		}
+		scanErr := scanner.Err()
+		file.Close()
+		if scanErr != nil {
+			return nil, scanErr
+		}
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
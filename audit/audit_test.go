@@ -0,0 +1,127 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRotatesAndQueries(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-audit")
+	defer os.RemoveAll(dir)
+	auditLog := &Log{Path: filepath.Join(dir, "audit.log"), MaxSize: 200, MaxBackups: 2}
+	defer auditLog.Close()
+
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		actor := "alice"
+		if i%2 == 1 {
+			actor = "bob"
+		}
+		auditLog.Record(Entry{Time: start.Add(time.Duration(i) * time.Minute), Source: SourceAPI, Actor: actor, Action: "POST /instances", Outcome: OutcomeSuccess})
+	}
+	if _, statErr := os.Stat(auditLog.Path + ".2"); statErr != nil {
+		t.Errorf("expected the log to be rotated twice: %v", statErr)
+	}
+	if _, statErr := os.Stat(auditLog.Path + ".3"); !os.IsNotExist(statErr) {
+		t.Error("expected only MaxBackups rotated files to be kept")
+	}
+	all, queryErr := auditLog.Query(Query{})
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+	if len(all) == 0 || len(all) >= 10 || !all[len(all)-1].Time.Equal(start.Add(9*time.Minute)) {
+		t.Fatalf("expected the newest entries that were kept oldest first, got %d ending %+v", len(all), all[len(all)-1])
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Time.Before(all[i-1].Time) {
+			t.Errorf("expected entries oldest first, got %s after %s", all[i].Time, all[i-1].Time)
+		}
+	}
+
+	bob, _ := auditLog.Query(Query{Actor: "bob", Since: start.Add(7 * time.Minute), Until: start.Add(9 * time.Minute)})
+	if len(bob) != 1 || !bob[0].Time.Equal(start.Add(7*time.Minute)) {
+		t.Errorf("expected bob's entry at 7m only, got %+v", bob)
+	}
+	last, _ := auditLog.Query(Query{Limit: 2})
+	if len(last) != 2 || !last[1].Time.Equal(start.Add(9*time.Minute)) {
+		t.Errorf("expected the 2 newest entries, got %+v", last)
+	}
+}
+
+func TestHandlerRecordsMutatingRequests(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-audit")
+	defer os.RemoveAll(dir)
+	auditLog := NewLog(filepath.Join(dir, "audit.log"))
+	defer auditLog.Close()
+
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Method == "POST" && !strings.Contains(string(body), "db-0") {
+			t.Errorf("expected the body to still reach the handler, got %q", body)
+		}
+		if req.Method == "DELETE" {
+			res.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/instances", &Handler{Log: auditLog, Next: next, Actor: func(req *http.Request) string { return req.Header.Get("X-User") }})
+	mux.Handle("/v1/audit", &QueryHandler{Log: auditLog})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, method := range []string{"GET", "POST", "DELETE"} {
+		req, _ := http.NewRequest(method, server.URL+"/instances", strings.NewReader(`{"name":"db-0"}`))
+		req.Header.Set("X-User", "alice")
+		res, resErr := http.DefaultClient.Do(req)
+		if resErr != nil {
+			t.Fatal(resErr)
+		}
+		res.Body.Close()
+	}
+
+	res, resErr := http.Get(server.URL + "/v1/audit?actor=alice&since=" + time.Now().Add(-time.Minute).Format(time.RFC3339))
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	entries := []Entry{}
+	json.NewDecoder(res.Body).Decode(&entries)
+	res.Body.Close()
+	if len(entries) != 2 {
+		t.Fatalf("expected the POST and DELETE to be recorded but not the GET, got %+v", entries)
+	}
+	if entries[0].Action != "POST /instances" || entries[0].Status != http.StatusOK || entries[0].Outcome != OutcomeSuccess || string(entries[0].Request) != `{"name":"db-0"}` {
+		t.Errorf("expected a successful POST with its body, got %+v", entries[0])
+	}
+	if entries[1].Status != http.StatusNotFound || entries[1].Outcome != OutcomeFailure {
+		t.Errorf("expected the DELETE to be recorded as a failure, got %+v", entries[1])
+	}
+
+	res, resErr = http.Get(server.URL + "/v1/audit?since=yesterday")
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a malformed since to be rejected, got %s", res.Status)
+	}
+}
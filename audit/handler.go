@@ -0,0 +1,160 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRecordedBody is how much of a request body is kept in an entry
+const maxRecordedBody = 64 * 1024
+
+// Handler records every request that is not a GET or HEAD to Log before passing it on to Next
+type Handler struct {
+	Log  *Log
+	Next http.Handler
+	// Actor names who made a request, the remote address is used if it is nil or returns nothing
+	Actor func(req *http.Request) string
+}
+
+// statusRecorder remembers the status a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(data)
+}
+
+// requestBody reads up to maxRecordedBody of req's body for the entry and puts it all back for Next
+func requestBody(req *http.Request) json.RawMessage {
+	if req.Body == nil {
+		return nil
+	}
+	recorded, _ := ioutil.ReadAll(io.LimitReader(req.Body, maxRecordedBody))
+	req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(recorded), req.Body))
+	if len(recorded) == 0 {
+		return nil
+	}
+	if !json.Valid(recorded) {
+		// keep bodies that are not JSON, or were cut off, as a string
+		quoted, _ := json.Marshal(string(recorded))
+		return quoted
+	}
+	return recorded
+}
+
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		h.Next.ServeHTTP(res, req)
+		return
+	}
+	started := time.Now()
+	entry := Entry{
+		Time:    started,
+		Source:  SourceAPI,
+		Action:  req.Method + " " + req.URL.Path,
+		Request: requestBody(req),
+	}
+	if h.Actor != nil {
+		entry.Actor = h.Actor(req)
+	}
+	if entry.Actor == "" {
+		entry.Actor = req.RemoteAddr
+	}
+	recorder := &statusRecorder{ResponseWriter: res}
+	h.Next.ServeHTTP(recorder, req)
+	entry.Status = recorder.status
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	entry.Outcome = OutcomeSuccess
+	if entry.Status >= http.StatusBadRequest {
+		entry.Outcome = OutcomeFailure
+	}
+	entry.DurationMS = int64(time.Since(started) / time.Millisecond)
+	h.Log.Record(entry)
+}
+
+type errorRes struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// QueryHandler serves GET /v1/audit?since=&until=&actor=&source=&limit=, times are RFC 3339
+type QueryHandler struct {
+	Log *Log
+}
+
+func (q *QueryHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	encoder := json.NewEncoder(res)
+	res.Header().Set("Content-Type", "application/json")
+	fail := func(status int, err error) {
+		res.WriteHeader(status)
+		encoder.Encode(&errorRes{Error: err.Error(), Code: status})
+	}
+	if req.Method != "GET" {
+		res.Header().Set("Allow", "GET")
+		fail(http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed on %s", req.Method, req.URL.Path))
+		return
+	}
+	if q.Log == nil {
+		fail(http.StatusNotFound, fmt.Errorf("auditing is disabled"))
+		return
+	}
+	params := req.URL.Query()
+	query := Query{Actor: params.Get("actor"), Source: params.Get("source")}
+	for name, field := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := params.Get(name); value != "" {
+			parsed, parseErr := time.Parse(time.RFC3339, value)
+			if parseErr != nil {
+				fail(http.StatusBadRequest, fmt.Errorf("%s must be an RFC 3339 time: %v", name, parseErr))
+				return
+			}
+			*field = parsed
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, limitErr := strconv.Atoi(value)
+		if limitErr != nil || limit < 0 {
+			fail(http.StatusBadRequest, fmt.Errorf("limit must be a positive number, got %q", value))
+			return
+		}
+		query.Limit = limit
+	}
+	entries, queryErr := q.Log.Query(query)
+	if queryErr != nil {
+		fail(http.StatusInternalServerError, queryErr)
+		return
+	}
+	encoder.Encode(entries)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Names given to requests without credentials
+const (
+	Anonymous       = "system:anonymous"
+	Unauthenticated = "system:unauthenticated"
+)
+
+// How an identity was authenticated
+const (
+	MethodToken       = "token"
+	MethodCertificate = "certificate"
+	MethodNone        = "none"
+)
+
+// ErrInvalidToken is a bearer token that is not in the token file
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// Identity is who made a request
+type Identity struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	Method string   `json:"method"`
+}
+
+// tokenEntry is a line of a token file
+type tokenEntry struct {
+	token    []byte
+	identity Identity
+}
+
+// Authenticator works out the identity of requests from a bearer token or a verified client certificate
+type Authenticator struct {
+	tokens []tokenEntry
+}
+
+// NewAuthenticator creates an Authenticator that only knows client certificates
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{}
+}
+
+// LoadTokenFile reads bearer tokens in the Kubernetes static token file format,
+// one token,user,uid,"group1,group2" per line with the uid and groups optional
+func (a *Authenticator) LoadTokenFile(path string) error {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	tokens := []tokenEntry{}
+	for line := 1; ; line++ {
+		record, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return fmt.Errorf("%s:%d needs at least a token and a user", path, line)
+		}
+		identity := Identity{Name: record[1], Method: MethodToken}
+		if len(record) > 3 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				identity.Groups = append(identity.Groups, strings.TrimSpace(group))
+			}
+		}
+		tokens = append(tokens, tokenEntry{token: []byte(record[0]), identity: identity})
+	}
+	a.tokens = tokens
+	return nil
+}
+
+// Authenticate returns the identity of req, requests without credentials are anonymous
+func (a *Authenticator) Authenticate(req *http.Request) (Identity, error) {
+	return a.AuthenticateCredentials(req.Header.Get("Authorization"), req.TLS)
+}
+
+// AuthenticateCredentials authenticates an Authorization header value and the TLS state of a connection, either may be empty,
+// for callers that do not speak http such as the gRPC server
+func (a *Authenticator) AuthenticateCredentials(authorization string, state *tls.ConnectionState) (Identity, error) {
+	if authorization != "" {
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			return Identity{}, errors.New("only bearer tokens are accepted in the Authorization header")
+		}
+		return a.token([]byte(strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))))
+	}
+	if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		cert := state.VerifiedChains[0][0]
+		return Identity{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization, Method: MethodCertificate}, nil
+	}
+	return Identity{Name: Anonymous, Groups: []string{Unauthenticated}, Method: MethodNone}, nil
+}
+
+// token compares against every known token so the time taken does not give away how much of one matched
+func (a *Authenticator) token(presented []byte) (Identity, error) {
+	var (
+		identity Identity
+		found    bool
+	)
+	for _, entry := range a.tokens {
+		if subtle.ConstantTimeCompare(entry.token, presented) == 1 {
+			identity, found = entry.identity, true
+		}
+	}
+	if !found {
+		return Identity{}, ErrInvalidToken
+	}
+	return identity, nil
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPolicy = `
+rules:
+- groups: ["ops"]
+  verbs: ["*"]
+  resources: ["*"]
+- users: ["viewer", "client"]
+  verbs: ["read"]
+  resources: ["instances"]
+- groups: ["system:unauthenticated"]
+  verbs: ["read"]
+  resources: ["operations"]
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	writeErr := ioutil.WriteFile(path, []byte(content), 0600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	return path
+}
+
+// newTestHandler serves 200 with the caller's name behind a Handler
+func newTestHandler(t *testing.T, dir string) *Handler {
+	policy, policyErr := LoadPolicy(writeFile(t, dir, "policy.yaml", testPolicy))
+	if policyErr != nil {
+		t.Fatal(policyErr)
+	}
+	authenticator := NewAuthenticator()
+	tokenErr := authenticator.LoadTokenFile(writeFile(t, dir, "tokens.csv", "# token,user,uid,groups\nadmin-token,alice,1,\"ops,dev\"\nviewer-token,viewer,2\n"))
+	if tokenErr != nil {
+		t.Fatal(tokenErr)
+	}
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		identity, _ := IdentityOf(req)
+		res.Write([]byte(identity.Name))
+	})
+	return &Handler{Authenticator: authenticator, Policy: policy, Next: next, Public: map[string]bool{"/healthz": true}}
+}
+
+func TestHandlerAuthorizesTokens(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-auth")
+	defer os.RemoveAll(dir)
+	server := httptest.NewServer(newTestHandler(t, dir))
+	defer server.Close()
+
+	cases := []struct {
+		method, path, token string
+		status              int
+	}{
+		{"DELETE", "/v1/instances/local/db-0", "admin-token", http.StatusOK},
+		{"POST", "/failover", "admin-token", http.StatusOK},
+		{"GET", "/v1/instances/local/db-0", "viewer-token", http.StatusOK},
+		{"POST", "/instances", "viewer-token", http.StatusForbidden},
+		{"POST", "/v1/operations/op-1/cancel", "viewer-token", http.StatusForbidden},
+		{"GET", "/instances", "stolen-token", http.StatusUnauthorized},
+		{"GET", "/instances", "", http.StatusUnauthorized},
+		{"GET", "/v1/operations", "", http.StatusOK},
+		{"GET", "/healthz", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, server.URL+c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		res, resErr := http.DefaultClient.Do(req)
+		if resErr != nil {
+			t.Fatal(resErr)
+		}
+		res.Body.Close()
+		if res.StatusCode != c.status {
+			t.Errorf("%s %s with %q: expected %d, got %s", c.method, c.path, c.token, c.status, res.Status)
+		}
+	}
+}
+
+func TestIdentifierSharesTheIdentity(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-auth")
+	defer os.RemoveAll(dir)
+	handler := newTestHandler(t, dir)
+	var outer string
+	server := httptest.NewServer(&Identifier{Authenticator: handler.Authenticator, Next: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		identity, ok := IdentityOf(req)
+		outer = identity.Name
+		if !ok {
+			outer = "none"
+		}
+		handler.ServeHTTP(res, req)
+	})})
+	defer server.Close()
+
+	for token, expected := range map[string]string{"admin-token": "alice", "stolen-token": "none"} {
+		req, _ := http.NewRequest("GET", server.URL+"/instances", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, resErr := http.DefaultClient.Do(req)
+		if resErr != nil {
+			t.Fatal(resErr)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if outer != expected {
+			t.Errorf("%s: expected the handler in front of auth to see %s, got %s", token, expected, outer)
+		}
+		if expected != "none" && string(body) != expected {
+			t.Errorf("%s: expected the handler behind auth to see %s, got %s", token, expected, body)
+		}
+		if expected == "none" && res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %s", token, res.Status)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	cases := map[string]string{
+		"GET /instances":                  "read instances",
+		"PATCH /v1/instances/local/db-0":  "create instances",
+		"DELETE /instances":               "delete instances",
+		"POST /v1/operations/op-1/cancel": "delete operations",
+		"POST /failover":                  "failover failover",
+		"GET /v1":                         "read ",
+	}
+	for request, want := range cases {
+		parts := strings.SplitN(request, " ", 2)
+		req, _ := http.NewRequest(parts[0], "http://kubongo"+parts[1], nil)
+		verb, resource := Attributes(req)
+		if got := verb + " " + resource; got != want {
+			t.Errorf("%s: expected %q, got %q", request, want, got)
+		}
+	}
+}
+
+func TestLoadPolicyRejectsUnknownVerbs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-auth")
+	defer os.RemoveAll(dir)
+	_, policyErr := LoadPolicy(writeFile(t, dir, "policy.yaml", "rules:\n- users: [\"bob\"]\n  verbs: [\"destroy\"]\n  resources: [\"*\"]\n"))
+	if policyErr == nil {
+		t.Error("expected a policy with an unknown verb to be rejected")
+	}
+}
+
+// issue signs a certificate for commonName with parent, or self signs a CA if parent is nil
+func issue(t *testing.T, dir, name, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"dev"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, certErr := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if certErr != nil {
+		t.Fatal(certErr)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	writeFile(t, dir, name+".crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, dir, name+".key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-auth")
+	defer os.RemoveAll(dir)
+	ca, caKey := issue(t, dir, "ca", "kubongo-ca", nil, nil)
+	issue(t, dir, "server", "kubongo", ca, caKey)
+	issue(t, dir, "client", "client", ca, caKey)
+	serverConf, serverErr := ServerTLSConfig(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt"))
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	server := httptest.NewUnstartedServer(newTestHandler(t, dir))
+	server.TLS = serverConf
+	server.StartTLS()
+	defer server.Close()
+
+	clientConf, clientErr := ClientTLSConfig(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if clientErr != nil {
+		t.Fatal(clientErr)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConf}}
+	res, resErr := client.Get(server.URL + "/instances")
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	name, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(name) != "client" {
+		t.Errorf("expected the client certificate's CN to be allowed to read, got %s %q", res.Status, name)
+	}
+	res, resErr = client.Post(server.URL+"/instances", "application/json", nil)
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the client certificate not to be allowed to create, got %s", res.Status)
+	}
+
+	anonymousConf, _ := ClientTLSConfig(filepath.Join(dir, "ca.crt"), "", "")
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: anonymousConf}}
+	res, resErr = anonymous.Get(server.URL + "/instances")
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a client without a certificate or token to be unauthorized, got %s", res.Status)
+	}
+}
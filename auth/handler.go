@@ -0,0 +1,108 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// Handler authenticates every request, authorizes it against Policy and passes it on to Next
+type Handler struct {
+	Authenticator *Authenticator
+	Policy        *Policy
+	Next          http.Handler
+	// Public are paths served to anyone, such as health checks
+	Public map[string]bool
+}
+
+type identityKey struct{}
+
+// authResult is what authenticating a request came to, kept in its context
+type authResult struct {
+	identity Identity
+	err      error
+}
+
+// Identify authenticates req and returns it with the outcome in its context, a request that was already
+// identified is returned as it is so that it is only ever authenticated once
+func (a *Authenticator) Identify(req *http.Request) *http.Request {
+	if _, done := req.Context().Value(identityKey{}).(authResult); done {
+		return req
+	}
+	identity, authErr := a.Authenticate(req)
+	return req.WithContext(context.WithValue(req.Context(), identityKey{}, authResult{identity: identity, err: authErr}))
+}
+
+// IdentityOf returns the identity req was authenticated as by an Identifier or a Handler
+func IdentityOf(req *http.Request) (Identity, bool) {
+	result, ok := req.Context().Value(identityKey{}).(authResult)
+	return result.identity, ok && result.err == nil
+}
+
+// Identifier authenticates every request for the handlers behind it, it is for handlers that need to know who made
+// a request before a Handler authorizes it, such as auditing
+type Identifier struct {
+	Authenticator *Authenticator
+	Next          http.Handler
+}
+
+func (i *Identifier) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	i.Next.ServeHTTP(res, i.Authenticator.Identify(req))
+}
+
+type errorRes struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+func writeError(res http.ResponseWriter, status int, message string) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(&errorRes{Error: message, Code: status})
+}
+
+// ServeHTTP answers 401 to requests whose credentials are invalid or that need credentials they did not present,
+// and 403 to authenticated requests the policy does not allow
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if h.Public[req.URL.Path] {
+		h.Next.ServeHTTP(res, req)
+		return
+	}
+	req = h.Authenticator.Identify(req)
+	result := req.Context().Value(identityKey{}).(authResult)
+	identity, authErr := result.identity, result.err
+	if authErr != nil {
+		log.Println("auth:91 rejected request from", req.RemoteAddr, authErr)
+		res.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(res, http.StatusUnauthorized, authErr.Error())
+		return
+	}
+	verb, resource := Attributes(req)
+	if !h.Policy.Allows(identity, verb, resource) {
+		if identity.Method == MethodNone {
+			res.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(res, http.StatusUnauthorized, "credentials are required")
+			return
+		}
+		log.Println("auth:103 denied", identity.Name, verb, "on", resource)
+		writeError(res, http.StatusForbidden, fmt.Sprintf("%s may not %s %s", identity.Name, verb, resource))
+		return
+	}
+	h.Next.ServeHTTP(res, req)
+}
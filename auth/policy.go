@@ -0,0 +1,133 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Verbs a policy grants
+const (
+	// VerbRead is GET on any resource
+	VerbRead = "read"
+	// VerbCreate is creating, registering and changing instances
+	VerbCreate = "create"
+	// VerbDelete is deleting instances and cancelling operations
+	VerbDelete = "delete"
+	// VerbFailover is failing over manually
+	VerbFailover = "failover"
+	// All matches every verb, resource, user or group
+	All = "*"
+)
+
+var verbs = map[string]bool{VerbRead: true, VerbCreate: true, VerbDelete: true, VerbFailover: true, All: true}
+
+// Rule grants its verbs on its resources to its users and the members of its groups
+type Rule struct {
+	Users     []string `json:"users,omitempty" yaml:"users,omitempty"`
+	Groups    []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Verbs     []string `json:"verbs" yaml:"verbs"`
+	Resources []string `json:"resources" yaml:"resources"`
+}
+
+// Policy is a list of rules, a request is allowed if any rule allows it
+//
+//	rules:
+//	- users: ["alice"]
+//	  groups: ["ops"]
+//	  verbs: ["read", "create", "delete", "failover"]
+//	  resources: ["instances", "operations", "failover"]
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// LoadPolicy reads a YAML or JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	policy := &Policy{}
+	yamlErr := yaml.Unmarshal(data, policy)
+	if yamlErr != nil {
+		return nil, yamlErr
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks every rule names a subject and only known verbs
+func (p *Policy) Validate() error {
+	for i, rule := range p.Rules {
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			return fmt.Errorf("rule %d names no users or groups", i)
+		}
+		for _, verb := range rule.Verbs {
+			if !verbs[verb] {
+				return fmt.Errorf("rule %d has unknown verb %q", i, verb)
+			}
+		}
+	}
+	return nil
+}
+
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == All || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether identity may use verb on resource
+func (p *Policy) Allows(identity Identity, verb, resource string) bool {
+	for _, rule := range p.Rules {
+		if !matches(rule.Verbs, verb) || !matches(rule.Resources, resource) {
+			continue
+		}
+		if matches(rule.Users, identity.Name) {
+			return true
+		}
+		for _, group := range identity.Groups {
+			if matches(rule.Groups, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Attributes returns the verb and resource of req, the resource is the first part of the path after an optional /v1
+func Attributes(req *http.Request) (string, string) {
+	path := strings.Trim(req.URL.Path, "/")
+	if path == "v1" || strings.HasPrefix(path, "v1/") {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "v1"), "/")
+	}
+	resource := strings.SplitN(path, "/", 2)[0]
+	switch {
+	case req.Method == "GET" || req.Method == "HEAD":
+		return VerbRead, resource
+	case req.Method == "POST" && resource == "failover":
+		return VerbFailover, resource
+	case req.Method == "DELETE" || (req.Method == "POST" && strings.HasSuffix(path, "/cancel")):
+		return VerbDelete, resource
+	default:
+		return VerbCreate, resource
+	}
+}
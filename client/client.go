@@ -0,0 +1,204 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+)
+
+// Client talks to Kubongo's api server
+type Client struct {
+	// BaseURL is the scheme, host and port of the api server, such as http://127.0.0.1:8888
+	BaseURL string
+	// HTTPClient sends the requests, it presents any credentials, http.DefaultClient is used if it is nil
+	HTTPClient *http.Client
+}
+
+// New creates a Client for the api server at baseURL
+func New(baseURL string, httpClient *http.Client) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: httpClient}
+}
+
+// Error is an error answer of the api server
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("kubongo answered %d: %s", e.Status, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the api server
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.Status == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the api server
+func IsConflict(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.Status == http.StatusConflict
+}
+
+// Instance is a registered instance, Instance is as its platform describes it
+type Instance struct {
+	Name     string                `json:"name"`
+	Zone     string                `json:"zone"`
+	Role     string                `json:"role,omitempty"`
+	Instance json.RawMessage       `json:"instance"`
+	Labels   map[string]string     `json:"labels"`
+	Health   *mongo.InstanceStatus `json:"health,omitempty"`
+}
+
+// instanceList is the part of mongo.InstanceList the client decodes, the rest holds platform instances
+type instanceList struct {
+	Items []Instance `json:"items"`
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// do sends body as JSON to path and decodes the answer into out if it has status want
+func (c *Client) do(method, path string, body, out interface{}, want ...int) (int, error) {
+	res, resErr := c.send(method, path, body)
+	if resErr != nil {
+		return 0, resErr
+	}
+	defer res.Body.Close()
+	for _, status := range want {
+		if res.StatusCode == status {
+			if out == nil {
+				return status, nil
+			}
+			return status, json.NewDecoder(res.Body).Decode(out)
+		}
+	}
+	return res.StatusCode, decodeError(res)
+}
+
+func (c *Client) send(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, jErr := json.Marshal(body)
+		if jErr != nil {
+			return nil, jErr
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, reqErr := http.NewRequest(method, c.BaseURL+path, reader)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.httpClient().Do(req)
+}
+
+// decodeError turns an error answer into an *Error, the body is used as is if it is not an ErrorRes
+func decodeError(res *http.Response) error {
+	var raw bytes.Buffer
+	io.Copy(&raw, io.LimitReader(res.Body, 64*1024))
+	body := &mongo.ErrorRes{}
+	if json.Unmarshal(raw.Bytes(), body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(raw.String())
+	}
+	if body.Error == "" {
+		body.Error = res.Status
+	}
+	return &Error{Status: res.StatusCode, Message: body.Error}
+}
+
+func instancePath(zone, name string) string {
+	return "/v1/instances/" + url.PathEscape(zone) + "/" + url.PathEscape(name)
+}
+
+// ListInstances returns every registered instance
+func (c *Client) ListInstances() ([]Instance, error) {
+	list := &instanceList{}
+	_, listErr := c.do("GET", "/v1/instances", nil, list, http.StatusOK)
+	return list.Items, listErr
+}
+
+// GetInstance returns the instance called name in zone
+func (c *Client) GetInstance(zone, name string) (*Instance, error) {
+	instance := &Instance{}
+	_, getErr := c.do("GET", instancePath(zone, name), nil, instance, http.StatusOK)
+	if getErr != nil {
+		return nil, getErr
+	}
+	return instance, nil
+}
+
+// CreateInstance starts creating an instance, or a replica set if tmpl.Members is more than 1,
+// the returned operation can be followed with GetOperation or WaitOperation
+func (c *Client) CreateInstance(tmpl *mongo.InstanceTemplate) (*mongo.Operation, error) {
+	create := *tmpl
+	create.Kind = "Create"
+	return c.startOperation("POST", "/v1/instances", &create)
+}
+
+// RegisterInstance starts registering an existing instance with labels
+func (c *Client) RegisterInstance(zone, name string, labels map[string]string) (*mongo.Operation, error) {
+	return c.startOperation("POST", "/v1/instances", &mongo.InstanceTemplate{Kind: "Register", Zone: zone, Name: name, Labels: labels})
+}
+
+// DeleteInstance starts deleting the instance called name in zone
+func (c *Client) DeleteInstance(zone, name string) (*mongo.Operation, error) {
+	return c.startOperation("DELETE", instancePath(zone, name), nil)
+}
+
+func (c *Client) startOperation(method, path string, body interface{}) (*mongo.Operation, error) {
+	op := &mongo.Operation{}
+	_, opErr := c.do(method, path, body, op, http.StatusAccepted)
+	if opErr != nil {
+		return nil, opErr
+	}
+	return op, nil
+}
+
+// GetOperation returns the operation with id
+func (c *Client) GetOperation(id string) (*mongo.Operation, error) {
+	op := &mongo.Operation{}
+	_, getErr := c.do("GET", "/v1/operations/"+url.PathEscape(id), nil, op, http.StatusOK)
+	if getErr != nil {
+		return nil, getErr
+	}
+	return op, nil
+}
+
+// WaitOperation polls the operation with id every interval until it is done
+func (c *Client) WaitOperation(id string, interval time.Duration) (*mongo.Operation, error) {
+	for {
+		op, getErr := c.GetOperation(id)
+		if getErr != nil || op.Done() {
+			return op, getErr
+		}
+		time.Sleep(interval)
+	}
+}
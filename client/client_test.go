@@ -0,0 +1,147 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+)
+
+// fakeHost creates and finds servers without a platform
+type fakeHost struct{}
+
+func (fakeHost) GetServers(namespace string) ([]hostProvider.Instance, error) {
+	return nil, nil
+}
+
+func (fakeHost) GetServer(project, zone, name string) (hostProvider.Instance, error) {
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: "127.0.0.1"}, nil
+}
+
+func (fakeHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (hostProvider.Instance, error) {
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: "127.0.0.1"}, nil
+}
+
+func (fakeHost) DeleteServer(namespace, zone, name string) error {
+	return nil
+}
+
+func newTestClient() (*Client, *httptest.Server) {
+	var host hostProvider.HostProvider = fakeHost{}
+	manager := mongo.NewManager("test", "test", &host, metadata.NewRegistry())
+	handler := &mongo.MongoHandler{Platform: "test", ProjectID: "test", Manager: manager}
+	operations := &mongo.OperationsHandler{Manager: manager}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/instances", handler)
+	mux.Handle("/v1/instances/", handler)
+	mux.Handle("/v1/operations/", operations)
+	server := httptest.NewServer(mux)
+	return New(server.URL, nil), server
+}
+
+func wait(t *testing.T, c *Client, op *mongo.Operation) {
+	done, waitErr := c.WaitOperation(op.ID, 10*time.Millisecond)
+	if waitErr != nil {
+		t.Fatal(waitErr)
+	}
+	if done.Status != mongo.OperationDone {
+		t.Fatalf("expected %s %s to be done, got %+v", op.Kind, op.Target, done)
+	}
+}
+
+func TestInstances(t *testing.T) {
+	c, server := newTestClient()
+	defer server.Close()
+
+	op, createErr := c.CreateInstance(&mongo.InstanceTemplate{Name: "db-0", Zone: "us-east1-b", Labels: map[string]string{"tier": "db"}})
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	wait(t, c, op)
+	op, registerErr := c.RegisterInstance("us-east1-c", "db-1", map[string]string{"tier": "cache"})
+	if registerErr != nil {
+		t.Fatal(registerErr)
+	}
+	wait(t, c, op)
+
+	instances, listErr := c.ListInstances()
+	if listErr != nil {
+		t.Fatal(listErr)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected db-0 and db-1, got %+v", instances)
+	}
+	instance, getErr := c.GetInstance("us-east1-c", "db-1")
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if instance.Name != "db-1" || instance.Labels["tier"] != "cache" || len(instance.Instance) == 0 {
+		t.Errorf("expected db-1 labeled tier=cache, got %+v", instance)
+	}
+
+	if _, getErr = c.GetInstance("us-east1-b", "db-1"); !IsNotFound(getErr) {
+		t.Errorf("expected db-1 not to be found in another zone, got %v", getErr)
+	}
+	if _, createErr = c.CreateInstance(&mongo.InstanceTemplate{Name: "db-0", Zone: "us-east1-b"}); !IsConflict(createErr) {
+		t.Errorf("expected creating db-0 again to conflict, got %v", createErr)
+	}
+
+	op, deleteErr := c.DeleteInstance("us-east1-b", "db-0")
+	if deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+	wait(t, c, op)
+	if _, getErr = c.GetInstance("us-east1-b", "db-0"); !IsNotFound(getErr) {
+		t.Errorf("expected db-0 to be deleted, got %v", getErr)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, server := newTestClient()
+	defer server.Close()
+
+	watcher, watchErr := c.Watch(0)
+	if watchErr != nil {
+		t.Fatal(watchErr)
+	}
+	op, registerErr := c.RegisterInstance("us-east1-b", "db-0", nil)
+	if registerErr != nil {
+		t.Fatal(registerErr)
+	}
+	select {
+	case event := <-watcher.ResultChan():
+		if event.Type != "ADDED" || event.Name != "db-0" || event.ResourceVersion == 0 {
+			t.Errorf("expected db-0 to be added, got %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for db-0 to be added")
+	}
+	wait(t, c, op)
+
+	watcher.Stop()
+	for range watcher.ResultChan() {
+	}
+	if watcher.Err() != nil {
+		t.Errorf("expected a stopped watch to end without an error, got %v", watcher.Err())
+	}
+	if _, watchErr = c.Watch(1 << 40); watchErr == nil {
+		t.Error("expected watching from a version the registry never had to fail")
+	}
+}
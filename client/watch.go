@@ -0,0 +1,125 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+)
+
+// Event is a single event of a watch, which fields are set depends on Type like a mongo.WatchEvent
+type Event struct {
+	Type            string                `json:"type"`
+	ResourceVersion uint64                `json:"resourceVersion,omitempty"`
+	Name            string                `json:"name,omitempty"`
+	Instance        json.RawMessage       `json:"instance,omitempty"`
+	Labels          map[string]string     `json:"labels,omitempty"`
+	Health          *mongo.InstanceStatus `json:"health,omitempty"`
+	Failover        *mongo.FailoverRecord `json:"failover,omitempty"`
+	Message         string                `json:"message,omitempty"`
+}
+
+// Watcher receives the events of a watch until it is stopped or the server ends it
+type Watcher struct {
+	events chan Event
+	body   io.ReadCloser
+	done   chan struct{}
+	once   sync.Once
+	mutex  sync.Mutex
+	err    error
+}
+
+// Watch streams every registry change, health transition and failover, registry changes after resourceVersion are
+// replayed first, 0 starts with an ADDED event for every registered instance
+func (c *Client) Watch(resourceVersion uint64) (*Watcher, error) {
+	path := "/v1/instances?watch=true"
+	if resourceVersion > 0 {
+		path += "&resourceVersion=" + strconv.FormatUint(resourceVersion, 10)
+	}
+	res, resErr := c.send("GET", path, nil)
+	if resErr != nil {
+		return nil, resErr
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+	w := &Watcher{events: make(chan Event), body: res.Body, done: make(chan struct{})}
+	go w.receive()
+	return w, nil
+}
+
+// ResultChan receives the events, it is closed when the watch ends
+func (w *Watcher) ResultChan() <-chan Event {
+	return w.events
+}
+
+// Err is why the server ended the watch, nil if it was stopped
+func (w *Watcher) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// Stop ends the watch
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+		w.body.Close()
+	})
+}
+
+func (w *Watcher) fail(err error) {
+	select {
+	case <-w.done:
+		// errors reading a body that was closed by Stop are expected
+	default:
+		w.mutex.Lock()
+		w.err = err
+		w.mutex.Unlock()
+	}
+}
+
+func (w *Watcher) receive() {
+	defer close(w.events)
+	defer w.body.Close()
+	decoder := json.NewDecoder(w.body)
+	for {
+		event := Event{}
+		decodeErr := decoder.Decode(&event)
+		if decodeErr == io.EOF {
+			w.fail(errors.New("the server ended the watch"))
+			return
+		}
+		if decodeErr != nil {
+			w.fail(decodeErr)
+			return
+		}
+		if event.Type == mongo.EventError {
+			w.fail(errors.New(event.Message))
+			return
+		}
+		select {
+		case w.events <- event:
+		case <-w.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcAPI
+
+import (
+	"crypto/tls"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/auth"
+	"github.com/cpg1111/kubongo/metrics"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcMetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// attributes are the verb and resource auth.Attributes gives the http request each method matches
+var attributes = map[string][2]string{
+	"/kubongo.v1.Kubongo/ListInstances":    {auth.VerbRead, "instances"},
+	"/kubongo.v1.Kubongo/GetInstance":      {auth.VerbRead, "instances"},
+	"/kubongo.v1.Kubongo/WatchHealth":      {auth.VerbRead, "instances"},
+	"/kubongo.v1.Kubongo/CreateInstance":   {auth.VerbCreate, "instances"},
+	"/kubongo.v1.Kubongo/RegisterInstance": {auth.VerbCreate, "instances"},
+	"/kubongo.v1.Kubongo/DeleteInstance":   {auth.VerbDelete, "instances"},
+	"/kubongo.v1.Kubongo/GetOperation":     {auth.VerbRead, "operations"},
+	"/kubongo.v1.Kubongo/Failover":         {auth.VerbFailover, "failover"},
+}
+
+// reflectionPrefix is the reflection service, which is public like /openapi.json
+const reflectionPrefix = "/grpc.reflection."
+
+type identityKey struct{}
+
+// actorOf names the caller of ctx, the authenticated identity if there is one and the peer's address otherwise
+func actorOf(ctx context.Context) string {
+	if identity, ok := ctx.Value(identityKey{}).(auth.Identity); ok && identity.Name != auth.Anonymous {
+		return identity.Name
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// authorize authenticates the caller of ctx and checks the Policy allows method, it returns ctx with the caller's identity
+func (s *Server) authorize(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, reflectionPrefix) {
+		return ctx, nil
+	}
+	attrs, known := attributes[method]
+	if !known {
+		return ctx, status.Errorf(codes.PermissionDenied, "%s is not a kubongo method", method)
+	}
+	if s.Policy == nil {
+		return ctx, nil
+	}
+	authorization := ""
+	if md, ok := grpcMetadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		authorization = md.Get("authorization")[0]
+	}
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, isTLS := p.AuthInfo.(credentials.TLSInfo); isTLS {
+			state = &info.State
+		}
+	}
+	identity, authErr := s.Authenticator.AuthenticateCredentials(authorization, state)
+	if authErr != nil {
+		log.Println("interceptor:88 rejected call from", actorOf(ctx), authErr)
+		return ctx, status.Error(codes.Unauthenticated, authErr.Error())
+	}
+	ctx = context.WithValue(ctx, identityKey{}, identity)
+	if !s.Policy.Allows(identity, attrs[0], attrs[1]) {
+		if identity.Method == auth.MethodNone {
+			return ctx, status.Error(codes.Unauthenticated, "credentials are required")
+		}
+		log.Println("interceptor:96 denied", identity.Name, attrs[0], "on", attrs[1])
+		return ctx, status.Errorf(codes.PermissionDenied, "%s may not %s %s", identity.Name, attrs[0], attrs[1])
+	}
+	return ctx, nil
+}
+
+// unary authorizes every call and audits the ones that are not reads, denied ones included
+func (s *Server) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	started := time.Now()
+	var res interface{}
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err == nil {
+		res, err = handler(ctx, req)
+	}
+	if attrs, known := attributes[info.FullMethod]; known && attrs[0] != auth.VerbRead {
+		s.record(ctx, info.FullMethod, req, started, err)
+	}
+	observe(info.FullMethod, started, err)
+	return res, err
+}
+
+// observe counts and times a call to method like metrics.Handler does for http, grpc calls are always POSTs
+func observe(method string, started time.Time, err error) {
+	metrics.APIRequests.Inc("grpc", method, "POST", status.Code(err).String())
+	metrics.APIDuration.Observe(time.Since(started).Seconds(), "grpc", method, "POST")
+}
+
+// record audits a call to method
+func (s *Server) record(ctx context.Context, method string, req interface{}, started time.Time, err error) {
+	if s.Audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Time:       started,
+		Source:     audit.SourceAPI,
+		Actor:      actorOf(ctx),
+		Action:     method,
+		Outcome:    audit.OutcomeSuccess,
+		DurationMS: int64(time.Since(started) / time.Millisecond),
+	}
+	if message, ok := req.(proto.Message); ok {
+		entry.Request, _ = protojson.Marshal(message)
+	}
+	if err != nil {
+		entry.Outcome, entry.Error = audit.OutcomeFailure, err.Error()
+	}
+	s.Audit.Record(entry)
+}
+
+// authorizedStream is a stream whose context carries the caller's identity
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authorizedStream) Context() context.Context {
+	return a.ctx
+}
+
+// stream authorizes every streaming call
+func (s *Server) stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	started := time.Now()
+	ctx, err := s.authorize(stream.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, &authorizedStream{ServerStream: stream, ctx: ctx})
+	}
+	observe(info.FullMethod, started, err)
+	return err
+}
@@ -0,0 +1,1416 @@
+// Copyright 2015 Christian Grabowski All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: kubongo.proto
+
+package grpcAPI
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListInstancesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListInstancesRequest) Reset() {
+	*x = ListInstancesRequest{}
+	mi := &file_kubongo_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListInstancesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInstancesRequest) ProtoMessage() {}
+
+func (x *ListInstancesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInstancesRequest.ProtoReflect.Descriptor instead.
+func (*ListInstancesRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{0}
+}
+
+type ListInstancesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Instances     []*Instance            `protobuf:"bytes,1,rep,name=instances,proto3" json:"instances,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListInstancesResponse) Reset() {
+	*x = ListInstancesResponse{}
+	mi := &file_kubongo_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListInstancesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInstancesResponse) ProtoMessage() {}
+
+func (x *ListInstancesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInstancesResponse.ProtoReflect.Descriptor instead.
+func (*ListInstancesResponse) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListInstancesResponse) GetInstances() []*Instance {
+	if x != nil {
+		return x.Instances
+	}
+	return nil
+}
+
+type GetInstanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Zone          string                 `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetInstanceRequest) Reset() {
+	*x = GetInstanceRequest{}
+	mi := &file_kubongo_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetInstanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetInstanceRequest) ProtoMessage() {}
+
+func (x *GetInstanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetInstanceRequest.ProtoReflect.Descriptor instead.
+func (*GetInstanceRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetInstanceRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *GetInstanceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type Instance struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Zone  string                 `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	// role is the replica set role of members, empty for standalone instances
+	Role       string            `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
+	InternalIp string            `protobuf:"bytes,4,opt,name=internal_ip,json=internalIp,proto3" json:"internal_ip,omitempty"`
+	Labels     map[string]string `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Health     *InstanceStatus   `protobuf:"bytes,6,opt,name=health,proto3" json:"health,omitempty"`
+	// platform_json is the instance as its platform describes it
+	PlatformJson  string `protobuf:"bytes,7,opt,name=platform_json,json=platformJson,proto3" json:"platform_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Instance) Reset() {
+	*x = Instance{}
+	mi := &file_kubongo_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Instance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Instance) ProtoMessage() {}
+
+func (x *Instance) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Instance.ProtoReflect.Descriptor instead.
+func (*Instance) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Instance) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Instance) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *Instance) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Instance) GetInternalIp() string {
+	if x != nil {
+		return x.InternalIp
+	}
+	return ""
+}
+
+func (x *Instance) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Instance) GetHealth() *InstanceStatus {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+func (x *Instance) GetPlatformJson() string {
+	if x != nil {
+		return x.PlatformJson
+	}
+	return ""
+}
+
+type InstanceStatus struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Name                 string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address              string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	State                string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	PreviousState        string                 `protobuf:"bytes,4,opt,name=previous_state,json=previousState,proto3" json:"previous_state,omitempty"`
+	Since                *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=since,proto3" json:"since,omitempty"`
+	ConsecutiveFailures  int32                  `protobuf:"varint,6,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	ConsecutiveSuccesses int32                  `protobuf:"varint,7,opt,name=consecutive_successes,json=consecutiveSuccesses,proto3" json:"consecutive_successes,omitempty"`
+	Healthy              bool                   `protobuf:"varint,8,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Role                 string                 `protobuf:"bytes,9,opt,name=role,proto3" json:"role,omitempty"`
+	Error                string                 `protobuf:"bytes,10,opt,name=error,proto3" json:"error,omitempty"`
+	CheckedAt            *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=checked_at,json=checkedAt,proto3" json:"checked_at,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *InstanceStatus) Reset() {
+	*x = InstanceStatus{}
+	mi := &file_kubongo_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstanceStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstanceStatus) ProtoMessage() {}
+
+func (x *InstanceStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstanceStatus.ProtoReflect.Descriptor instead.
+func (*InstanceStatus) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InstanceStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetPreviousState() string {
+	if x != nil {
+		return x.PreviousState
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *InstanceStatus) GetConsecutiveFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (x *InstanceStatus) GetConsecutiveSuccesses() int32 {
+	if x != nil {
+		return x.ConsecutiveSuccesses
+	}
+	return 0
+}
+
+func (x *InstanceStatus) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *InstanceStatus) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *InstanceStatus) GetCheckedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CheckedAt
+	}
+	return nil
+}
+
+type MemberOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// priority and votes are left to mongod's defaults unless set
+	Priority      *float64          `protobuf:"fixed64,1,opt,name=priority,proto3,oneof" json:"priority,omitempty"`
+	Votes         *int32            `protobuf:"varint,2,opt,name=votes,proto3,oneof" json:"votes,omitempty"`
+	Hidden        bool              `protobuf:"varint,3,opt,name=hidden,proto3" json:"hidden,omitempty"`
+	ArbiterOnly   bool              `protobuf:"varint,4,opt,name=arbiter_only,json=arbiterOnly,proto3" json:"arbiter_only,omitempty"`
+	Tags          map[string]string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MemberOptions) Reset() {
+	*x = MemberOptions{}
+	mi := &file_kubongo_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MemberOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MemberOptions) ProtoMessage() {}
+
+func (x *MemberOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MemberOptions.ProtoReflect.Descriptor instead.
+func (*MemberOptions) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MemberOptions) GetPriority() float64 {
+	if x != nil && x.Priority != nil {
+		return *x.Priority
+	}
+	return 0
+}
+
+func (x *MemberOptions) GetVotes() int32 {
+	if x != nil && x.Votes != nil {
+		return *x.Votes
+	}
+	return 0
+}
+
+func (x *MemberOptions) GetHidden() bool {
+	if x != nil {
+		return x.Hidden
+	}
+	return false
+}
+
+func (x *MemberOptions) GetArbiterOnly() bool {
+	if x != nil {
+		return x.ArbiterOnly
+	}
+	return false
+}
+
+func (x *MemberOptions) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type CreateInstanceRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Zone        string                 `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	MachineType string                 `protobuf:"bytes,3,opt,name=machine_type,json=machineType,proto3" json:"machine_type,omitempty"`
+	SourceImage string                 `protobuf:"bytes,4,opt,name=source_image,json=sourceImage,proto3" json:"source_image,omitempty"`
+	Source      string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	Version     string                 `protobuf:"bytes,6,opt,name=version,proto3" json:"version,omitempty"`
+	// members more than 1 creates a whole replica set of instances named <name>-<i>
+	Members       int32             `protobuf:"varint,7,opt,name=members,proto3" json:"members,omitempty"`
+	Options       *MemberOptions    `protobuf:"bytes,8,opt,name=options,proto3" json:"options,omitempty"`
+	Labels        map[string]string `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateInstanceRequest) Reset() {
+	*x = CreateInstanceRequest{}
+	mi := &file_kubongo_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateInstanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateInstanceRequest) ProtoMessage() {}
+
+func (x *CreateInstanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateInstanceRequest.ProtoReflect.Descriptor instead.
+func (*CreateInstanceRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateInstanceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetMachineType() string {
+	if x != nil {
+		return x.MachineType
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetSourceImage() string {
+	if x != nil {
+		return x.SourceImage
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *CreateInstanceRequest) GetMembers() int32 {
+	if x != nil {
+		return x.Members
+	}
+	return 0
+}
+
+func (x *CreateInstanceRequest) GetOptions() *MemberOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *CreateInstanceRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type RegisterInstanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Zone          string                 `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterInstanceRequest) Reset() {
+	*x = RegisterInstanceRequest{}
+	mi := &file_kubongo_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterInstanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterInstanceRequest) ProtoMessage() {}
+
+func (x *RegisterInstanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterInstanceRequest.ProtoReflect.Descriptor instead.
+func (*RegisterInstanceRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RegisterInstanceRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *RegisterInstanceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RegisterInstanceRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type DeleteInstanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Zone          string                 `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteInstanceRequest) Reset() {
+	*x = DeleteInstanceRequest{}
+	mi := &file_kubongo_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteInstanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteInstanceRequest) ProtoMessage() {}
+
+func (x *DeleteInstanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteInstanceRequest.ProtoReflect.Descriptor instead.
+func (*DeleteInstanceRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteInstanceRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *DeleteInstanceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type GetOperationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOperationRequest) Reset() {
+	*x = GetOperationRequest{}
+	mi := &file_kubongo_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOperationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOperationRequest) ProtoMessage() {}
+
+func (x *GetOperationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOperationRequest.ProtoReflect.Descriptor instead.
+func (*GetOperationRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetOperationRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type OperationStep struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=finished,proto3" json:"finished,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OperationStep) Reset() {
+	*x = OperationStep{}
+	mi := &file_kubongo_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OperationStep) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperationStep) ProtoMessage() {}
+
+func (x *OperationStep) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperationStep.ProtoReflect.Descriptor instead.
+func (*OperationStep) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *OperationStep) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *OperationStep) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *OperationStep) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *OperationStep) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *OperationStep) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Operation struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind     string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Target   string                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	Actor    string                 `protobuf:"bytes,4,opt,name=actor,proto3" json:"actor,omitempty"`
+	Status   string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Progress int32                  `protobuf:"varint,6,opt,name=progress,proto3" json:"progress,omitempty"`
+	Steps    []*OperationStep       `protobuf:"bytes,7,rep,name=steps,proto3" json:"steps,omitempty"`
+	Error    string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	// result_json is what the operation produced, such as the created instance
+	ResultJson    string                 `protobuf:"bytes,9,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created,proto3" json:"created,omitempty"`
+	Updated       *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=updated,proto3" json:"updated,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=finished,proto3" json:"finished,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+	mi := &file_kubongo_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Operation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
+func (*Operation) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Operation) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Operation) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Operation) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *Operation) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *Operation) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Operation) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Operation) GetSteps() []*OperationStep {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *Operation) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Operation) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+func (x *Operation) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Operation) GetUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *Operation) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+type FailoverRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailoverRequest) Reset() {
+	*x = FailoverRequest{}
+	mi := &file_kubongo_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailoverRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailoverRequest) ProtoMessage() {}
+
+func (x *FailoverRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailoverRequest.ProtoReflect.Descriptor instead.
+func (*FailoverRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *FailoverRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+type FailoverRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Outcome       string                 `protobuf:"bytes,4,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Reason        string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Manual        bool                   `protobuf:"varint,6,opt,name=manual,proto3" json:"manual,omitempty"`
+	Count         int32                  `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailoverRecord) Reset() {
+	*x = FailoverRecord{}
+	mi := &file_kubongo_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailoverRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailoverRecord) ProtoMessage() {}
+
+func (x *FailoverRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailoverRecord.ProtoReflect.Descriptor instead.
+func (*FailoverRecord) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FailoverRecord) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *FailoverRecord) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *FailoverRecord) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *FailoverRecord) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+func (x *FailoverRecord) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *FailoverRecord) GetManual() bool {
+	if x != nil {
+		return x.Manual
+	}
+	return false
+}
+
+func (x *FailoverRecord) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type WatchHealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchHealthRequest) Reset() {
+	*x = WatchHealthRequest{}
+	mi := &file_kubongo_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchHealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchHealthRequest) ProtoMessage() {}
+
+func (x *WatchHealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchHealthRequest.ProtoReflect.Descriptor instead.
+func (*WatchHealthRequest) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{14}
+}
+
+type HealthEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*HealthEvent_Health
+	//	*HealthEvent_Failover
+	Event         isHealthEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthEvent) Reset() {
+	*x = HealthEvent{}
+	mi := &file_kubongo_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthEvent) ProtoMessage() {}
+
+func (x *HealthEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_kubongo_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthEvent.ProtoReflect.Descriptor instead.
+func (*HealthEvent) Descriptor() ([]byte, []int) {
+	return file_kubongo_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *HealthEvent) GetEvent() isHealthEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *HealthEvent) GetHealth() *InstanceStatus {
+	if x != nil {
+		if x, ok := x.Event.(*HealthEvent_Health); ok {
+			return x.Health
+		}
+	}
+	return nil
+}
+
+func (x *HealthEvent) GetFailover() *FailoverRecord {
+	if x != nil {
+		if x, ok := x.Event.(*HealthEvent_Failover); ok {
+			return x.Failover
+		}
+	}
+	return nil
+}
+
+type isHealthEvent_Event interface {
+	isHealthEvent_Event()
+}
+
+type HealthEvent_Health struct {
+	Health *InstanceStatus `protobuf:"bytes,1,opt,name=health,proto3,oneof"`
+}
+
+type HealthEvent_Failover struct {
+	Failover *FailoverRecord `protobuf:"bytes,2,opt,name=failover,proto3,oneof"`
+}
+
+func (*HealthEvent_Health) isHealthEvent_Event() {}
+
+func (*HealthEvent_Failover) isHealthEvent_Event() {}
+
+var File_kubongo_proto protoreflect.FileDescriptor
+
+const file_kubongo_proto_rawDesc = "" +
+	"\n" +
+	"\rkubongo.proto\x12\n" +
+	"kubongo.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x16\n" +
+	"\x14ListInstancesRequest\"K\n" +
+	"\x15ListInstancesResponse\x122\n" +
+	"\tinstances\x18\x01 \x03(\v2\x14.kubongo.v1.InstanceR\tinstances\"<\n" +
+	"\x12GetInstanceRequest\x12\x12\n" +
+	"\x04zone\x18\x01 \x01(\tR\x04zone\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\xb5\x02\n" +
+	"\bInstance\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x12\n" +
+	"\x04role\x18\x03 \x01(\tR\x04role\x12\x1f\n" +
+	"\vinternal_ip\x18\x04 \x01(\tR\n" +
+	"internalIp\x128\n" +
+	"\x06labels\x18\x05 \x03(\v2 .kubongo.v1.Instance.LabelsEntryR\x06labels\x122\n" +
+	"\x06health\x18\x06 \x01(\v2\x1a.kubongo.v1.InstanceStatusR\x06health\x12#\n" +
+	"\rplatform_json\x18\a \x01(\tR\fplatformJson\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x94\x03\n" +
+	"\x0eInstanceStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12%\n" +
+	"\x0eprevious_state\x18\x04 \x01(\tR\rpreviousState\x120\n" +
+	"\x05since\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x121\n" +
+	"\x14consecutive_failures\x18\x06 \x01(\x05R\x13consecutiveFailures\x123\n" +
+	"\x15consecutive_successes\x18\a \x01(\x05R\x14consecutiveSuccesses\x12\x18\n" +
+	"\ahealthy\x18\b \x01(\bR\ahealthy\x12\x12\n" +
+	"\x04role\x18\t \x01(\tR\x04role\x12\x14\n" +
+	"\x05error\x18\n" +
+	" \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"checked_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcheckedAt\"\x8f\x02\n" +
+	"\rMemberOptions\x12\x1f\n" +
+	"\bpriority\x18\x01 \x01(\x01H\x00R\bpriority\x88\x01\x01\x12\x19\n" +
+	"\x05votes\x18\x02 \x01(\x05H\x01R\x05votes\x88\x01\x01\x12\x16\n" +
+	"\x06hidden\x18\x03 \x01(\bR\x06hidden\x12!\n" +
+	"\farbiter_only\x18\x04 \x01(\bR\varbiterOnly\x127\n" +
+	"\x04tags\x18\x05 \x03(\v2#.kubongo.v1.MemberOptions.TagsEntryR\x04tags\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\v\n" +
+	"\t_priorityB\b\n" +
+	"\x06_votes\"\x88\x03\n" +
+	"\x15CreateInstanceRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12!\n" +
+	"\fmachine_type\x18\x03 \x01(\tR\vmachineType\x12!\n" +
+	"\fsource_image\x18\x04 \x01(\tR\vsourceImage\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\tR\aversion\x12\x18\n" +
+	"\amembers\x18\a \x01(\x05R\amembers\x123\n" +
+	"\aoptions\x18\b \x01(\v2\x19.kubongo.v1.MemberOptionsR\aoptions\x12E\n" +
+	"\x06labels\x18\t \x03(\v2-.kubongo.v1.CreateInstanceRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xc5\x01\n" +
+	"\x17RegisterInstanceRequest\x12\x12\n" +
+	"\x04zone\x18\x01 \x01(\tR\x04zone\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12G\n" +
+	"\x06labels\x18\x03 \x03(\v2/.kubongo.v1.RegisterInstanceRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"?\n" +
+	"\x15DeleteInstanceRequest\x12\x12\n" +
+	"\x04zone\x18\x01 \x01(\tR\x04zone\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"%\n" +
+	"\x13GetOperationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xbf\x01\n" +
+	"\rOperationStep\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x124\n" +
+	"\astarted\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\x9d\x03\n" +
+	"\tOperation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12\x14\n" +
+	"\x05actor\x18\x04 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x1a\n" +
+	"\bprogress\x18\x06 \x01(\x05R\bprogress\x12/\n" +
+	"\x05steps\x18\a \x03(\v2\x19.kubongo.v1.OperationStepR\x05steps\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12\x1f\n" +
+	"\vresult_json\x18\t \x01(\tR\n" +
+	"resultJson\x124\n" +
+	"\acreated\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x124\n" +
+	"\aupdated\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\aupdated\x126\n" +
+	"\bfinished\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\"%\n" +
+	"\x0fFailoverRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\"\xc4\x01\n" +
+	"\x0eFailoverRecord\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\x12\x18\n" +
+	"\aoutcome\x18\x04 \x01(\tR\aoutcome\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12\x16\n" +
+	"\x06manual\x18\x06 \x01(\bR\x06manual\x12\x14\n" +
+	"\x05count\x18\a \x01(\x05R\x05count\"\x14\n" +
+	"\x12WatchHealthRequest\"\x86\x01\n" +
+	"\vHealthEvent\x124\n" +
+	"\x06health\x18\x01 \x01(\v2\x1a.kubongo.v1.InstanceStatusH\x00R\x06health\x128\n" +
+	"\bfailover\x18\x02 \x01(\v2\x1a.kubongo.v1.FailoverRecordH\x00R\bfailoverB\a\n" +
+	"\x05event2\xe3\x04\n" +
+	"\aKubongo\x12T\n" +
+	"\rListInstances\x12 .kubongo.v1.ListInstancesRequest\x1a!.kubongo.v1.ListInstancesResponse\x12C\n" +
+	"\vGetInstance\x12\x1e.kubongo.v1.GetInstanceRequest\x1a\x14.kubongo.v1.Instance\x12J\n" +
+	"\x0eCreateInstance\x12!.kubongo.v1.CreateInstanceRequest\x1a\x15.kubongo.v1.Operation\x12N\n" +
+	"\x10RegisterInstance\x12#.kubongo.v1.RegisterInstanceRequest\x1a\x15.kubongo.v1.Operation\x12J\n" +
+	"\x0eDeleteInstance\x12!.kubongo.v1.DeleteInstanceRequest\x1a\x15.kubongo.v1.Operation\x12F\n" +
+	"\fGetOperation\x12\x1f.kubongo.v1.GetOperationRequest\x1a\x15.kubongo.v1.Operation\x12C\n" +
+	"\bFailover\x12\x1b.kubongo.v1.FailoverRequest\x1a\x1a.kubongo.v1.FailoverRecord\x12H\n" +
+	"\vWatchHealth\x12\x1e.kubongo.v1.WatchHealthRequest\x1a\x17.kubongo.v1.HealthEvent0\x01B$Z\"github.com/cpg1111/kubongo/grpcAPIb\x06proto3"
+
+var (
+	file_kubongo_proto_rawDescOnce sync.Once
+	file_kubongo_proto_rawDescData []byte
+)
+
+func file_kubongo_proto_rawDescGZIP() []byte {
+	file_kubongo_proto_rawDescOnce.Do(func() {
+		file_kubongo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_kubongo_proto_rawDesc), len(file_kubongo_proto_rawDesc)))
+	})
+	return file_kubongo_proto_rawDescData
+}
+
+var file_kubongo_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_kubongo_proto_goTypes = []any{
+	(*ListInstancesRequest)(nil),    // 0: kubongo.v1.ListInstancesRequest
+	(*ListInstancesResponse)(nil),   // 1: kubongo.v1.ListInstancesResponse
+	(*GetInstanceRequest)(nil),      // 2: kubongo.v1.GetInstanceRequest
+	(*Instance)(nil),                // 3: kubongo.v1.Instance
+	(*InstanceStatus)(nil),          // 4: kubongo.v1.InstanceStatus
+	(*MemberOptions)(nil),           // 5: kubongo.v1.MemberOptions
+	(*CreateInstanceRequest)(nil),   // 6: kubongo.v1.CreateInstanceRequest
+	(*RegisterInstanceRequest)(nil), // 7: kubongo.v1.RegisterInstanceRequest
+	(*DeleteInstanceRequest)(nil),   // 8: kubongo.v1.DeleteInstanceRequest
+	(*GetOperationRequest)(nil),     // 9: kubongo.v1.GetOperationRequest
+	(*OperationStep)(nil),           // 10: kubongo.v1.OperationStep
+	(*Operation)(nil),               // 11: kubongo.v1.Operation
+	(*FailoverRequest)(nil),         // 12: kubongo.v1.FailoverRequest
+	(*FailoverRecord)(nil),          // 13: kubongo.v1.FailoverRecord
+	(*WatchHealthRequest)(nil),      // 14: kubongo.v1.WatchHealthRequest
+	(*HealthEvent)(nil),             // 15: kubongo.v1.HealthEvent
+	nil,                             // 16: kubongo.v1.Instance.LabelsEntry
+	nil,                             // 17: kubongo.v1.MemberOptions.TagsEntry
+	nil,                             // 18: kubongo.v1.CreateInstanceRequest.LabelsEntry
+	nil,                             // 19: kubongo.v1.RegisterInstanceRequest.LabelsEntry
+	(*timestamppb.Timestamp)(nil),   // 20: google.protobuf.Timestamp
+}
+var file_kubongo_proto_depIdxs = []int32{
+	3,  // 0: kubongo.v1.ListInstancesResponse.instances:type_name -> kubongo.v1.Instance
+	16, // 1: kubongo.v1.Instance.labels:type_name -> kubongo.v1.Instance.LabelsEntry
+	4,  // 2: kubongo.v1.Instance.health:type_name -> kubongo.v1.InstanceStatus
+	20, // 3: kubongo.v1.InstanceStatus.since:type_name -> google.protobuf.Timestamp
+	20, // 4: kubongo.v1.InstanceStatus.checked_at:type_name -> google.protobuf.Timestamp
+	17, // 5: kubongo.v1.MemberOptions.tags:type_name -> kubongo.v1.MemberOptions.TagsEntry
+	5,  // 6: kubongo.v1.CreateInstanceRequest.options:type_name -> kubongo.v1.MemberOptions
+	18, // 7: kubongo.v1.CreateInstanceRequest.labels:type_name -> kubongo.v1.CreateInstanceRequest.LabelsEntry
+	19, // 8: kubongo.v1.RegisterInstanceRequest.labels:type_name -> kubongo.v1.RegisterInstanceRequest.LabelsEntry
+	20, // 9: kubongo.v1.OperationStep.started:type_name -> google.protobuf.Timestamp
+	20, // 10: kubongo.v1.OperationStep.finished:type_name -> google.protobuf.Timestamp
+	10, // 11: kubongo.v1.Operation.steps:type_name -> kubongo.v1.OperationStep
+	20, // 12: kubongo.v1.Operation.created:type_name -> google.protobuf.Timestamp
+	20, // 13: kubongo.v1.Operation.updated:type_name -> google.protobuf.Timestamp
+	20, // 14: kubongo.v1.Operation.finished:type_name -> google.protobuf.Timestamp
+	20, // 15: kubongo.v1.FailoverRecord.time:type_name -> google.protobuf.Timestamp
+	4,  // 16: kubongo.v1.HealthEvent.health:type_name -> kubongo.v1.InstanceStatus
+	13, // 17: kubongo.v1.HealthEvent.failover:type_name -> kubongo.v1.FailoverRecord
+	0,  // 18: kubongo.v1.Kubongo.ListInstances:input_type -> kubongo.v1.ListInstancesRequest
+	2,  // 19: kubongo.v1.Kubongo.GetInstance:input_type -> kubongo.v1.GetInstanceRequest
+	6,  // 20: kubongo.v1.Kubongo.CreateInstance:input_type -> kubongo.v1.CreateInstanceRequest
+	7,  // 21: kubongo.v1.Kubongo.RegisterInstance:input_type -> kubongo.v1.RegisterInstanceRequest
+	8,  // 22: kubongo.v1.Kubongo.DeleteInstance:input_type -> kubongo.v1.DeleteInstanceRequest
+	9,  // 23: kubongo.v1.Kubongo.GetOperation:input_type -> kubongo.v1.GetOperationRequest
+	12, // 24: kubongo.v1.Kubongo.Failover:input_type -> kubongo.v1.FailoverRequest
+	14, // 25: kubongo.v1.Kubongo.WatchHealth:input_type -> kubongo.v1.WatchHealthRequest
+	1,  // 26: kubongo.v1.Kubongo.ListInstances:output_type -> kubongo.v1.ListInstancesResponse
+	3,  // 27: kubongo.v1.Kubongo.GetInstance:output_type -> kubongo.v1.Instance
+	11, // 28: kubongo.v1.Kubongo.CreateInstance:output_type -> kubongo.v1.Operation
+	11, // 29: kubongo.v1.Kubongo.RegisterInstance:output_type -> kubongo.v1.Operation
+	11, // 30: kubongo.v1.Kubongo.DeleteInstance:output_type -> kubongo.v1.Operation
+	11, // 31: kubongo.v1.Kubongo.GetOperation:output_type -> kubongo.v1.Operation
+	13, // 32: kubongo.v1.Kubongo.Failover:output_type -> kubongo.v1.FailoverRecord
+	15, // 33: kubongo.v1.Kubongo.WatchHealth:output_type -> kubongo.v1.HealthEvent
+	26, // [26:34] is the sub-list for method output_type
+	18, // [18:26] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_kubongo_proto_init() }
+func file_kubongo_proto_init() {
+	if File_kubongo_proto != nil {
+		return
+	}
+	file_kubongo_proto_msgTypes[5].OneofWrappers = []any{}
+	file_kubongo_proto_msgTypes[15].OneofWrappers = []any{
+		(*HealthEvent_Health)(nil),
+		(*HealthEvent_Failover)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_kubongo_proto_rawDesc), len(file_kubongo_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_kubongo_proto_goTypes,
+		DependencyIndexes: file_kubongo_proto_depIdxs,
+		MessageInfos:      file_kubongo_proto_msgTypes,
+	}.Build()
+	File_kubongo_proto = out.File
+	file_kubongo_proto_goTypes = nil
+	file_kubongo_proto_depIdxs = nil
+}
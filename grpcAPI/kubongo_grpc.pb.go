@@ -0,0 +1,422 @@
+// Copyright 2015 Christian Grabowski All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: kubongo.proto
+
+package grpcAPI
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Kubongo_ListInstances_FullMethodName    = "/kubongo.v1.Kubongo/ListInstances"
+	Kubongo_GetInstance_FullMethodName      = "/kubongo.v1.Kubongo/GetInstance"
+	Kubongo_CreateInstance_FullMethodName   = "/kubongo.v1.Kubongo/CreateInstance"
+	Kubongo_RegisterInstance_FullMethodName = "/kubongo.v1.Kubongo/RegisterInstance"
+	Kubongo_DeleteInstance_FullMethodName   = "/kubongo.v1.Kubongo/DeleteInstance"
+	Kubongo_GetOperation_FullMethodName     = "/kubongo.v1.Kubongo/GetOperation"
+	Kubongo_Failover_FullMethodName         = "/kubongo.v1.Kubongo/Failover"
+	Kubongo_WatchHealth_FullMethodName      = "/kubongo.v1.Kubongo/WatchHealth"
+)
+
+// KubongoClient is the client API for Kubongo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Kubongo manages mongo instances, it serves the same operations as the http api
+type KubongoClient interface {
+	// ListInstances returns every registered instance
+	ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (*ListInstancesResponse, error)
+	// GetInstance returns a single instance, NOT_FOUND if it is not registered in the zone
+	GetInstance(ctx context.Context, in *GetInstanceRequest, opts ...grpc.CallOption) (*Instance, error)
+	// CreateInstance starts creating an instance, or a replica set if members is more than 1
+	CreateInstance(ctx context.Context, in *CreateInstanceRequest, opts ...grpc.CallOption) (*Operation, error)
+	// RegisterInstance starts registering an existing instance
+	RegisterInstance(ctx context.Context, in *RegisterInstanceRequest, opts ...grpc.CallOption) (*Operation, error)
+	// DeleteInstance starts deleting an instance
+	DeleteInstance(ctx context.Context, in *DeleteInstanceRequest, opts ...grpc.CallOption) (*Operation, error)
+	// GetOperation returns an operation started by CreateInstance, RegisterInstance or DeleteInstance
+	GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error)
+	// Failover fails over from the primary at from, or the current primary if from is empty
+	Failover(ctx context.Context, in *FailoverRequest, opts ...grpc.CallOption) (*FailoverRecord, error)
+	// WatchHealth streams every health transition and failover until the client goes away
+	WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HealthEvent], error)
+}
+
+type kubongoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKubongoClient(cc grpc.ClientConnInterface) KubongoClient {
+	return &kubongoClient{cc}
+}
+
+func (c *kubongoClient) ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (*ListInstancesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListInstancesResponse)
+	err := c.cc.Invoke(ctx, Kubongo_ListInstances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) GetInstance(ctx context.Context, in *GetInstanceRequest, opts ...grpc.CallOption) (*Instance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Instance)
+	err := c.cc.Invoke(ctx, Kubongo_GetInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) CreateInstance(ctx context.Context, in *CreateInstanceRequest, opts ...grpc.CallOption) (*Operation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, Kubongo_CreateInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) RegisterInstance(ctx context.Context, in *RegisterInstanceRequest, opts ...grpc.CallOption) (*Operation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, Kubongo_RegisterInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) DeleteInstance(ctx context.Context, in *DeleteInstanceRequest, opts ...grpc.CallOption) (*Operation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, Kubongo_DeleteInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, Kubongo_GetOperation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) Failover(ctx context.Context, in *FailoverRequest, opts ...grpc.CallOption) (*FailoverRecord, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FailoverRecord)
+	err := c.cc.Invoke(ctx, Kubongo_Failover_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kubongoClient) WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HealthEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Kubongo_ServiceDesc.Streams[0], Kubongo_WatchHealth_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchHealthRequest, HealthEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Kubongo_WatchHealthClient = grpc.ServerStreamingClient[HealthEvent]
+
+// KubongoServer is the server API for Kubongo service.
+// All implementations must embed UnimplementedKubongoServer
+// for forward compatibility.
+//
+// Kubongo manages mongo instances, it serves the same operations as the http api
+type KubongoServer interface {
+	// ListInstances returns every registered instance
+	ListInstances(context.Context, *ListInstancesRequest) (*ListInstancesResponse, error)
+	// GetInstance returns a single instance, NOT_FOUND if it is not registered in the zone
+	GetInstance(context.Context, *GetInstanceRequest) (*Instance, error)
+	// CreateInstance starts creating an instance, or a replica set if members is more than 1
+	CreateInstance(context.Context, *CreateInstanceRequest) (*Operation, error)
+	// RegisterInstance starts registering an existing instance
+	RegisterInstance(context.Context, *RegisterInstanceRequest) (*Operation, error)
+	// DeleteInstance starts deleting an instance
+	DeleteInstance(context.Context, *DeleteInstanceRequest) (*Operation, error)
+	// GetOperation returns an operation started by CreateInstance, RegisterInstance or DeleteInstance
+	GetOperation(context.Context, *GetOperationRequest) (*Operation, error)
+	// Failover fails over from the primary at from, or the current primary if from is empty
+	Failover(context.Context, *FailoverRequest) (*FailoverRecord, error)
+	// WatchHealth streams every health transition and failover until the client goes away
+	WatchHealth(*WatchHealthRequest, grpc.ServerStreamingServer[HealthEvent]) error
+	mustEmbedUnimplementedKubongoServer()
+}
+
+// UnimplementedKubongoServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedKubongoServer struct{}
+
+func (UnimplementedKubongoServer) ListInstances(context.Context, *ListInstancesRequest) (*ListInstancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInstances not implemented")
+}
+func (UnimplementedKubongoServer) GetInstance(context.Context, *GetInstanceRequest) (*Instance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInstance not implemented")
+}
+func (UnimplementedKubongoServer) CreateInstance(context.Context, *CreateInstanceRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateInstance not implemented")
+}
+func (UnimplementedKubongoServer) RegisterInstance(context.Context, *RegisterInstanceRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterInstance not implemented")
+}
+func (UnimplementedKubongoServer) DeleteInstance(context.Context, *DeleteInstanceRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteInstance not implemented")
+}
+func (UnimplementedKubongoServer) GetOperation(context.Context, *GetOperationRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOperation not implemented")
+}
+func (UnimplementedKubongoServer) Failover(context.Context, *FailoverRequest) (*FailoverRecord, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Failover not implemented")
+}
+func (UnimplementedKubongoServer) WatchHealth(*WatchHealthRequest, grpc.ServerStreamingServer[HealthEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchHealth not implemented")
+}
+func (UnimplementedKubongoServer) mustEmbedUnimplementedKubongoServer() {}
+func (UnimplementedKubongoServer) testEmbeddedByValue()                 {}
+
+// UnsafeKubongoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KubongoServer will
+// result in compilation errors.
+type UnsafeKubongoServer interface {
+	mustEmbedUnimplementedKubongoServer()
+}
+
+func RegisterKubongoServer(s grpc.ServiceRegistrar, srv KubongoServer) {
+	// If the following call pancis, it indicates UnimplementedKubongoServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Kubongo_ServiceDesc, srv)
+}
+
+func _Kubongo_ListInstances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInstancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).ListInstances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_ListInstances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).ListInstances(ctx, req.(*ListInstancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_GetInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).GetInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_GetInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).GetInstance(ctx, req.(*GetInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_CreateInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).CreateInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_CreateInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).CreateInstance(ctx, req.(*CreateInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_RegisterInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).RegisterInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_RegisterInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).RegisterInstance(ctx, req.(*RegisterInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_DeleteInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).DeleteInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_DeleteInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).DeleteInstance(ctx, req.(*DeleteInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_GetOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).GetOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_GetOperation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).GetOperation(ctx, req.(*GetOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_Failover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FailoverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KubongoServer).Failover(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Kubongo_Failover_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KubongoServer).Failover(ctx, req.(*FailoverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Kubongo_WatchHealth_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchHealthRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KubongoServer).WatchHealth(m, &grpc.GenericServerStream[WatchHealthRequest, HealthEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Kubongo_WatchHealthServer = grpc.ServerStreamingServer[HealthEvent]
+
+// Kubongo_ServiceDesc is the grpc.ServiceDesc for Kubongo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Kubongo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubongo.v1.Kubongo",
+	HandlerType: (*KubongoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListInstances",
+			Handler:    _Kubongo_ListInstances_Handler,
+		},
+		{
+			MethodName: "GetInstance",
+			Handler:    _Kubongo_GetInstance_Handler,
+		},
+		{
+			MethodName: "CreateInstance",
+			Handler:    _Kubongo_CreateInstance_Handler,
+		},
+		{
+			MethodName: "RegisterInstance",
+			Handler:    _Kubongo_RegisterInstance_Handler,
+		},
+		{
+			MethodName: "DeleteInstance",
+			Handler:    _Kubongo_DeleteInstance_Handler,
+		},
+		{
+			MethodName: "GetOperation",
+			Handler:    _Kubongo_GetOperation_Handler,
+		},
+		{
+			MethodName: "Failover",
+			Handler:    _Kubongo_Failover_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchHealth",
+			Handler:       _Kubongo_WatchHealth_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kubongo.proto",
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package grpcAPI serves the Kubongo gRPC service, kubongo.proto, from the same Manager as the http api
+package grpcAPI
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative kubongo.proto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/auth"
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements KubongoServer with a Manager
+type Server struct {
+	UnimplementedKubongoServer
+	Manager *mongo.Manager
+	// Authenticator and Policy authorize calls like auth.Handler does for http, a nil Policy allows every call
+	Authenticator *auth.Authenticator
+	Policy        *auth.Policy
+	// Audit records every mutating call, nil records nothing
+	Audit *audit.Log
+}
+
+// NewServer creates a Server for manager that allows every call
+func NewServer(manager *mongo.Manager) *Server {
+	return &Server{Manager: manager}
+}
+
+// GRPCServer creates a grpc server with the Kubongo service and server reflection registered,
+// calls are authorized and audited by s
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(s.unary), grpc.StreamInterceptor(s.stream))
+	server := grpc.NewServer(opts...)
+	RegisterKubongoServer(server, s)
+	reflection.Register(server)
+	return server
+}
+
+// codeOf maps an error from the Manager to a grpc code
+func codeOf(err error) codes.Code {
+	switch {
+	case metadata.IsNotFound(err):
+		return codes.NotFound
+	case metadata.IsConflict(err):
+		return codes.AlreadyExists
+	default:
+		return codes.Internal
+	}
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timestampOf(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+func toStatus(health mongo.InstanceStatus) *InstanceStatus {
+	return &InstanceStatus{
+		Name:                 health.Name,
+		Address:              health.Address,
+		State:                health.State,
+		PreviousState:        health.PreviousState,
+		Since:                timestamp(health.Since),
+		ConsecutiveFailures:  int32(health.ConsecutiveFailures),
+		ConsecutiveSuccesses: int32(health.ConsecutiveSuccesses),
+		Healthy:              health.LastHealth.Healthy,
+		Role:                 health.LastHealth.Role,
+		Error:                health.LastHealth.Error,
+		CheckedAt:            timestamp(health.LastHealth.CheckedAt),
+	}
+}
+
+func toFailover(record mongo.FailoverRecord) *FailoverRecord {
+	return &FailoverRecord{
+		Time:    timestamp(record.Time),
+		From:    record.From,
+		To:      record.To,
+		Outcome: record.Outcome,
+		Reason:  record.Reason,
+		Manual:  record.Manual,
+		Count:   int32(record.Count),
+	}
+}
+
+func toOperation(op mongo.Operation) *Operation {
+	converted := &Operation{
+		Id:         op.ID,
+		Kind:       op.Kind,
+		Target:     op.Target,
+		Actor:      op.Actor,
+		Status:     op.Status,
+		Progress:   int32(op.Progress),
+		Error:      op.Error,
+		ResultJson: string(op.Result),
+		Created:    timestamp(op.Created),
+		Updated:    timestamp(op.Updated),
+		Finished:   timestampOf(op.Finished),
+	}
+	for _, step := range op.Steps {
+		converted.Steps = append(converted.Steps, &OperationStep{
+			Name:     step.Name,
+			Status:   step.Status,
+			Started:  timestampOf(step.Started),
+			Finished: timestampOf(step.Finished),
+			Error:    step.Error,
+		})
+	}
+	return converted
+}
+
+func (s *Server) toInstance(instance hostProvider.Instance) *Instance {
+	name := instance.GetName()
+	labels, _ := s.Manager.Registry.Labels(name)
+	platformJSON, _ := json.Marshal(instance)
+	converted := &Instance{
+		Name:         name,
+		Zone:         instance.GetZone(),
+		Role:         metadata.RoleOf(instance),
+		InternalIp:   instance.GetInternalIP(),
+		Labels:       labels,
+		PlatformJson: string(platformJSON),
+	}
+	if health, probed := s.Manager.Prober.Status(name); probed {
+		converted.Health = toStatus(health)
+	}
+	return converted
+}
+
+// ListInstances returns every registered instance
+func (s *Server) ListInstances(ctx context.Context, req *ListInstancesRequest) (*ListInstancesResponse, error) {
+	res := &ListInstancesResponse{}
+	for _, instance := range s.Manager.Registry.List() {
+		res.Instances = append(res.Instances, s.toInstance(instance))
+	}
+	return res, nil
+}
+
+// GetInstance returns a single instance, an instance of the same name in another zone is not found
+func (s *Server) GetInstance(ctx context.Context, req *GetInstanceRequest) (*Instance, error) {
+	instance, ok := s.Manager.Registry.Get(req.Name)
+	if !ok || instance.GetZone() != req.Zone {
+		return nil, status.Errorf(codes.NotFound, "%s/%s is not registered", req.Zone, req.Name)
+	}
+	return s.toInstance(instance), nil
+}
+
+// startCreate checks tmpl like MongoHandler.Post does and starts creating or registering it
+func (s *Server) startCreate(ctx context.Context, tmpl *mongo.InstanceTemplate) (*Operation, error) {
+	validErr := tmpl.Validate()
+	if validErr != nil {
+		return nil, status.Error(codes.InvalidArgument, validErr.Error())
+	}
+	if tmpl.Kind == "Create" && tmpl.Members > 1 {
+		if s.Manager.ReplicaSet == "" {
+			return nil, status.Error(codes.FailedPrecondition, "no replica set name was configured")
+		}
+	} else if _, exists := s.Manager.Registry.Get(tmpl.Name); exists {
+		return nil, status.Errorf(codes.AlreadyExists, "%s is already registered", tmpl.Name)
+	}
+	return toOperation(s.Manager.StartCreate(tmpl, actorOf(ctx))), nil
+}
+
+// CreateInstance starts creating an instance, or a replica set if members is more than 1
+func (s *Server) CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*Operation, error) {
+	tmpl := &mongo.InstanceTemplate{
+		Kind:        "Create",
+		Name:        req.Name,
+		Zone:        req.Zone,
+		MachineType: req.MachineType,
+		SourceImage: req.SourceImage,
+		Source:      req.Source,
+		Version:     req.Version,
+		Members:     int(req.Members),
+		Labels:      req.Labels,
+	}
+	if opts := req.Options; opts != nil {
+		tmpl.Options = metadata.MemberOptions{Priority: opts.Priority, Hidden: opts.Hidden, ArbiterOnly: opts.ArbiterOnly, Tags: opts.Tags}
+		if opts.Votes != nil {
+			votes := int(*opts.Votes)
+			tmpl.Options.Votes = &votes
+		}
+	}
+	return s.startCreate(ctx, tmpl)
+}
+
+// RegisterInstance starts registering an existing instance
+func (s *Server) RegisterInstance(ctx context.Context, req *RegisterInstanceRequest) (*Operation, error) {
+	return s.startCreate(ctx, &mongo.InstanceTemplate{Kind: "Register", Name: req.Name, Zone: req.Zone, Labels: req.Labels})
+}
+
+// DeleteInstance starts deleting an instance
+func (s *Server) DeleteInstance(ctx context.Context, req *DeleteInstanceRequest) (*Operation, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	instance, ok := s.Manager.Registry.Get(req.Name)
+	if !ok || instance.GetZone() != req.Zone {
+		return nil, status.Errorf(codes.NotFound, "%s/%s is not registered", req.Zone, req.Name)
+	}
+	return toOperation(s.Manager.StartRemove(req.Zone, req.Name, actorOf(ctx))), nil
+}
+
+// GetOperation returns an operation
+func (s *Server) GetOperation(ctx context.Context, req *GetOperationRequest) (*Operation, error) {
+	op, ok := s.Manager.Operations.Get(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %s not found", req.Id)
+	}
+	return toOperation(op), nil
+}
+
+// Failover fails over manually, it is not subject to the FailoverPolicy
+func (s *Server) Failover(ctx context.Context, req *FailoverRequest) (*FailoverRecord, error) {
+	record, failErr := s.Manager.Failover(req.From)
+	if failErr != nil {
+		return nil, status.Error(codeOf(failErr), failErr.Error())
+	}
+	return toFailover(record), nil
+}
+
+// WatchHealth streams every health transition and failover until the client goes away
+func (s *Server) WatchHealth(req *WatchHealthRequest, stream grpc.ServerStreamingServer[HealthEvent]) error {
+	events, unsubscribe := s.Manager.Subscribe()
+	defer unsubscribe()
+	for {
+		var event *HealthEvent
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case managerEvent, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "watcher fell too far behind the health checks")
+			}
+			switch castEvent := managerEvent.(type) {
+			case mongo.InstanceStatus:
+				event = &HealthEvent{Event: &HealthEvent_Health{Health: toStatus(castEvent)}}
+			case mongo.FailoverRecord:
+				event = &HealthEvent{Event: &HealthEvent_Failover{Failover: toFailover(castEvent)}}
+			default:
+				continue
+			}
+		}
+		sendErr := stream.Send(event)
+		if sendErr != nil {
+			return sendErr
+		}
+	}
+}
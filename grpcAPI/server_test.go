@@ -0,0 +1,201 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcAPI
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/auth"
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcMetadata "google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+// fakeHost creates and finds servers without a platform
+type fakeHost struct{}
+
+func (fakeHost) GetServers(namespace string) ([]hostProvider.Instance, error) {
+	return nil, nil
+}
+
+func (fakeHost) GetServer(project, zone, name string) (hostProvider.Instance, error) {
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: "127.0.0.1"}, nil
+}
+
+func (fakeHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (hostProvider.Instance, error) {
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: "127.0.0.1"}, nil
+}
+
+func (fakeHost) DeleteServer(namespace, zone, name string) error {
+	return nil
+}
+
+// serve starts s on a local port and returns a connection to it
+func serve(t *testing.T, s *Server) (*grpc.ClientConn, func()) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatal(listenErr)
+	}
+	server := s.GRPCServer()
+	go server.Serve(listener)
+	conn, dialErr := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if dialErr != nil {
+		t.Fatal(dialErr)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func newManager() *mongo.Manager {
+	var host hostProvider.HostProvider = fakeHost{}
+	return mongo.NewManager("test", "test", &host, metadata.NewRegistry())
+}
+
+func awaitOperation(t *testing.T, client KubongoClient, op *Operation) *Operation {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		current, getErr := client.GetOperation(context.Background(), &GetOperationRequest{Id: op.Id})
+		if getErr != nil {
+			t.Fatal(getErr)
+		}
+		if current.Status == mongo.OperationDone || current.Status == mongo.OperationFailed {
+			return current
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the operation")
+	return nil
+}
+
+func TestInstances(t *testing.T) {
+	conn, stop := serve(t, NewServer(newManager()))
+	defer stop()
+	client := NewKubongoClient(conn)
+	ctx := context.Background()
+
+	op, createErr := client.CreateInstance(ctx, &CreateInstanceRequest{Name: "db-0", Zone: "us-east1-b", Labels: map[string]string{"tier": "db"}})
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	if done := awaitOperation(t, client, op); done.Status != mongo.OperationDone || done.Kind != "create" {
+		t.Errorf("expected db-0 to be created, got %+v", done)
+	}
+	instance, getErr := client.GetInstance(ctx, &GetInstanceRequest{Zone: "us-east1-b", Name: "db-0"})
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if instance.Labels["tier"] != "db" || instance.InternalIp != "127.0.0.1" || instance.PlatformJson == "" {
+		t.Errorf("expected db-0 labeled tier=db at 127.0.0.1, got %+v", instance)
+	}
+
+	_, createErr = client.CreateInstance(ctx, &CreateInstanceRequest{Name: "db-0", Zone: "us-east1-b"})
+	if status.Code(createErr) != codes.AlreadyExists {
+		t.Errorf("expected creating db-0 again to be AlreadyExists, got %v", createErr)
+	}
+	_, createErr = client.CreateInstance(ctx, &CreateInstanceRequest{Zone: "us-east1-b"})
+	if status.Code(createErr) != codes.InvalidArgument {
+		t.Errorf("expected an instance without a name to be InvalidArgument, got %v", createErr)
+	}
+	_, getErr = client.GetInstance(ctx, &GetInstanceRequest{Zone: "us-east1-c", Name: "db-0"})
+	if status.Code(getErr) != codes.NotFound {
+		t.Errorf("expected db-0 not to be found in another zone, got %v", getErr)
+	}
+
+	op, deleteErr := client.DeleteInstance(ctx, &DeleteInstanceRequest{Zone: "us-east1-b", Name: "db-0"})
+	if deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+	awaitOperation(t, client, op)
+	list, listErr := client.ListInstances(ctx, &ListInstancesRequest{})
+	if listErr != nil || len(list.Instances) != 0 {
+		t.Errorf("expected db-0 to be deleted, got %v %v", list, listErr)
+	}
+}
+
+func TestAuthorizationAndAudit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-grpc")
+	defer os.RemoveAll(dir)
+	policyPath := filepath.Join(dir, "policy.yaml")
+	tokenPath := filepath.Join(dir, "tokens.csv")
+	ioutil.WriteFile(policyPath, []byte("rules:\n- users: [\"alice\"]\n  verbs: [\"*\"]\n  resources: [\"*\"]\n- users: [\"viewer\"]\n  verbs: [\"read\"]\n  resources: [\"instances\"]\n"), 0600)
+	ioutil.WriteFile(tokenPath, []byte("alice-token,alice,1\nviewer-token,viewer,2\n"), 0600)
+	policy, policyErr := auth.LoadPolicy(policyPath)
+	if policyErr != nil {
+		t.Fatal(policyErr)
+	}
+	authenticator := auth.NewAuthenticator()
+	authenticator.LoadTokenFile(tokenPath)
+	s := &Server{Manager: newManager(), Authenticator: authenticator, Policy: policy, Audit: audit.NewLog(filepath.Join(dir, "audit.log"))}
+	conn, stop := serve(t, s)
+	defer stop()
+	client := NewKubongoClient(conn)
+	as := func(token string) context.Context {
+		return grpcMetadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	if _, listErr := client.ListInstances(context.Background(), &ListInstancesRequest{}); status.Code(listErr) != codes.Unauthenticated {
+		t.Errorf("expected a call without credentials to be Unauthenticated, got %v", listErr)
+	}
+	if _, listErr := client.ListInstances(as("viewer-token"), &ListInstancesRequest{}); listErr != nil {
+		t.Errorf("expected viewer to list instances, got %v", listErr)
+	}
+	if _, regErr := client.RegisterInstance(as("viewer-token"), &RegisterInstanceRequest{Zone: "us-east1-b", Name: "db-0"}); status.Code(regErr) != codes.PermissionDenied {
+		t.Errorf("expected viewer not to register instances, got %v", regErr)
+	}
+	op, regErr := client.RegisterInstance(as("alice-token"), &RegisterInstanceRequest{Zone: "us-east1-b", Name: "db-0"})
+	if regErr != nil {
+		t.Fatal(regErr)
+	}
+	if op.Actor != "alice" {
+		t.Errorf("expected the operation to be started by alice, got %q", op.Actor)
+	}
+
+	entries, _ := s.Audit.Query(audit.Query{Source: audit.SourceAPI})
+	if len(entries) != 2 || entries[0].Actor != "viewer" || entries[0].Outcome != audit.OutcomeFailure || entries[1].Actor != "alice" {
+		t.Errorf("expected the denied and the allowed register to be audited, got %+v", entries)
+	}
+
+	// reflection is public so standard tooling can find the service
+	stream, reflectErr := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if reflectErr != nil {
+		t.Fatal(reflectErr)
+	}
+	stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	res, recvErr := stream.Recv()
+	if recvErr != nil {
+		t.Fatal(recvErr)
+	}
+	found := false
+	for _, service := range res.GetListServicesResponse().GetService() {
+		found = found || service.Name == "kubongo.v1.Kubongo"
+	}
+	if !found {
+		t.Errorf("expected reflection to list kubongo.v1.Kubongo, got %v", res)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health serves kubongo's liveness, readiness and build info for probes
+package health
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Paths the handlers are served on, they are public so probes need no credentials
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+	VersionPath   = "/version"
+)
+
+// Check returns an error explaining why a dependency is not ready
+type Check func() error
+
+// CheckResult is the outcome of a single Check
+type CheckResult struct {
+	Name       string `json:"name"`
+	Ready      bool   `json:"ready"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Report is the body of /readyz
+type Report struct {
+	Ready  bool          `json:"ready"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Readiness serves whether every dependency it checks is ready, checks can be added while it is served
+type Readiness struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Check
+}
+
+// NewReadiness creates a Readiness with no checks, it is ready until one is added
+func NewReadiness() *Readiness {
+	return &Readiness{checks: make(map[string]Check)}
+}
+
+// Add checks the dependency name with check, replacing any check already added for name
+func (r *Readiness) Add(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.checks[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// Check runs every check at once and reports them in the order they were added
+func (r *Readiness) Check() Report {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+	report := Report{Ready: true, Checks: make([]CheckResult, len(names))}
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started := time.Now()
+			checkErr := checks[i]()
+			result := CheckResult{Name: names[i], Ready: checkErr == nil, DurationMS: int64(time.Since(started) / time.Millisecond)}
+			if checkErr != nil {
+				result.Error = checkErr.Error()
+			}
+			report.Checks[i] = result
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		report.Ready = report.Ready && result.Ready
+	}
+	return report
+}
+
+// ServeHTTP answers 200 when every check passes and 503 with the failing checks' errors otherwise
+func (r *Readiness) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if !allowed(res, req) {
+		return
+	}
+	report := r.Check()
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+		for _, result := range report.Checks {
+			if !result.Ready {
+				log.Println("health:113 not ready,", result.Name, "failed:", result.Error)
+			}
+		}
+	}
+	writeJSON(res, status, &report)
+}
+
+// Liveness answers 200 for as long as the process can serve http
+func Liveness(res http.ResponseWriter, req *http.Request) {
+	if !allowed(res, req) {
+		return
+	}
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Write([]byte("ok\n"))
+}
+
+// allowed answers 405 to anything but GET and HEAD
+func allowed(res http.ResponseWriter, req *http.Request) bool {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		return true
+	}
+	res.Header().Set("Allow", "GET, HEAD")
+	writeJSON(res, http.StatusMethodNotAllowed, map[string]interface{}{"error": "method " + req.Method + " is not allowed on " + req.URL.Path, "code": http.StatusMethodNotAllowed})
+	return false
+}
+
+func writeJSON(res http.ResponseWriter, status int, body interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(body)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestReadiness(t *testing.T) {
+	readiness := NewReadiness()
+	if res := get(t, readiness, ReadinessPath); res.Code != http.StatusOK {
+		t.Errorf("expected no checks to be ready, got %d", res.Code)
+	}
+
+	kubeErr := errors.New("connection refused")
+	readiness.Add("kubernetes", func() error { return kubeErr })
+	readiness.Add("registry", func() error { return nil })
+	res := get(t, readiness, ReadinessPath)
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected an unreachable kubernetes to be unavailable, got %d", res.Code)
+	}
+	report := Report{}
+	json.NewDecoder(res.Body).Decode(&report)
+	if report.Ready || len(report.Checks) != 2 || report.Checks[0].Name != "kubernetes" || report.Checks[0].Error != "connection refused" || !report.Checks[1].Ready {
+		t.Errorf("expected kubernetes to be reported failing and the registry ready, got %+v", report)
+	}
+
+	readiness.Add("kubernetes", func() error { return nil })
+	if res := get(t, readiness, ReadinessPath); res.Code != http.StatusOK {
+		t.Errorf("expected a replaced check to be used, got %d %s", res.Code, res.Body)
+	}
+}
+
+func TestLivenessAndVersion(t *testing.T) {
+	if res := get(t, http.HandlerFunc(Liveness), LivenessPath); res.Code != http.StatusOK {
+		t.Errorf("expected the process to be alive, got %d", res.Code)
+	}
+	Version, Commit = "v1.2.3", "abc123"
+	defer func() { Version, Commit = "dev", "unknown" }()
+	res := get(t, http.HandlerFunc(VersionHandler), VersionPath)
+	info := BuildInfo{}
+	json.NewDecoder(res.Body).Decode(&info)
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.GoVersion == "" {
+		t.Errorf("expected the stamped version, got %+v", info)
+	}
+
+	req, _ := http.NewRequest("POST", VersionPath, nil)
+	post := httptest.NewRecorder()
+	VersionHandler(post, req)
+	if post.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be refused, got %d", post.Code)
+	}
+}
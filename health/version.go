@@ -0,0 +1,49 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Version, Commit and BuildDate are stamped at link time, for example
+// go build -ldflags "-X github.com/cpg1111/kubongo/health.Version=v0.2.0"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// BuildInfo is the body of /version
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Build returns the info stamped into this binary
+func Build() BuildInfo {
+	return BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+}
+
+// VersionHandler serves Build as JSON
+func VersionHandler(res http.ResponseWriter, req *http.Request) {
+	if !allowed(res, req) {
+		return
+	}
+	info := Build()
+	writeJSON(res, http.StatusOK, &info)
+}
@@ -0,0 +1,498 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostProvider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ec2APIVersion is the version of the EC2 query api kubongo speaks
+const ec2APIVersion = "2016-11-15"
+
+// Ec2ProjectTag is the tag EC2 instances are given with kubongo's project, GetServers lists instances by it
+const Ec2ProjectTag = "kubongo:project"
+
+// Ec2Config is the json config of the EC2 platform, credentials and region missing from it are read from
+// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY, $AWS_SESSION_TOKEN and $AWS_REGION
+type Ec2Config struct {
+	AwsCredentials
+	Region string `json:"region"`
+	// Endpoint overrides https://ec2.{region}.amazonaws.com
+	Endpoint         string   `json:"endpoint"`
+	SubnetID         string   `json:"subnetId"`
+	SecurityGroupIDs []string `json:"securityGroupIds"`
+	KeyName          string   `json:"keyName"`
+}
+
+// Ec2Host is the HostProvider struct for AWS, used to control instances on EC2
+type Ec2Host struct {
+	HostProvider
+	Project     string
+	Region      string
+	Endpoint    string
+	Credentials AwsCredentials
+	// SubnetID, SecurityGroupIDs and KeyName are given to every instance created, when set
+	SubnetID         string
+	SecurityGroupIDs []string
+	KeyName          string
+	Client           *http.Client
+	// PollInterval is how often an instance is described while waiting for it to run or terminate
+	PollInterval time.Duration
+}
+
+// Ec2Instance is an EC2 instance, named by its Name tag
+type Ec2Instance struct {
+	Instance
+	InstanceID       string            `json:"instanceId"`
+	Name             string            `json:"name"`
+	ImageID          string            `json:"imageId"`
+	InstanceType     string            `json:"instanceType"`
+	AvailabilityZone string            `json:"availabilityZone"`
+	State            string            `json:"state"`
+	PrivateIPAddress string            `json:"privateIpAddress"`
+	PublicIPAddress  string            `json:"publicIpAddress,omitempty"`
+	LaunchTime       string            `json:"launchTime"`
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+// GetInternalIP returns the private IP of its instance
+func (e Ec2Instance) GetInternalIP() string {
+	return e.PrivateIPAddress
+}
+
+// GetName returns the Name tag of its instance
+func (e Ec2Instance) GetName() string {
+	return e.Name
+}
+
+// GetZone returns the availability zone of its instance
+func (e Ec2Instance) GetZone() string {
+	return e.AvailabilityZone
+}
+
+// NewEc2 returns a new EC2 HostProvider for project, configured from the json file at confPath if there is one
+func NewEc2(project, confPath string) (*Ec2Host, error) {
+	conf := &Ec2Config{}
+	if confPath != "" {
+		confJSON, readErr := ioutil.ReadFile(confPath)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return nil, readErr
+		}
+		if readErr == nil {
+			if jErr := json.Unmarshal(confJSON, conf); jErr != nil {
+				return nil, jErr
+			}
+		}
+	}
+	if conf.AccessKeyID == "" {
+		conf.AwsCredentials = AwsCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	if conf.Region == "" {
+		conf.Region = os.Getenv("AWS_REGION")
+	}
+	if conf.AccessKeyID == "" || conf.SecretAccessKey == "" {
+		return nil, errors.New("Could Not Auth with AWS, no access key was configured")
+	}
+	if conf.Region == "" {
+		return nil, errors.New("no AWS region was configured")
+	}
+	if conf.Endpoint == "" {
+		conf.Endpoint = fmt.Sprintf("https://ec2.%s.amazonaws.com", conf.Region)
+	}
+	return &Ec2Host{
+		Project:          project,
+		Region:           conf.Region,
+		Endpoint:         conf.Endpoint,
+		Credentials:      conf.AwsCredentials,
+		SubnetID:         conf.SubnetID,
+		SecurityGroupIDs: conf.SecurityGroupIDs,
+		KeyName:          conf.KeyName,
+		Client:           &http.Client{Timeout: 30 * time.Second},
+		PollInterval:     operationPollInterval,
+	}, nil
+}
+
+// Ec2Error is an error returned by the EC2 api
+type Ec2Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *Ec2Error) Error() string {
+	return fmt.Sprintf("EC2 returned a status code of %d, %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+type ec2ErrorResponse struct {
+	Errors []struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Errors>Error"`
+	RequestID string `xml:"RequestID"`
+}
+
+type ec2Tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+// ec2InstanceItem is an instance as described in the EC2 api's XML
+type ec2InstanceItem struct {
+	InstanceID    string `xml:"instanceId"`
+	ImageID       string `xml:"imageId"`
+	InstanceState struct {
+		Name string `xml:"name"`
+	} `xml:"instanceState"`
+	InstanceType string `xml:"instanceType"`
+	Placement    struct {
+		AvailabilityZone string `xml:"availabilityZone"`
+	} `xml:"placement"`
+	PrivateIPAddress string   `xml:"privateIpAddress"`
+	IPAddress        string   `xml:"ipAddress"`
+	LaunchTime       string   `xml:"launchTime"`
+	Tags             []ec2Tag `xml:"tagSet>item"`
+}
+
+func (i ec2InstanceItem) toInstance() *Ec2Instance {
+	instance := &Ec2Instance{
+		InstanceID:       i.InstanceID,
+		ImageID:          i.ImageID,
+		InstanceType:     i.InstanceType,
+		AvailabilityZone: i.Placement.AvailabilityZone,
+		State:            i.InstanceState.Name,
+		PrivateIPAddress: i.PrivateIPAddress,
+		PublicIPAddress:  i.IPAddress,
+		LaunchTime:       i.LaunchTime,
+		Tags:             make(map[string]string),
+	}
+	for _, tag := range i.Tags {
+		instance.Tags[tag.Key] = tag.Value
+	}
+	instance.Name = instance.Tags["Name"]
+	return instance
+}
+
+type describeInstancesResponse struct {
+	Reservations []struct {
+		Instances []ec2InstanceItem `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type runInstancesResponse struct {
+	Instances []ec2InstanceItem `xml:"instancesSet>item"`
+}
+
+type terminateInstancesResponse struct {
+	Instances []struct {
+		InstanceID   string `xml:"instanceId"`
+		CurrentState struct {
+			Name string `xml:"name"`
+		} `xml:"currentState"`
+	} `xml:"instancesSet>item"`
+}
+
+// liveStates are the states of instances that are not terminated or on their way to be
+var liveStates = []string{"pending", "running", "stopping", "stopped"}
+
+// call signs and POSTs action with params to the EC2 query api, decoding its XML response into out,
+// the request is abandoned when ctx is done
+func (e Ec2Host) call(ctx context.Context, action string, params url.Values, out interface{}) error {
+	params.Set("Action", action)
+	params.Set("Version", ec2APIVersion)
+	body := []byte(params.Encode())
+	req, reqErr := http.NewRequest("POST", e.Endpoint, bytes.NewReader(body))
+	if reqErr != nil {
+		return reqErr
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, body, e.Credentials, e.Region, "ec2", time.Now())
+	res, resErr := e.Client.Do(req.WithContext(ctx))
+	if resErr != nil {
+		return resErr
+	}
+	defer res.Body.Close()
+	resBody, readErr := ioutil.ReadAll(res.Body)
+	if readErr != nil {
+		return readErr
+	}
+	if res.StatusCode >= 400 {
+		ec2Err := &Ec2Error{StatusCode: res.StatusCode}
+		errRes := &ec2ErrorResponse{}
+		if xml.Unmarshal(resBody, errRes) == nil && len(errRes.Errors) > 0 {
+			ec2Err.Code, ec2Err.Message, ec2Err.RequestID = errRes.Errors[0].Code, errRes.Errors[0].Message, errRes.RequestID
+		} else {
+			ec2Err.Message = string(resBody)
+		}
+		return ec2Err
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(resBody, out)
+}
+
+// setFilters adds the name=values filters to params as Filter.N.Name and Filter.N.Value.M
+func setFilters(params url.Values, filters map[string][]string) {
+	names := []string{}
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for n, name := range names {
+		prefix := "Filter." + strconv.Itoa(n+1)
+		params.Set(prefix+".Name", name)
+		for i, value := range filters[name] {
+			params.Set(prefix+".Value."+strconv.Itoa(i+1), value)
+		}
+	}
+}
+
+// describe returns every instance matching params, following the api's pages
+func (e Ec2Host) describe(ctx context.Context, params url.Values) ([]*Ec2Instance, error) {
+	instances := []*Ec2Instance{}
+	for {
+		result := &describeInstancesResponse{}
+		if callErr := e.call(ctx, "DescribeInstances", params, result); callErr != nil {
+			return nil, callErr
+		}
+		for _, reservation := range result.Reservations {
+			for _, item := range reservation.Instances {
+				instances = append(instances, item.toInstance())
+			}
+		}
+		if result.NextToken == "" {
+			return instances, nil
+		}
+		params.Set("NextToken", result.NextToken)
+	}
+}
+
+// GetServers returns the instances on EC2 tagged with namespace as their project, that are not terminated
+func (e Ec2Host) GetServers(namespace string) ([]Instance, error) {
+	params := url.Values{}
+	setFilters(params, map[string][]string{
+		"tag:" + Ec2ProjectTag: {namespace},
+		"instance-state-name":  liveStates,
+	})
+	found, findErr := e.describe(context.Background(), params)
+	if findErr != nil {
+		return nil, findErr
+	}
+	instances := make([]Instance, len(found))
+	for i := range found {
+		instances[i] = found[i]
+	}
+	return instances, nil
+}
+
+// GetServer returns the instance on EC2 named name in the availability zone zone and tagged with project,
+// instances of another kubongo in the same account are never found
+func (e Ec2Host) GetServer(project, zone, name string) (Instance, error) {
+	return e.getServer(context.Background(), project, zone, name)
+}
+
+func (e Ec2Host) getServer(ctx context.Context, project, zone, name string) (*Ec2Instance, error) {
+	params := url.Values{}
+	setFilters(params, map[string][]string{
+		"tag:Name":             {name},
+		"tag:" + Ec2ProjectTag: {project},
+		"availability-zone":    {zone},
+		"instance-state-name":  liveStates,
+	})
+	found, findErr := e.describe(ctx, params)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("Could not find %s in %s on EC2", name, zone)
+	}
+	return found[0], nil
+}
+
+// Ping checks EC2 accepts the host's credentials by describing the region's availability zones
+func (e Ec2Host) Ping() error {
+	return e.call(context.Background(), "DescribeAvailabilityZones", url.Values{}, nil)
+}
+
+// CreateServer runs an instance of the instance type machineType from the AMI sourceImage in the availability zone zone,
+// tagged with its name and namespace as its project, and waits for it to run. source is unused on EC2.
+func (e Ec2Host) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
+	return e.CreateServerContext(context.Background(), namespace, zone, name, machineType, sourceImage, source)
+}
+
+// CreateServerContext creates an instance like CreateServer, reporting its state through ctx until it runs
+func (e Ec2Host) CreateServerContext(ctx context.Context, namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
+	params := url.Values{
+		"ImageId":                         {sourceImage},
+		"InstanceType":                    {machineType},
+		"MinCount":                        {"1"},
+		"MaxCount":                        {"1"},
+		"Placement.AvailabilityZone":      {zone},
+		"TagSpecification.1.ResourceType": {"instance"},
+		"TagSpecification.1.Tag.1.Key":    {"Name"},
+		"TagSpecification.1.Tag.1.Value":  {name},
+		"TagSpecification.1.Tag.2.Key":    {Ec2ProjectTag},
+		"TagSpecification.1.Tag.2.Value":  {namespace},
+	}
+	if e.SubnetID != "" {
+		params.Set("SubnetId", e.SubnetID)
+	}
+	for i, group := range e.SecurityGroupIDs {
+		params.Set("SecurityGroupId."+strconv.Itoa(i+1), group)
+	}
+	if e.KeyName != "" {
+		params.Set("KeyName", e.KeyName)
+	}
+	var instance *Ec2Instance
+	after := ""
+	for launch := 0; instance == nil; launch++ {
+		if launch == maxLaunches {
+			return nil, fmt.Errorf("EC2 kept returning terminated instances for %s", name)
+		}
+		params.Set("ClientToken", clientToken(namespace, zone, name, after))
+		result := &runInstancesResponse{}
+		if runErr := e.call(ctx, "RunInstances", params, result); runErr != nil {
+			return nil, runErr
+		}
+		if len(result.Instances) == 0 {
+			return nil, fmt.Errorf("EC2 ran no instance for %s", name)
+		}
+		launched := result.Instances[0].toInstance()
+		// a token outlives its instance, so a name created again soon after is handed the terminated instance back
+		if launched.State == "terminated" || launched.State == "shutting-down" {
+			after = launched.InstanceID
+			continue
+		}
+		instance = launched
+	}
+	instance.Name = name
+	running, waitErr := e.waitState(ctx, "RunInstances", instance, "running")
+	if waitErr != nil {
+		// unlike GCE a pending instance can be terminated right away, do so rather than leave a server nobody registered
+		e.terminate(context.Background(), instance.InstanceID)
+		return nil, waitErr
+	}
+	return running, nil
+}
+
+// maxLaunches caps how many terminated instances of earlier launches CreateServer steps over
+const maxLaunches = 10
+
+// clientToken makes RunInstances idempotent for name, so retrying a create never launches a second instance.
+// after is the instance an earlier launch under the same token ran, if it was terminated since.
+func clientToken(namespace, zone, name, after string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + zone + "/" + name + "/" + after))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeleteServer terminates the instance named name in the availability zone zone and waits for it to be terminated
+func (e Ec2Host) DeleteServer(namespace, zone, name string) error {
+	return e.DeleteServerContext(context.Background(), namespace, zone, name)
+}
+
+// DeleteServerContext deletes an instance like DeleteServer, reporting its state through ctx until it is terminated
+func (e Ec2Host) DeleteServerContext(ctx context.Context, namespace, zone, name string) error {
+	instance, findErr := e.getServer(ctx, namespace, zone, name)
+	if findErr != nil {
+		return findErr
+	}
+	state, termErr := e.terminate(ctx, instance.InstanceID)
+	if termErr != nil {
+		return termErr
+	}
+	instance.State = state
+	_, waitErr := e.waitState(ctx, "TerminateInstances", instance, "terminated")
+	return waitErr
+}
+
+// terminate terminates the instance instanceID, returning the state it is in after
+func (e Ec2Host) terminate(ctx context.Context, instanceID string) (string, error) {
+	result := &terminateInstancesResponse{}
+	termErr := e.call(ctx, "TerminateInstances", url.Values{"InstanceId.1": {instanceID}}, result)
+	if termErr != nil || len(result.Instances) == 0 {
+		return "", termErr
+	}
+	return result.Instances[0].CurrentState.Name, nil
+}
+
+// ec2Progress is how far along an instance in a state is towards running, or towards terminated
+var ec2Progress = map[string]int{
+	"pending":       50,
+	"running":       100,
+	"shutting-down": 50,
+	"terminated":    100,
+}
+
+// waitState describes instance every PollInterval until it is in the state want or ctx is done,
+// reporting each poll as the platform operation action
+func (e Ec2Host) waitState(ctx context.Context, action string, instance *Ec2Instance, want string) (*Ec2Instance, error) {
+	for {
+		op := Operation{
+			Name:     action + "/" + instance.InstanceID,
+			Type:     action,
+			Target:   instance.Name,
+			Status:   instance.State,
+			Progress: ec2Progress[instance.State],
+		}
+		// an instance that is on its way to run only fails by stopping or terminating
+		if want == "running" && instance.State != "pending" && instance.State != "running" {
+			op.Error = fmt.Sprintf("EC2 instance %s of %s is %s, not %s", instance.InstanceID, instance.Name, instance.State, want)
+		}
+		ReportOperation(ctx, op)
+		if instance.State == want {
+			return instance, nil
+		}
+		if op.Error != "" {
+			return nil, errors.New(op.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(e.PollInterval):
+		}
+		found, findErr := e.describe(ctx, url.Values{"InstanceId.1": {instance.InstanceID}})
+		if findErr != nil {
+			return nil, findErr
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("EC2 instance %s of %s disappeared", instance.InstanceID, instance.Name)
+		}
+		name := instance.Name
+		instance = found[0]
+		if instance.Name == "" {
+			instance.Name = name
+		}
+	}
+}
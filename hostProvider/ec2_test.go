@@ -0,0 +1,261 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostProvider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestSignV4(t *testing.T) {
+	// the get-vanilla case of AWS's Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := AwsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if req.Header.Get("Authorization") != expected {
+		t.Errorf("expected %s, got %s", expected, req.Header.Get("Authorization"))
+	}
+}
+
+// fakeEc2 is a tiny stand in for the EC2 query api, instances move on to their next state every time they are described
+type fakeEc2 struct {
+	mutex     sync.Mutex
+	instances map[string]*Ec2Instance
+	tokens    map[string]string
+	actions   []string
+	// stopLaunches makes launched instances stop instead of run
+	stopLaunches bool
+}
+
+var nextState = map[string]string{"pending": "running", "shutting-down": "terminated"}
+
+func (f *fakeEc2) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(req.Header.Get("Authorization"), "/us-east-1/ec2/aws4_request") {
+		res.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(res, `<Response><Errors><Error><Code>AuthFailure</Code><Message>not signed</Message></Error></Errors></Response>`)
+		return
+	}
+	req.ParseForm()
+	action := req.PostForm.Get("Action")
+	f.actions = append(f.actions, action)
+	switch action {
+	case "RunInstances":
+		if req.PostForm.Get("ImageId") == "" {
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(res, `<Response><Errors><Error><Code>MissingParameter</Code><Message>The request must contain the parameter ImageId</Message></Error></Errors><RequestID>r-1</RequestID></Response>`)
+			return
+		}
+		if id, seen := f.tokens[req.PostForm.Get("ClientToken")]; seen {
+			fmt.Fprintf(res, `<RunInstancesResponse><instancesSet>%s</instancesSet></RunInstancesResponse>`, f.item(f.instances[id]))
+			return
+		}
+		id := fmt.Sprintf("i-%04d", len(f.instances)+1)
+		f.tokens[req.PostForm.Get("ClientToken")] = id
+		f.instances[id] = &Ec2Instance{
+			InstanceID:       id,
+			ImageID:          req.PostForm.Get("ImageId"),
+			InstanceType:     req.PostForm.Get("InstanceType"),
+			AvailabilityZone: req.PostForm.Get("Placement.AvailabilityZone"),
+			State:            "pending",
+			Tags: map[string]string{
+				req.PostForm.Get("TagSpecification.1.Tag.1.Key"): req.PostForm.Get("TagSpecification.1.Tag.1.Value"),
+				req.PostForm.Get("TagSpecification.1.Tag.2.Key"): req.PostForm.Get("TagSpecification.1.Tag.2.Value"),
+			},
+		}
+		fmt.Fprintf(res, `<RunInstancesResponse><instancesSet>%s</instancesSet></RunInstancesResponse>`, f.item(f.instances[id]))
+	case "DescribeInstances":
+		items := ""
+		for _, instance := range f.instances {
+			if f.matches(instance, req) {
+				if next, ok := nextState[instance.State]; ok {
+					instance.State = next
+				}
+				if f.stopLaunches && instance.State == "running" {
+					instance.State = "stopped"
+				}
+				if instance.State == "running" {
+					instance.PrivateIPAddress = "10.0.0." + strings.TrimLeft(instance.InstanceID[2:], "0")
+				}
+				items += f.item(instance)
+			}
+		}
+		fmt.Fprintf(res, `<DescribeInstancesResponse><reservationSet><item><instancesSet>%s</instancesSet></item></reservationSet></DescribeInstancesResponse>`, items)
+	case "TerminateInstances":
+		instance := f.instances[req.PostForm.Get("InstanceId.1")]
+		instance.State = "shutting-down"
+		fmt.Fprintf(res, `<TerminateInstancesResponse><instancesSet><item><instanceId>%s</instanceId><currentState><name>shutting-down</name></currentState></item></instancesSet></TerminateInstancesResponse>`, instance.InstanceID)
+	case "DescribeAvailabilityZones":
+		fmt.Fprint(res, `<DescribeAvailabilityZonesResponse><availabilityZoneInfo/></DescribeAvailabilityZonesResponse>`)
+	}
+}
+
+func (f *fakeEc2) item(instance *Ec2Instance) string {
+	tags := ""
+	for key, value := range instance.Tags {
+		tags += fmt.Sprintf("<item><key>%s</key><value>%s</value></item>", key, value)
+	}
+	return fmt.Sprintf(`<item><instanceId>%s</instanceId><imageId>%s</imageId><instanceState><name>%s</name></instanceState>`+
+		`<instanceType>%s</instanceType><placement><availabilityZone>%s</availabilityZone></placement>`+
+		`<privateIpAddress>%s</privateIpAddress><tagSet>%s</tagSet></item>`,
+		instance.InstanceID, instance.ImageID, instance.State, instance.InstanceType, instance.AvailabilityZone, instance.PrivateIPAddress, tags)
+}
+
+// matches applies the InstanceId.1 and Filter.N parameters of a DescribeInstances request to instance
+func (f *fakeEc2) matches(instance *Ec2Instance, req *http.Request) bool {
+	if id := req.PostForm.Get("InstanceId.1"); id != "" && id != instance.InstanceID {
+		return false
+	}
+	for n := 1; req.PostForm.Get(fmt.Sprintf("Filter.%d.Name", n)) != ""; n++ {
+		name := req.PostForm.Get(fmt.Sprintf("Filter.%d.Name", n))
+		var actual string
+		switch {
+		case name == "availability-zone":
+			actual = instance.AvailabilityZone
+		case name == "instance-state-name":
+			actual = instance.State
+		case strings.HasPrefix(name, "tag:"):
+			actual = instance.Tags[strings.TrimPrefix(name, "tag:")]
+		}
+		matched := false
+		for i := 1; req.PostForm.Get(fmt.Sprintf("Filter.%d.Value.%d", n, i)) != ""; i++ {
+			matched = matched || req.PostForm.Get(fmt.Sprintf("Filter.%d.Value.%d", n, i)) == actual
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestEc2(t *testing.T) (*Ec2Host, *fakeEc2, func()) {
+	api := &fakeEc2{instances: make(map[string]*Ec2Instance), tokens: make(map[string]string)}
+	server := httptest.NewServer(api)
+	host := &Ec2Host{
+		Project:      "test",
+		Region:       "us-east-1",
+		Endpoint:     server.URL,
+		Credentials:  AwsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Client:       http.DefaultClient,
+		PollInterval: time.Millisecond,
+	}
+	return host, api, server.Close
+}
+
+func TestEc2Host(t *testing.T) {
+	host, api, closer := newTestEc2(t)
+	defer closer()
+	if pingErr := host.Ping(); pingErr != nil {
+		t.Fatal(pingErr)
+	}
+	ops := []Operation{}
+	ctx := WithOperationHook(context.Background(), func(op Operation) { ops = append(ops, op) })
+	created, createErr := host.CreateServerContext(ctx, "test", "us-east-1a", "db-0", "m4.large", "ami-123", "")
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	instance := created.(*Ec2Instance)
+	if instance.GetName() != "db-0" || instance.GetZone() != "us-east-1a" || instance.GetInternalIP() != "10.0.0.1" ||
+		instance.InstanceType != "m4.large" || instance.ImageID != "ami-123" || instance.State != "running" {
+		t.Errorf("unexpected instance %+v", instance)
+	}
+	if len(ops) != 2 || ops[0].Status != "pending" || ops[1].Status != "running" || ops[1].Progress != 100 || ops[1].Target != "db-0" {
+		t.Errorf("expected the instance to be reported pending then running, got %+v", ops)
+	}
+	retried, retryErr := host.CreateServer("test", "us-east-1a", "db-0", "m4.large", "ami-123", "")
+	if retryErr != nil || retried.(*Ec2Instance).InstanceID != instance.InstanceID || len(api.instances) != 1 {
+		t.Errorf("expected creating db-0 again to return %s, got %+v %v", instance.InstanceID, retried, retryErr)
+	}
+	found, getErr := host.GetServer("test", "us-east-1a", "db-0")
+	if getErr != nil || found.(*Ec2Instance).InstanceID != instance.InstanceID {
+		t.Errorf("expected to find %s, got %+v %v", instance.InstanceID, found, getErr)
+	}
+	if _, getErr = host.GetServer("test", "us-east-1b", "db-0"); getErr == nil {
+		t.Error("found db-0 in the wrong availability zone")
+	}
+	if _, getErr = host.GetServer("other", "us-east-1a", "db-0"); getErr == nil {
+		t.Error("found db-0 in another project")
+	}
+	if deleteErr := host.DeleteServer("other", "us-east-1a", "db-0"); deleteErr == nil || api.instances[instance.InstanceID].State != "running" {
+		t.Error("another project's kubongo terminated db-0")
+	}
+	servers, listErr := host.GetServers("test")
+	if listErr != nil || len(servers) != 1 {
+		t.Errorf("expected 1 server in the project, got %d %v", len(servers), listErr)
+	}
+	if servers, _ = host.GetServers("other"); len(servers) != 0 {
+		t.Errorf("expected no servers in another project, got %d", len(servers))
+	}
+	if deleteErr := host.DeleteServer("test", "us-east-1a", "db-0"); deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+	if api.instances[instance.InstanceID].State != "terminated" {
+		t.Errorf("expected db-0 to be terminated, it is %s", api.instances[instance.InstanceID].State)
+	}
+	if _, getErr = host.GetServer("test", "us-east-1a", "db-0"); getErr == nil {
+		t.Error("found db-0 after it was terminated")
+	}
+	recreated, recreateErr := host.CreateServer("test", "us-east-1a", "db-0", "m4.large", "ami-123", "")
+	if recreateErr != nil || recreated.(*Ec2Instance).InstanceID == instance.InstanceID || recreated.(*Ec2Instance).State != "running" {
+		t.Errorf("expected db-0 to be launched again, got %+v %v", recreated, recreateErr)
+	}
+}
+
+func TestEc2TerminatesFailedLaunches(t *testing.T) {
+	host, api, closer := newTestEc2(t)
+	defer closer()
+	api.stopLaunches = true
+	_, createErr := host.CreateServer("test", "us-east-1a", "db-0", "m4.large", "ami-123", "")
+	if createErr == nil || !strings.Contains(createErr.Error(), "stopped") {
+		t.Fatalf("expected the launch to fail as the instance stopped, got %v", createErr)
+	}
+	if state := api.instances["i-0001"].State; state != "shutting-down" {
+		t.Errorf("expected the stopped instance to be terminated, it is %s", state)
+	}
+}
+
+func TestEc2CallsFollowTheContext(t *testing.T) {
+	host, _, closer := newTestEc2(t)
+	defer closer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, createErr := host.CreateServerContext(ctx, "test", "us-east-1a", "db-0", "m4.large", "ami-123", ""); createErr == nil {
+		t.Error("expected a create with a cancelled context to fail")
+	}
+}
+
+func TestEc2Errors(t *testing.T) {
+	host, _, closer := newTestEc2(t)
+	defer closer()
+	_, createErr := host.CreateServer("test", "us-east-1a", "db-0", "m4.large", "", "")
+	ec2Err, ok := createErr.(*Ec2Error)
+	if !ok || ec2Err.StatusCode != http.StatusBadRequest || ec2Err.Code != "MissingParameter" || ec2Err.RequestID != "r-1" {
+		t.Errorf("expected a MissingParameter error, got %v", createErr)
+	}
+	host.Credentials.AccessKeyID = "other"
+	if pingErr := host.Ping(); pingErr == nil || pingErr.(*Ec2Error).Code != "AuthFailure" {
+		t.Errorf("expected an AuthFailure, got %v", pingErr)
+	}
+}
@@ -21,7 +21,11 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 
+	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gce "google.golang.org/cloud/compute/metadata"
@@ -133,6 +137,16 @@ func (g GcloudInstance) GetInternalIP() string {
 	return ""
 }
 
+// GetName returns the name of its instance
+func (g GcloudInstance) GetName() string {
+	return g.Name
+}
+
+// GetZone returns the zone of its instance, GCE returns the zone's URL
+func (g GcloudInstance) GetZone() string {
+	return path.Base(g.Zone)
+}
+
 // NewGCEInstance returns a new GceInstance struct
 func NewGCEInstance() *GcloudInstance {
 	return &GcloudInstance{}
@@ -211,6 +225,20 @@ func (g GcloudHost) GetServer(project, zone, name string) (Instance, error) {
 	return result, nil
 }
 
+// Ping checks GCE accepts the host's credentials by getting its project
+func (g GcloudHost) Ping() error {
+	gcloudRoute := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s", g.Project)
+	res, resErr := g.Client.Get(gcloudRoute)
+	if resErr != nil {
+		return resErr
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s received a status code of %d", gcloudRoute, res.StatusCode)
+	}
+	return nil
+}
+
 // InstanceTemplate is a struct for request data to create a server
 type InstanceTemplate struct {
 	Name        string `json:"name"`
@@ -219,8 +247,13 @@ type InstanceTemplate struct {
 	Source      string `json:"source"`
 }
 
-// CreateServer will send a POST to the GCE api to create an instance
+// CreateServer will send a POST to the GCE api to create an instance and wait for GCE to finish creating it
 func (g GcloudHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
+	return g.CreateServerContext(context.Background(), namespace, zone, name, machineType, sourceImage, source)
+}
+
+// CreateServerContext creates an instance like CreateServer, following GCE's zone operation through ctx
+func (g GcloudHost) CreateServerContext(ctx context.Context, namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
 	gcloudRoute := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances", namespace, zone)
 	newInstance := &InstanceTemplate{
 		Name:        name,
@@ -237,18 +270,32 @@ func (g GcloudHost) CreateServer(namespace, zone, name, machineType, sourceImage
 	if resErr != nil {
 		return nil, resErr
 	}
-	defer res.Body.Close()
-	decoder := json.NewDecoder(res.Body)
-	result := &GcloudInstance{}
-	decodeErr := decoder.Decode(result)
-	if decodeErr != nil {
-		return nil, decodeErr
+	op, opErr := decodeOperation(res)
+	if opErr != nil {
+		return nil, opErr
 	}
-	return result, nil
+	waitErr := g.waitOperation(ctx, namespace, zone, op)
+	if waitErr != nil {
+		if ctx.Err() != nil {
+			// GCE can not cancel an insert, so let it finish and delete what it created
+			go func() {
+				if g.waitOperation(context.Background(), namespace, zone, op) == nil {
+					g.DeleteServer(namespace, zone, name)
+				}
+			}()
+		}
+		return nil, waitErr
+	}
+	return g.GetServer(namespace, zone, name)
 }
 
-// DeleteServer will send GCE a DELETE to delete a specific instance
+// DeleteServer will send GCE a DELETE to delete a specific instance and wait for GCE to finish deleting it
 func (g GcloudHost) DeleteServer(namespace, zone, name string) error {
+	return g.DeleteServerContext(context.Background(), namespace, zone, name)
+}
+
+// DeleteServerContext deletes an instance like DeleteServer, following GCE's zone operation through ctx
+func (g GcloudHost) DeleteServerContext(ctx context.Context, namespace, zone, name string) error {
 	gcloudRoute := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s", namespace, zone, name)
 	req, reqErr := http.NewRequest("DELETE", gcloudRoute, nil)
 	if reqErr != nil {
@@ -258,6 +305,86 @@ func (g GcloudHost) DeleteServer(namespace, zone, name string) error {
 	if resErr != nil {
 		return resErr
 	}
+	op, opErr := decodeOperation(res)
+	if opErr != nil {
+		return opErr
+	}
+	return g.waitOperation(ctx, namespace, zone, op)
+}
+
+// operationPollInterval is how often a running zone operation is polled
+const operationPollInterval = 2 * time.Second
+
+// zoneOperation is a GCE zone operation as returned by inserts and deletes
+type zoneOperation struct {
+	Name          string `json:"name"`
+	OperationType string `json:"operationType"`
+	TargetLink    string `json:"targetLink"`
+	Status        string `json:"status"`
+	Progress      int    `json:"progress"`
+	Error         struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error"`
+	HTTPErrorMessage string `json:"httpErrorMessage"`
+}
+
+func (z *zoneOperation) toOperation() Operation {
+	op := Operation{
+		Name:     z.Name,
+		Type:     z.OperationType,
+		Target:   path.Base(z.TargetLink),
+		Status:   z.Status,
+		Progress: z.Progress,
+	}
+	messages := []string{}
+	for _, e := range z.Error.Errors {
+		messages = append(messages, e.Code+": "+e.Message)
+	}
+	op.Error = strings.Join(messages, ", ")
+	return op
+}
+
+// decodeOperation reads the zone operation in res, closing its body
+func decodeOperation(res *http.Response) (*zoneOperation, error) {
 	defer res.Body.Close()
-	return nil
+	if res.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("GCE returned %s: %s", res.Status, body)
+	}
+	result := &zoneOperation{}
+	decodeErr := json.NewDecoder(res.Body).Decode(result)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return result, nil
+}
+
+// waitOperation polls the zone operation op until it is DONE or ctx is done
+func (g GcloudHost) waitOperation(ctx context.Context, project, zone string, op *zoneOperation) error {
+	var opErr error
+	for opErr == nil {
+		reported := op.toOperation()
+		ReportOperation(ctx, reported)
+		if op.Status == "DONE" {
+			if reported.Error != "" {
+				return fmt.Errorf("GCE operation %s failed: %s", op.Name, reported.Error)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(operationPollInterval):
+		}
+		gcloudRoute := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/operations/%s", project, zone, op.Name)
+		pollRes, pollErr := g.Client.Get(gcloudRoute)
+		if pollErr != nil {
+			return pollErr
+		}
+		op, opErr = decodeOperation(pollRes)
+	}
+	return opErr
 }
@@ -17,6 +17,10 @@ package hostProvider
 type Instance interface {
 	// GetInternalIP returns a string of the instance's internal IP
 	GetInternalIP() string
+	// GetName returns the instance's name, unique within kubongo
+	GetName() string
+	// GetZone returns the zone the instance runs in
+	GetZone() string
 }
 
 //HostProvider is the interface for HostProviders for each platform to control instances on the platform
@@ -30,3 +34,8 @@ type HostProvider interface {
 	// DeleteServer deletes an instance for the platform
 	DeleteServer(namespace, zone, name string) error
 }
+
+// Pinger is a HostProvider that can check the platform is reachable and accepts its credentials
+type Pinger interface {
+	Ping() error
+}
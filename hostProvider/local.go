@@ -36,6 +36,21 @@ type LocalInstance struct {
 	Zone        string
 }
 
+// GetInternalIP returns the IP of the local process
+func (l LocalInstance) GetInternalIP() string {
+	return l.IP
+}
+
+// GetName returns the name of the local process
+func (l LocalInstance) GetName() string {
+	return l.Name
+}
+
+// GetZone returns the zone the local process was created in
+func (l LocalInstance) GetZone() string {
+	return l.Zone
+}
+
 // LocalHost controls Instances for the "local" platform
 type LocalHost struct {
 	HostProvider
@@ -47,15 +62,20 @@ func NewLocal() *LocalHost {
 	return &LocalHost{}
 }
 
+// Ping always succeeds, local processes need no credentials
+func (l *LocalHost) Ping() error {
+	return nil
+}
+
 // GetServers returns all local servers, i.e. registered process
-func (l LocalHost) GetServers(namespace string) ([]Instance, error) {
+func (l *LocalHost) GetServers(namespace string) ([]Instance, error) {
 	return l.Instances, nil
 }
 
 // GetServer returns a specific server/process
-func (l LocalHost) GetServer(project, zone, name string) (Instance, error) {
+func (l *LocalHost) GetServer(project, zone, name string) (Instance, error) {
 	for i := range l.Instances {
-		if l.Instances[i].(LocalInstance).Name == name {
+		if l.Instances[i].GetName() == name {
 			return l.Instances[i], nil
 		}
 	}
@@ -63,7 +83,7 @@ func (l LocalHost) GetServer(project, zone, name string) (Instance, error) {
 }
 
 // CreateServer creates a new server
-func (l LocalHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
+func (l *LocalHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (Instance, error) {
 	var process string
 	if source != "" {
 		process = source
@@ -78,9 +98,9 @@ func (l LocalHost) CreateServer(namespace, zone, name, machineType, sourceImage,
 		ProcessPort: port,
 		Name:        name,
 		IP:          "127.0.0.1",
+		Zone:        zone,
 	}
-	pointerL := &l
-	pointerL.Instances = append(l.Instances, newInst)
+	l.Instances = append(l.Instances, newInst)
 	if strings.Contains(newInst.Process, "mongo") {
 		imageManager := image.NewManager("local")
 		//imageManager.InstallMongo()
@@ -89,6 +109,17 @@ func (l LocalHost) CreateServer(namespace, zone, name, machineType, sourceImage,
 	return newInst, pErr
 }
 
+// localProcess accepts both LocalInstance values and pointers, CreateServer returns the latter
+func localProcess(inst Instance) string {
+	switch castInst := inst.(type) {
+	case LocalInstance:
+		return castInst.Process
+	case *LocalInstance:
+		return castInst.Process
+	}
+	return ""
+}
+
 func killProc(proc string) error {
 	pid, pErr := exec.Command("pgrep", "-f", proc).Output()
 	if pErr != nil {
@@ -106,16 +137,12 @@ func killProc(proc string) error {
 }
 
 // DeleteServer will delete a registered server i.e. kill a process
-func (l LocalHost) DeleteServer(namespace, zone, name string) error {
+func (l *LocalHost) DeleteServer(namespace, zone, name string) error {
 	for i := range l.Instances {
-		if l.Instances[i].(LocalInstance).Name == name {
-			proc := l.Instances[i].(LocalInstance).Process
-			killProc(proc)
-			if i+1 < len(l.Instances) {
-				l.Instances[i] = l.Instances[i+1]
-			} else {
-				l.Instances[i] = nil
-			}
+		if l.Instances[i].GetName() == name {
+			killProc(localProcess(l.Instances[i]))
+			l.Instances = append(l.Instances[:i], l.Instances[i+1:]...)
+			return nil
 		}
 	}
 	return errors.New("Could not find instance in local instances")
@@ -0,0 +1,54 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostProvider
+
+import (
+	"golang.org/x/net/context"
+)
+
+// Operation is a platform's own record of an asynchronous change, such as a GCE zone operation
+type Operation struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	// Status is the platform's status, for GCE PENDING, RUNNING or DONE and for EC2 the instance's state
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+	Error    string `json:"error,omitempty"`
+}
+
+// OperationHook is called every time a platform operation is polled, it must not block
+type OperationHook func(op Operation)
+
+// ContextHostProvider is a HostProvider that follows its creates and deletes until the platform finishes them,
+// reporting each poll to the OperationHook of ctx and giving up when ctx is done
+type ContextHostProvider interface {
+	HostProvider
+	CreateServerContext(ctx context.Context, namespace, zone, name, machineType, sourceImage, source string) (Instance, error)
+	DeleteServerContext(ctx context.Context, namespace, zone, name string) error
+}
+
+type operationHookKey struct{}
+
+// WithOperationHook returns a ctx whose platform operations are reported to hook
+func WithOperationHook(ctx context.Context, hook OperationHook) context.Context {
+	return context.WithValue(ctx, operationHookKey{}, hook)
+}
+
+// ReportOperation calls the OperationHook of ctx if it has one, for ContextHostProviders to report each poll
+func ReportOperation(ctx context.Context, op Operation) {
+	if hook, ok := ctx.Value(operationHookKey{}).(OperationHook); ok {
+		hook(op)
+	}
+}
@@ -87,22 +87,22 @@ func (i *Manager) run(cmd string, finish chan error) {
 
 // RunCMD runs a Bash command on targeted image
 func (i *Manager) RunCMD(command string) {
-	log.Println("image:160 RUNCMD")
+	log.Println("image:90 RUNCMD")
 	waitgroup.Wait()
 	waitgroup.Add(1)
 	defer waitgroup.Done()
-	log.Println("image:110 SPAWN")
+	log.Println("image:94 SPAWN")
 	finish := make(chan error)
 	go i.run(command, finish)
 	for {
 		select {
 		case m1 := <-finish:
-			log.Println("image:127 ", m1)
+			log.Println("image:100 ", m1)
 			//log.Println(command, "pid:", i.SSHCommand.Process.Pid, "is about to close")
 			if m1 != nil {
 				log.Fatal("res ", m1, command)
 			} else {
-				log.Println("image:132 CMD success")
+				log.Println("image:105 CMD success")
 				return
 			}
 		}
@@ -112,16 +112,16 @@ func (i *Manager) RunCMD(command string) {
 // InstallMongo installs mongo on target image
 func (i *Manager) InstallMongo() {
 	mongoExec, execErr := os.Lstat("/usr/local/bin/mongod")
-	log.Println("image:143 ", mongoExec)
+	log.Println("image:115 ", mongoExec)
 	if execErr != nil {
-		log.Println("image:145 ", execErr)
+		log.Println("image:117 ", execErr)
 	} else if mongoExec == nil {
 		var installCMD string
 		switch i.OS {
 		case "ubuntu-14-04", "ubuntu-12-04", "debian-7", "debian-8":
 			installCMD = "apt-get update && apt-get install mongodb"
 		case "darwin":
-			log.Println("image:152 is darwin OS")
+			log.Println("image:124 is darwin OS")
 			installCMD = "/usr/local/bin/brew update && /usr/local/bin/brew install mongodb"
 		}
 		i.RunCMD(installCMD)
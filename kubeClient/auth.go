@@ -0,0 +1,291 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ServiceAccountDir is where Kubernetes mounts a pod's service account token, CA and namespace
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config is how to reach and authenticate with the Kubernetes api server
+type Config struct {
+	// Host is the base URL of the api server, e.g. https://10.0.0.1:6443
+	Host string
+	// Namespace is the namespace of the kubeconfig context or the pod's service account, may be empty
+	Namespace string
+	// BearerToken is sent as an Authorization header, BearerTokenFile is re-read periodically and wins over it
+	BearerToken     string
+	BearerTokenFile string
+	Username        string
+	Password        string
+	// CAData is PEM encoded CA certificates to trust, if empty the system roots are used
+	CAData []byte
+	// CertData and KeyData are a PEM encoded client certificate and key
+	CertData []byte
+	KeyData  []byte
+	Insecure bool
+}
+
+// InsecureConfig is the config for an api server on plain HTTP without credentials, e.g. a local master on 127.0.0.1:8080
+func InsecureConfig(apiServerIP string) *Config {
+	host := apiServerIP
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = fmt.Sprintf("http://%s", host)
+	}
+	return &Config{Host: host}
+}
+
+// InClusterConfig is the config for running in a pod, using the mounted service account token and CA
+func InClusterConfig() (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set to run in cluster")
+	}
+	tokenFile := filepath.Join(ServiceAccountDir, "token")
+	if _, statErr := os.Stat(tokenFile); statErr != nil {
+		return nil, statErr
+	}
+	caData, caErr := ioutil.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if caErr != nil {
+		return nil, caErr
+	}
+	namespace, _ := ioutil.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+	return &Config{
+		Host:            fmt.Sprintf("https://%s", net.JoinHostPort(host, port)),
+		Namespace:       strings.TrimSpace(string(namespace)),
+		BearerTokenFile: tokenFile,
+		CAData:          caData,
+	}, nil
+}
+
+type kubeconfigCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+}
+
+type kubeconfigUser struct {
+	Token                 string `yaml:"token"`
+	TokenFile             string `yaml:"tokenFile"`
+	ClientCertificate     string `yaml:"client-certificate"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKey             string `yaml:"client-key"`
+	ClientKeyData         string `yaml:"client-key-data"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+}
+
+type kubeconfigContext struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace"`
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string            `yaml:"name"`
+		Cluster kubeconfigCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string         `yaml:"name"`
+		User kubeconfigUser `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string            `yaml:"name"`
+		Context kubeconfigContext `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// readData returns the base64 decoded inline data if set, otherwise the contents of the file relative to dir
+func readData(inline, file, dir string) ([]byte, error) {
+	if inline != "" {
+		return base64.StdEncoding.DecodeString(inline)
+	}
+	if file == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// LoadKubeconfig reads a kubeconfig file and returns the config for contextName, or its current-context if empty
+func LoadKubeconfig(path, contextName string) (*Config, error) {
+	raw, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	kc := &kubeconfig{}
+	yamlErr := yaml.Unmarshal(raw, kc)
+	if yamlErr != nil {
+		return nil, yamlErr
+	}
+	if contextName == "" {
+		contextName = kc.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("%s has no current-context and no context was given", path)
+	}
+	var ctx *kubeconfigContext
+	for i := range kc.Contexts {
+		if kc.Contexts[i].Name == contextName {
+			ctx = &kc.Contexts[i].Context
+		}
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("context %s not found in %s", contextName, path)
+	}
+	var cluster *kubeconfigCluster
+	for i := range kc.Clusters {
+		if kc.Clusters[i].Name == ctx.Cluster {
+			cluster = &kc.Clusters[i].Cluster
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %s of context %s not found in %s", ctx.Cluster, contextName, path)
+	}
+	user := &kubeconfigUser{}
+	for i := range kc.Users {
+		if kc.Users[i].Name == ctx.User {
+			user = &kc.Users[i].User
+		}
+	}
+	dir := filepath.Dir(path)
+	conf := &Config{
+		Host:        cluster.Server,
+		Namespace:   ctx.Namespace,
+		BearerToken: user.Token,
+		Username:    user.Username,
+		Password:    user.Password,
+		Insecure:    cluster.InsecureSkipTLSVerify,
+	}
+	if user.TokenFile != "" {
+		conf.BearerTokenFile = user.TokenFile
+		if !filepath.IsAbs(conf.BearerTokenFile) {
+			conf.BearerTokenFile = filepath.Join(dir, conf.BearerTokenFile)
+		}
+	}
+	var dataErr error
+	if conf.CAData, dataErr = readData(cluster.CertificateAuthorityData, cluster.CertificateAuthority, dir); dataErr != nil {
+		return nil, dataErr
+	}
+	if conf.CertData, dataErr = readData(user.ClientCertificateData, user.ClientCertificate, dir); dataErr != nil {
+		return nil, dataErr
+	}
+	if conf.KeyData, dataErr = readData(user.ClientKeyData, user.ClientKey, dir); dataErr != nil {
+		return nil, dataErr
+	}
+	return conf, nil
+}
+
+// tokenRefreshInterval is how often a BearerTokenFile is re-read, service account tokens are rotated by the kubelet
+const tokenRefreshInterval = time.Minute
+
+// authTransport adds bearer token or basic auth credentials to every request
+type authTransport struct {
+	base      http.RoundTripper
+	mutex     sync.Mutex
+	token     string
+	tokenFile string
+	readAt    time.Time
+	username  string
+	password  string
+}
+
+func (a *authTransport) bearerToken() (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.tokenFile != "" && time.Since(a.readAt) > tokenRefreshInterval {
+		token, readErr := ioutil.ReadFile(a.tokenFile)
+		if readErr != nil {
+			return "", readErr
+		}
+		a.token = strings.TrimSpace(string(token))
+		a.readAt = time.Now()
+	}
+	return a.token, nil
+}
+
+func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, tokenErr := a.bearerToken()
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	// RoundTrippers must not modify the request they are given
+	authReq := new(http.Request)
+	*authReq = *req
+	authReq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authReq.Header[k] = v
+	}
+	if token != "" {
+		authReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	} else if a.username != "" {
+		authReq.SetBasicAuth(a.username, a.password)
+	}
+	return a.base.RoundTrip(authReq)
+}
+
+// HTTPClient returns an http.Client that trusts the config's CA and presents its credentials
+func (cfg *Config) HTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, errors.New("could not parse any CA certificates for the Kubernetes api server")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.CertData) > 0 || len(cfg.KeyData) > 0 {
+		cert, certErr := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if certErr != nil {
+			return nil, certErr
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if cfg.BearerToken != "" || cfg.BearerTokenFile != "" || cfg.Username != "" {
+		transport = &authTransport{
+			base:      transport,
+			token:     cfg.BearerToken,
+			tokenFile: cfg.BearerTokenFile,
+			username:  cfg.Username,
+			password:  cfg.Password,
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
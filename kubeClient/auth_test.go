@@ -0,0 +1,93 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKubeconfigPing(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer s3cret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Path != "/healthz" {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]})
+	dir, dirErr := ioutil.TempDir(os.TempDir(), "kubeconfig")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("s3cret\n"), 0600)
+	kubeconfigYAML := fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+current-context: other
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: other
+  context:
+    cluster: missing
+    user: tester
+- name: test
+  context:
+    cluster: test
+    user: tester
+    namespace: mongo
+users:
+- name: tester
+  user:
+    tokenFile: token
+`, server.URL, base64.StdEncoding.EncodeToString(caPEM))
+	path := filepath.Join(dir, "config")
+	ioutil.WriteFile(path, []byte(kubeconfigYAML), 0600)
+	_, currentErr := LoadKubeconfig(path, "")
+	if currentErr == nil {
+		t.Error("expected an error for a context pointing at a missing cluster")
+	}
+	conf, confErr := LoadKubeconfig(path, "test")
+	if confErr != nil {
+		t.Fatal(confErr)
+	}
+	ctl, ctlErr := NewWithConfig(conf, "", "DB_CONNECT_STRING", "mongo")
+	if ctlErr != nil {
+		t.Fatal(ctlErr)
+	}
+	if ctl.Namespace != "mongo" {
+		t.Errorf("expected the context's namespace mongo, got %s", ctl.Namespace)
+	}
+	pingErr := ctl.Ping()
+	if pingErr != nil {
+		t.Error(pingErr)
+	}
+}
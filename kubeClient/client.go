@@ -17,13 +17,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	kube "golang.org/x/build/kubernetes"
-	api "golang.org/x/build/kubernetes/api"
-	"golang.org/x/net/context"
 )
 
 // Controller for talking to Kubernetes
@@ -31,79 +30,244 @@ type Controller struct {
 	APIServerIP string
 	Client      *kube.Client
 	rawClient   *http.Client
+	baseURL     string
 	Namespace   string
 	EnvVarName  string
+	// ServiceName is the name of the selector-less Service and Endpoints kubongo publishes mongo under
+	ServiceName string
+	// ServicePort is the port the Service exposes and the Endpoints point at
+	ServicePort int
+	// Connection holds what is written into the connection string ConfigMap and Secret
+	Connection ConnectionConfig
+	// ConsumesAnnotation marks Deployments and StatefulSets to restart when the connection string changes,
+	// its value must be the name of the ConfigMap/Secret the workload consumes
+	ConsumesAnnotation string
+	// configName overrides ConfigName() for controllers made by WithServiceName
+	configName string
 }
 
-// New creates a new Controller struct
-func New(apiServerIP, namespace, evn string) *Controller {
-	httpClient := &http.Client{}
-	kubeClient, kubeErr := kube.NewClient(apiServerIP, httpClient)
+// DefaultConsumesAnnotation is the annotation looked for on workloads when none is set
+const DefaultConsumesAnnotation = "kubongo.io/consumes"
+
+// DefaultServicePort is the port published for mongo when none is set
+const DefaultServicePort = 27017
+
+// New creates a new Controller struct for an api server on plain HTTP without credentials
+func New(apiServerIP, namespace, evn, serviceName string) *Controller {
+	ctl, ctlErr := NewWithConfig(InsecureConfig(apiServerIP), namespace, evn, serviceName)
+	if ctlErr != nil {
+		panic(ctlErr)
+	}
+	return ctl
+}
+
+// NewWithConfig creates a new Controller struct that talks to the api server described by cfg,
+// if namespace is empty the namespace of cfg is used
+func NewWithConfig(cfg *Config, namespace, evn, serviceName string) (*Controller, error) {
+	httpClient, clientErr := cfg.HTTPClient()
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	kubeClient, kubeErr := kube.NewClient(cfg.Host, httpClient)
 	if kubeErr != nil {
-		panic(kubeErr)
+		return nil, kubeErr
+	}
+	if namespace == "" {
+		namespace = cfg.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
 	}
 	return &Controller{
-		APIServerIP: apiServerIP,
+		APIServerIP: strings.TrimPrefix(strings.TrimPrefix(cfg.Host, "https://"), "http://"),
 		Client:      kubeClient,
 		rawClient:   httpClient,
+		baseURL:     strings.TrimSuffix(cfg.Host, "/"),
 		Namespace:   namespace,
 		EnvVarName:  evn,
+		ServiceName: serviceName,
+		ServicePort: DefaultServicePort,
+		Connection: ConnectionConfig{
+			ReadPreference: "primary",
+		},
+		ConsumesAnnotation: DefaultConsumesAnnotation,
+	}, nil
+}
+
+// WithServiceName returns a copy of the Controller that publishes under another Service, Endpoints, ConfigMap and Secret name
+func (c *Controller) WithServiceName(serviceName string) *Controller {
+	clone := *c
+	clone.ServiceName = serviceName
+	clone.configName = serviceName
+	return &clone
+}
+
+// APIError is returned when the Kubernetes api responds with a non 2xx status
+type APIError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Status     Status
+}
+
+func (e *APIError) Error() string {
+	if e.Status.Message != "" {
+		return fmt.Sprintf("%s %s received a status code of %d: %s", e.Method, e.URL, e.StatusCode, e.Status.Message)
 	}
+	return fmt.Sprintf("%s %s received a status code of %d", e.Method, e.URL, e.StatusCode)
+}
+
+// IsNotFound returns true if err is a 404 from the Kubernetes api
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict returns true if err is a 409 from the Kubernetes api
+func IsConflict(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusConflict
 }
 
-type execPayload struct {
-	Command   string `json:"command"`
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
+func (c *Controller) url(path string) string {
+	return fmt.Sprintf("%s%s", c.baseURL, path)
 }
 
-func (c *Controller) kubeExec(pod api.Pod, cmd string, execErrs chan error) {
-	url := fmt.Sprintf("http://%s/api/v1/namespaces/%s/pods/%s/exec", c.APIServerIP, c.Namespace, pod.ObjectMeta.Name)
-	payload := &execPayload{
-		Command:   cmd,
-		Name:      pod.ObjectMeta.Name,
-		Namespace: c.Namespace,
+func (c *Controller) namespacedPath(resource, name string) string {
+	if name == "" {
+		return fmt.Sprintf("/api/v1/namespaces/%s/%s", c.Namespace, resource)
 	}
-	payloadJSON, pErr := json.Marshal(payload)
-	if pErr != nil {
-		execErrs <- pErr
+	return fmt.Sprintf("/api/v1/namespaces/%s/%s/%s", c.Namespace, resource, name)
+}
+
+// groupPath is namespacedPath for resources outside of the core api group, e.g. apps/v1
+func (c *Controller) groupPath(groupVersion, resource, name string) string {
+	if name == "" {
+		return fmt.Sprintf("/apis/%s/namespaces/%s/%s", groupVersion, c.Namespace, resource)
+	}
+	return fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s", groupVersion, c.Namespace, resource, name)
+}
+
+// do sends body as JSON to path and decodes the response into out, either may be nil
+func (c *Controller) do(method, path, contentType string, body, out interface{}) error {
+	var reqBody *bytes.Buffer
+	if body != nil {
+		bodyJSON, bErr := json.Marshal(body)
+		if bErr != nil {
+			return bErr
+		}
+		reqBody = bytes.NewBuffer(bodyJSON)
+	} else {
+		reqBody = &bytes.Buffer{}
 	}
-	resp, resErr := c.rawClient.Post(url, "Application/JSON", bytes.NewBuffer(payloadJSON))
+	url := c.url(path)
+	req, reqErr := http.NewRequest(method, url, reqBody)
+	if reqErr != nil {
+		return reqErr
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, resErr := c.rawClient.Do(req)
 	if resErr != nil {
-		execErrs <- resErr
+		return resErr
+	}
+	defer res.Body.Close()
+	resBody, readErr := ioutil.ReadAll(res.Body)
+	if readErr != nil {
+		return readErr
 	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		execErrs <- fmt.Errorf("%s received a status code of %d", url, resp.StatusCode)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: res.StatusCode, Method: method, URL: url}
+		json.Unmarshal(resBody, &apiErr.Status)
+		return apiErr
 	}
+	if out == nil || len(resBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resBody, out)
 }
 
-// UpdateServiceEndPoint updates kubernetes with the new endpoint for the service
-func (c *Controller) UpdateServiceEndPoint(newEndPoint string) error {
-	ctx := context.Background()
-	pods, pErr := c.Client.GetPods(ctx)
-	if pErr != nil {
-		return pErr
-	}
-	errsChan := make(chan error)
-	for i := range pods {
-		go c.kubeExec(pods[i], fmt.Sprintf("export %s=%s", c.EnvVarName, newEndPoint), errsChan)
-		select {
-		case err := <-errsChan:
-			if err != nil {
-				return err
-			}
+func (c *Controller) get(path string, out interface{}) error {
+	return c.do("GET", path, "", nil, out)
+}
+
+func (c *Controller) create(path string, body, out interface{}) error {
+	return c.do("POST", path, "application/json", body, out)
+}
+
+func (c *Controller) update(path string, body, out interface{}) error {
+	return c.do("PUT", path, "application/json", body, out)
+}
+
+func (c *Controller) patch(path string, body, out interface{}) error {
+	return c.do("PATCH", path, "application/strategic-merge-patch+json", body, out)
+}
+
+// mergePatch is patch for custom resources, which do not support strategic merge patches
+func (c *Controller) mergePatch(path string, body, out interface{}) error {
+	return c.do("PATCH", path, "application/merge-patch+json", body, out)
+}
+
+// maxConflictRetries is how many times a write is retried when someone else updated the object between our GET and PUT
+const maxConflictRetries = 3
+
+// object is a Kubernetes object whose metadata can be read and written by upsert
+type object interface {
+	meta() *ObjectMeta
+}
+
+// upsert creates obj under the collection path if it does not exist, otherwise replaces it in place,
+// retrying when someone else updated the object between our GET and PUT. On success obj carries the written resourceVersion.
+func (c *Controller) upsert(collection string, obj object) error {
+	meta := obj.meta()
+	path := fmt.Sprintf("%s/%s", collection, meta.Name)
+	var writeErr error
+	for attempt := 0; attempt < maxConflictRetries; attempt++ {
+		existing := &struct {
+			Metadata ObjectMeta `json:"metadata"`
+		}{}
+		getErr := c.get(path, existing)
+		written := &struct {
+			Metadata ObjectMeta `json:"metadata"`
+		}{}
+		if IsNotFound(getErr) {
+			writeErr = c.create(collection, obj, written)
+		} else if getErr != nil {
+			return getErr
+		} else {
+			meta.ResourceVersion = existing.Metadata.ResourceVersion
+			writeErr = c.update(path, obj, written)
+		}
+		if writeErr == nil {
+			meta.ResourceVersion = written.Metadata.ResourceVersion
+		}
+		if !IsConflict(writeErr) {
+			return writeErr
 		}
 	}
-	return nil
+	return writeErr
 }
 
-// Ping checks that Kubernetes' master exists
+// pingTimeout bounds how long Ping waits on the api server
+const pingTimeout = 3 * time.Second
+
+// Ping checks that Kubernetes' master is up and accepts our credentials by hitting /healthz, falling back to /version
 func (c *Controller) Ping() error {
-	conn, connErr := net.Dial("tcp", c.APIServerIP)
-	if connErr != nil {
-		return connErr
+	pingClient := *c.rawClient
+	pingClient.Timeout = pingTimeout
+	var lastErr error
+	for _, path := range []string{"/healthz", "/version"} {
+		res, resErr := pingClient.Get(c.url(path))
+		if resErr != nil {
+			return resErr
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			return nil
+		}
+		lastErr = &APIError{StatusCode: res.StatusCode, Method: "GET", URL: c.url(path)}
 	}
-	conn.SetDeadline(time.Now().Add(3 * time.Second))
-	defer conn.Close()
-	return nil
+	return lastErr
 }
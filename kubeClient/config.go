@@ -0,0 +1,162 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+// ConnectionConfig is what goes into the connection string besides the hosts
+type ConnectionConfig struct {
+	ReplicaSet     string
+	ReadPreference string
+	Database       string
+	AuthSource     string
+	Username       string
+	Password       string
+}
+
+// connectionRevisionAnnotation is stamped on consumers' pod templates with the Secret's resourceVersion, changing it is what rolls them
+const connectionRevisionAnnotation = "kubongo.io/connection-revision"
+
+// ConfigName is the name of the ConfigMap and Secret, derived from EnvVarName, e.g. DB_CONNECT_STRING becomes db-connect-string
+func (c *Controller) ConfigName() string {
+	if c.configName != "" {
+		return c.configName
+	}
+	return strings.Replace(strings.ToLower(c.EnvVarName), "_", "-", -1)
+}
+
+func (c *Controller) hosts(instances metadata.Instances) []string {
+	hosts := []string{}
+	for i := range instances {
+		if instances[i] == nil {
+			continue
+		}
+		ip := instances[i].GetInternalIP()
+		if ip == "" {
+			continue
+		}
+		hosts = append(hosts, fmt.Sprintf("%s:%d", ip, c.ServicePort))
+	}
+	return hosts
+}
+
+func (c *Controller) connectionURL(hosts []string) *url.URL {
+	query := url.Values{}
+	if c.Connection.ReplicaSet != "" {
+		query.Set("replicaSet", c.Connection.ReplicaSet)
+	}
+	if c.Connection.ReadPreference != "" {
+		query.Set("readPreference", c.Connection.ReadPreference)
+	}
+	if c.Connection.AuthSource != "" {
+		query.Set("authSource", c.Connection.AuthSource)
+	}
+	connURL := &url.URL{
+		Scheme:   "mongodb",
+		Host:     strings.Join(hosts, ","),
+		Path:     "/" + c.Connection.Database,
+		RawQuery: query.Encode(),
+	}
+	if c.Connection.Username != "" {
+		connURL.User = url.UserPassword(c.Connection.Username, c.Connection.Password)
+	}
+	return connURL
+}
+
+// ConnectionString returns the mongodb:// URI for instances, including credentials
+func (c *Controller) ConnectionString(instances metadata.Instances) string {
+	return c.connectionURL(c.hosts(instances)).String()
+}
+
+// UpdateConnectionString writes the hosts, replica set and read preference into a ConfigMap and the full URI into a Secret,
+// both named ConfigName() with the URI under the EnvVarName key, then rolls every workload annotated as consuming them
+func (c *Controller) UpdateConnectionString(instances metadata.Instances) error {
+	hosts := c.hosts(instances)
+	if len(hosts) == 0 {
+		return errors.New("no mongo instances with an internal IP to write into the connection string")
+	}
+	name := c.ConfigName()
+	configMap := &ConfigMap{
+		TypeMeta: TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+		Metadata: ObjectMeta{Name: name, Namespace: c.Namespace, Labels: managedByLabels},
+		Data: map[string]string{
+			"hosts":          strings.Join(hosts, ","),
+			"replicaSet":     c.Connection.ReplicaSet,
+			"readPreference": c.Connection.ReadPreference,
+			"database":       c.Connection.Database,
+		},
+	}
+	cmErr := c.upsert(c.namespacedPath("configmaps", ""), configMap)
+	if cmErr != nil {
+		return cmErr
+	}
+	uri := c.connectionURL(hosts).String()
+	secret := &Secret{
+		TypeMeta:   TypeMeta{Kind: "Secret", APIVersion: "v1"},
+		Metadata:   ObjectMeta{Name: name, Namespace: c.Namespace, Labels: managedByLabels},
+		Type:       "Opaque",
+		StringData: map[string]string{c.EnvVarName: uri},
+	}
+	secretErr := c.upsert(c.namespacedPath("secrets", ""), secret)
+	if secretErr != nil {
+		return secretErr
+	}
+	// the api server only bumps the resourceVersion when the Secret changed, and unlike a hash of the URI it reveals nothing about the password
+	return c.RestartConsumers(secret.Metadata.ResourceVersion)
+}
+
+// RestartConsumers rolls every Deployment and StatefulSet annotated with ConsumesAnnotation: ConfigName()
+// whose pod template was not already stamped with revision
+func (c *Controller) RestartConsumers(revision string) error {
+	name := c.ConfigName()
+	for _, resource := range []string{"deployments", "statefulsets"} {
+		list := &WorkloadList{}
+		listErr := c.get(c.groupPath("apps/v1", resource, ""), list)
+		if listErr != nil {
+			return listErr
+		}
+		for i := range list.Items {
+			workload := list.Items[i]
+			if workload.Metadata.Annotations[c.ConsumesAnnotation] != name {
+				continue
+			}
+			if workload.Spec.Template.Metadata.Annotations[connectionRevisionAnnotation] == revision {
+				continue
+			}
+			log.Println("config:145 restarting", resource, workload.Metadata.Name, "for new connection string")
+			patch := map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"annotations": map[string]string{connectionRevisionAnnotation: revision},
+						},
+					},
+				},
+			}
+			patchErr := c.patch(c.groupPath("apps/v1", resource, workload.Metadata.Name), patch, nil)
+			if patchErr != nil {
+				return patchErr
+			}
+		}
+	}
+	return nil
+}
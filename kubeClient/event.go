@@ -0,0 +1,93 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Event types, Warning is for anything an operator may need to act on
+const (
+	EventNormal  = "Normal"
+	EventWarning = "Warning"
+)
+
+// EventComponent is the source component kubongo reports its Events as
+const EventComponent = "kubongo"
+
+// ObjectReference points at the object an Event is about
+type ObjectReference struct {
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+// EventSource is the component and host that reported an Event
+type EventSource struct {
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+// Event is a v1 Event
+type Event struct {
+	TypeMeta           `json:",inline"`
+	Metadata           ObjectMeta      `json:"metadata"`
+	InvolvedObject     ObjectReference `json:"involvedObject"`
+	Reason             string          `json:"reason,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	Type               string          `json:"type,omitempty"`
+	Source             EventSource     `json:"source,omitempty"`
+	FirstTimestamp     string          `json:"firstTimestamp,omitempty"`
+	LastTimestamp      string          `json:"lastTimestamp,omitempty"`
+	Count              int32           `json:"count,omitempty"`
+	ReportingComponent string          `json:"reportingComponent,omitempty"`
+	ReportingInstance  string          `json:"reportingInstance,omitempty"`
+}
+
+// newEvent builds an Event about the Controller's Service, named like kubectl and client-go name theirs
+func (c *Controller) newEvent(eventType, reason, message string, now time.Time) *Event {
+	host, _ := os.Hostname()
+	timestamp := now.UTC().Format(time.RFC3339)
+	return &Event{
+		TypeMeta: TypeMeta{Kind: "Event", APIVersion: "v1"},
+		Metadata: ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", c.ServiceName, now.UnixNano()),
+			Namespace: c.Namespace,
+			Labels:    managedByLabels,
+		},
+		InvolvedObject: ObjectReference{
+			Kind:       "Service",
+			Namespace:  c.Namespace,
+			Name:       c.ServiceName,
+			APIVersion: "v1",
+		},
+		Reason:             reason,
+		Message:            message,
+		Type:               eventType,
+		Source:             EventSource{Component: EventComponent, Host: host},
+		FirstTimestamp:     timestamp,
+		LastTimestamp:      timestamp,
+		Count:              1,
+		ReportingComponent: EventComponent,
+		ReportingInstance:  host,
+	}
+}
+
+// RecordEvent posts an Event about the Controller's Service into its namespace
+func (c *Controller) RecordEvent(eventType, reason, message string) error {
+	return c.create(c.namespacedPath("events", ""), c.newEvent(eventType, reason, message, time.Now()), nil)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"fmt"
+	"log"
+)
+
+// MongoCluster CRD coordinates
+const (
+	MongoClusterGroup    = "kubongo.io"
+	MongoClusterVersion  = "v1alpha1"
+	MongoClusterKind     = "MongoCluster"
+	MongoClusterPlural   = "mongoclusters"
+	MongoClusterSingular = "mongocluster"
+)
+
+var mongoClusterGroupVersion = fmt.Sprintf("%s/%s", MongoClusterGroup, MongoClusterVersion)
+
+// MongoClusterSpec is the desired state of an out of cluster mongo deployment
+type MongoClusterSpec struct {
+	Zone        string `json:"zone"`
+	MachineType string `json:"machineType"`
+	Members     int    `json:"members"`
+	SourceImage string `json:"sourceImage,omitempty"`
+	ServiceName string `json:"serviceName"`
+}
+
+// MongoClusterMember is an instance kubongo created for a MongoCluster
+type MongoClusterMember struct {
+	Name    string `json:"name"`
+	Zone    string `json:"zone"`
+	IP      string `json:"ip,omitempty"`
+	Healthy bool   `json:"healthy"`
+}
+
+// MongoClusterStatus is the observed state of a MongoCluster, written by kubongo
+type MongoClusterStatus struct {
+	ObservedGeneration int64                `json:"observedGeneration,omitempty"`
+	Phase              string               `json:"phase,omitempty"`
+	ReadyMembers       int                  `json:"readyMembers"`
+	Members            []MongoClusterMember `json:"members"`
+	Message            string               `json:"message,omitempty"`
+}
+
+// MongoCluster is the custom resource for an out of cluster mongo deployment
+type MongoCluster struct {
+	TypeMeta `json:",inline"`
+	Metadata ObjectMeta         `json:"metadata"`
+	Spec     MongoClusterSpec   `json:"spec"`
+	Status   MongoClusterStatus `json:"status,omitempty"`
+}
+
+func (m *MongoCluster) meta() *ObjectMeta { return &m.Metadata }
+
+// MongoClusterList is a list of MongoClusters
+type MongoClusterList struct {
+	TypeMeta `json:",inline"`
+	Metadata ListMeta       `json:"metadata"`
+	Items    []MongoCluster `json:"items"`
+}
+
+type schemaProps map[string]interface{}
+
+func mongoClusterCRD() map[string]interface{} {
+	str := schemaProps{"type": "string"}
+	integer := schemaProps{"type": "integer"}
+	member := schemaProps{
+		"type": "object",
+		"properties": schemaProps{
+			"name":    str,
+			"zone":    str,
+			"ip":      str,
+			"healthy": schemaProps{"type": "boolean"},
+		},
+	}
+	return map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": ObjectMeta{
+			Name:   fmt.Sprintf("%s.%s", MongoClusterPlural, MongoClusterGroup),
+			Labels: managedByLabels,
+		},
+		"spec": map[string]interface{}{
+			"group": MongoClusterGroup,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"plural":     MongoClusterPlural,
+				"singular":   MongoClusterSingular,
+				"kind":       MongoClusterKind,
+				"listKind":   MongoClusterKind + "List",
+				"shortNames": []string{"mgc"},
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    MongoClusterVersion,
+					"served":  true,
+					"storage": true,
+					"subresources": map[string]interface{}{
+						"status": map[string]interface{}{},
+					},
+					"additionalPrinterColumns": []interface{}{
+						schemaProps{"name": "Members", "type": "integer", "jsonPath": ".spec.members"},
+						schemaProps{"name": "Ready", "type": "integer", "jsonPath": ".status.readyMembers"},
+						schemaProps{"name": "Phase", "type": "string", "jsonPath": ".status.phase"},
+					},
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": schemaProps{
+							"type": "object",
+							"properties": schemaProps{
+								"spec": schemaProps{
+									"type":     "object",
+									"required": []string{"zone", "machineType", "members", "serviceName"},
+									"properties": schemaProps{
+										"zone":        str,
+										"machineType": str,
+										"members":     schemaProps{"type": "integer", "minimum": 0},
+										"sourceImage": str,
+										"serviceName": str,
+									},
+								},
+								"status": schemaProps{
+									"type": "object",
+									"properties": schemaProps{
+										"observedGeneration": integer,
+										"phase":              str,
+										"readyMembers":       integer,
+										"members":            schemaProps{"type": "array", "items": member},
+										"message":            str,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EnsureMongoClusterCRD registers the MongoCluster CustomResourceDefinition if it does not exist yet
+func (c *Controller) EnsureMongoClusterCRD() error {
+	path := "/apis/apiextensions.k8s.io/v1/customresourcedefinitions"
+	getErr := c.get(fmt.Sprintf("%s/%s.%s", path, MongoClusterPlural, MongoClusterGroup), nil)
+	if getErr == nil {
+		return nil
+	}
+	if !IsNotFound(getErr) {
+		return getErr
+	}
+	log.Println("mongocluster:163 registering the", MongoClusterKind, "CustomResourceDefinition")
+	createErr := c.create(path, mongoClusterCRD(), nil)
+	if IsConflict(createErr) {
+		return nil
+	}
+	return createErr
+}
+
+// ListMongoClusters lists the MongoClusters in the Controller's namespace
+func (c *Controller) ListMongoClusters() (*MongoClusterList, error) {
+	list := &MongoClusterList{}
+	listErr := c.get(c.groupPath(mongoClusterGroupVersion, MongoClusterPlural, ""), list)
+	return list, listErr
+}
+
+// WatchMongoClusters watches the MongoClusters in the Controller's namespace from resourceVersion
+func (c *Controller) WatchMongoClusters(resourceVersion string) (*Watcher, error) {
+	return c.watch(c.groupPath(mongoClusterGroupVersion, MongoClusterPlural, ""), resourceVersion)
+}
+
+// UpdateMongoClusterStatus writes status into the status subresource of the named MongoCluster
+func (c *Controller) UpdateMongoClusterStatus(name string, status MongoClusterStatus) error {
+	patch := map[string]interface{}{"status": status}
+	return c.mergePatch(c.groupPath(mongoClusterGroupVersion, MongoClusterPlural, name)+"/status", patch, nil)
+}
+
+// SetMongoClusterFinalizers replaces the finalizers of the named MongoCluster
+func (c *Controller) SetMongoClusterFinalizers(name string, finalizers []string) error {
+	if finalizers == nil {
+		finalizers = []string{}
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"finalizers": finalizers},
+	}
+	return c.mergePatch(c.groupPath(mongoClusterGroupVersion, MongoClusterPlural, name), patch, nil)
+}
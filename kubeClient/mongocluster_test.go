@@ -0,0 +1,55 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnsureMongoClusterCRD(t *testing.T) {
+	ctl, api, closer := newTestController(t)
+	defer closer()
+	if err := ctl.EnsureMongoClusterCRD(); err != nil {
+		t.Fatal(err)
+	}
+	crd := &struct {
+		Spec struct {
+			Versions []struct {
+				Schema struct {
+					OpenAPIV3Schema struct {
+						Properties map[string]struct {
+							Properties map[string]interface{} `json:"properties"`
+						} `json:"properties"`
+					} `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}{}
+	json.Unmarshal(api.objects["/apis/apiextensions.k8s.io/v1/customresourcedefinitions/mongoclusters.kubongo.io"], crd)
+	if len(crd.Spec.Versions) != 1 {
+		t.Fatalf("expected the CRD to be created with one version, got %+v", crd)
+	}
+	spec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties
+	if _, ok := spec["members"]; !ok {
+		t.Errorf("expected members in the spec schema, got %v", spec)
+	}
+	// kubongo can not choose a mongod version, so the api server must not accept one
+	if _, ok := spec["version"]; ok {
+		t.Error("expected no version in the spec schema")
+	}
+	if err := ctl.EnsureMongoClusterCRD(); err != nil || len(api.writes) != 1 {
+		t.Errorf("expected an existing CRD to be kept, got %v and writes %v", err, api.writes)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"log"
+
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+const servicePortName = "mongodb"
+
+var managedByLabels = map[string]string{"app.kubernetes.io/managed-by": "kubongo"}
+
+func (c *Controller) newService() *Service {
+	return &Service{
+		TypeMeta: TypeMeta{Kind: "Service", APIVersion: "v1"},
+		Metadata: ObjectMeta{
+			Name:      c.ServiceName,
+			Namespace: c.Namespace,
+			Labels:    managedByLabels,
+		},
+		Spec: ServiceSpec{
+			Ports: []ServicePort{{
+				Name:       servicePortName,
+				Protocol:   "TCP",
+				Port:       c.ServicePort,
+				TargetPort: c.ServicePort,
+			}},
+		},
+	}
+}
+
+// newEndpoints builds an Endpoints object with one address per instance that has an internal IP
+func (c *Controller) newEndpoints(instances metadata.Instances) *Endpoints {
+	addresses := []EndpointAddress{}
+	for i := range instances {
+		if instances[i] == nil {
+			continue
+		}
+		ip := instances[i].GetInternalIP()
+		if ip == "" {
+			continue
+		}
+		addresses = append(addresses, EndpointAddress{IP: ip})
+	}
+	endpoints := &Endpoints{
+		TypeMeta: TypeMeta{Kind: "Endpoints", APIVersion: "v1"},
+		Metadata: ObjectMeta{
+			Name:      c.ServiceName,
+			Namespace: c.Namespace,
+			Labels:    managedByLabels,
+		},
+		Subsets: []EndpointSubset{},
+	}
+	if len(addresses) > 0 {
+		endpoints.Subsets = append(endpoints.Subsets, EndpointSubset{
+			Addresses: addresses,
+			Ports: []EndpointPort{{
+				Name:     servicePortName,
+				Port:     c.ServicePort,
+				Protocol: "TCP",
+			}},
+		})
+	}
+	return endpoints
+}
+
+// EnsureService creates the selector-less mongo Service if it does not exist yet
+func (c *Controller) EnsureService() error {
+	path := c.namespacedPath("services", c.ServiceName)
+	existing := &Service{}
+	getErr := c.get(path, existing)
+	if getErr == nil {
+		return nil
+	}
+	if !IsNotFound(getErr) {
+		return getErr
+	}
+	log.Println("service:91 creating service", c.ServiceName, "in", c.Namespace)
+	createErr := c.create(c.namespacedPath("services", ""), c.newService(), nil)
+	if IsConflict(createErr) {
+		return nil
+	}
+	return createErr
+}
+
+// UpdateServiceEndPoint publishes every instance as an address of the mongo Service's Endpoints,
+// the Endpoints object is created if missing and otherwise rewritten in place
+func (c *Controller) UpdateServiceEndPoint(instances metadata.Instances) error {
+	serviceErr := c.EnsureService()
+	if serviceErr != nil {
+		return serviceErr
+	}
+	return c.upsert(c.namespacedPath("endpoints", ""), c.newEndpoints(instances))
+}
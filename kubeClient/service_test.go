@@ -0,0 +1,113 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+const (
+	testServicePath   = "/api/v1/namespaces/default/services/mongo"
+	testEndpointsPath = "/api/v1/namespaces/default/endpoints/mongo"
+)
+
+func TestEnsureServiceKeepsAnExistingService(t *testing.T) {
+	ctl, api, closer := newTestController(t)
+	defer closer()
+	api.objects[testServicePath] = []byte(`{"metadata":{"name":"mongo","resourceVersion":"3"}}`)
+	if err := ctl.EnsureService(); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.writes) != 0 {
+		t.Errorf("expected the existing service to be left alone, got %v", api.writes)
+	}
+}
+
+func TestEnsureServiceToleratesAConcurrentCreate(t *testing.T) {
+	ctl, api, closer := newTestController(t)
+	defer closer()
+	api.conflicts = 1
+	if err := ctl.EnsureService(); err != nil {
+		t.Errorf("expected a conflict on create to mean the service exists, got %v", err)
+	}
+}
+
+func TestUpdateServiceEndPointUpdatesInPlace(t *testing.T) {
+	ctl, api, closer := newTestController(t)
+	defer closer()
+	api.objects[testServicePath] = []byte(`{"metadata":{"name":"mongo"}}`)
+	api.objects[testEndpointsPath] = []byte(`{"metadata":{"name":"mongo","resourceVersion":"7"}}`)
+	if err := ctl.UpdateServiceEndPoint(testInstances()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(api.writes, []string{"PUT " + testEndpointsPath}) {
+		t.Errorf("expected the endpoints to be replaced, got %v", api.writes)
+	}
+	endpoints := &Endpoints{}
+	json.Unmarshal(api.objects[testEndpointsPath], endpoints)
+	if endpoints.Metadata.ResourceVersion != "7" {
+		t.Errorf("expected the update to carry resourceVersion 7, got %q", endpoints.Metadata.ResourceVersion)
+	}
+}
+
+func TestUpdateServiceEndPointRetriesConflicts(t *testing.T) {
+	ctl, api, closer := newTestController(t)
+	defer closer()
+	api.objects[testServicePath] = []byte(`{"metadata":{"name":"mongo"}}`)
+	api.objects[testEndpointsPath] = []byte(`{"metadata":{"name":"mongo","resourceVersion":"7"}}`)
+	api.conflicts = maxConflictRetries - 1
+	if err := ctl.UpdateServiceEndPoint(testInstances()); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.writes) != maxConflictRetries {
+		t.Errorf("expected %d attempts, got %v", maxConflictRetries, api.writes)
+	}
+	api.writes = nil
+	api.conflicts = maxConflictRetries
+	if err := ctl.UpdateServiceEndPoint(testInstances()); !IsConflict(err) {
+		t.Errorf("expected to give up with a conflict, got %v", err)
+	}
+	if len(api.writes) != maxConflictRetries {
+		t.Errorf("expected %d attempts, got %v", maxConflictRetries, api.writes)
+	}
+}
+
+func TestNewEndpointsSubsets(t *testing.T) {
+	ctl := New("localhost:8080", "default", "DB_CONNECT_STRING", "mongo")
+	ctl.ServicePort = 27018
+	endpoints := ctl.newEndpoints(metadata.Instances{
+		nil,
+		hostProvider.LocalInstance{Name: "a", IP: "10.0.0.1"},
+		hostProvider.LocalInstance{Name: "pending"},
+	})
+	if len(endpoints.Subsets) != 1 {
+		t.Fatalf("expected one subset, got %+v", endpoints.Subsets)
+	}
+	subset := endpoints.Subsets[0]
+	if !reflect.DeepEqual(subset.Addresses, []EndpointAddress{{IP: "10.0.0.1"}}) {
+		t.Errorf("expected only the instance with an ip, got %+v", subset.Addresses)
+	}
+	if len(subset.Ports) != 1 || subset.Ports[0].Port != 27018 || subset.Ports[0].Name != servicePortName {
+		t.Errorf("unexpected ports %+v", subset.Ports)
+	}
+	empty, _ := json.Marshal(ctl.newEndpoints(metadata.Instances{hostProvider.LocalInstance{Name: "pending"}}).Subsets)
+	if string(empty) != "[]" {
+		t.Errorf("expected no instances to publish an empty subset list, got %s", empty)
+	}
+}
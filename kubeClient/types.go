@@ -0,0 +1,142 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+// TypeMeta is the kind and api version of a Kubernetes object
+type TypeMeta struct {
+	Kind       string `json:"kind,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+// ObjectMeta is the metadata of a Kubernetes object
+type ObjectMeta struct {
+	Name              string            `json:"name,omitempty"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	Generation        int64             `json:"generation,omitempty"`
+	DeletionTimestamp string            `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string          `json:"finalizers,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+}
+
+// ListMeta is the metadata of a list of Kubernetes objects
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// ServicePort is a port exposed by a Service
+type ServicePort struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	Port       int    `json:"port"`
+	TargetPort int    `json:"targetPort,omitempty"`
+}
+
+// ServiceSpec is the spec of a Service, kubongo leaves Selector empty so Kubernetes does not manage the Endpoints
+type ServiceSpec struct {
+	Ports    []ServicePort     `json:"ports"`
+	Selector map[string]string `json:"selector,omitempty"`
+}
+
+// Service is a Kubernetes v1 Service
+type Service struct {
+	TypeMeta `json:",inline"`
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     ServiceSpec `json:"spec"`
+}
+
+// EndpointAddress is a single IP in an Endpoints subset
+type EndpointAddress struct {
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// EndpointPort is a port in an Endpoints subset, Name must match the Service's port name
+type EndpointPort struct {
+	Name     string `json:"name,omitempty"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// EndpointSubset is a group of addresses sharing the same ports
+type EndpointSubset struct {
+	Addresses         []EndpointAddress `json:"addresses,omitempty"`
+	NotReadyAddresses []EndpointAddress `json:"notReadyAddresses,omitempty"`
+	Ports             []EndpointPort    `json:"ports,omitempty"`
+}
+
+// Endpoints is a Kubernetes v1 Endpoints object
+type Endpoints struct {
+	TypeMeta `json:",inline"`
+	Metadata ObjectMeta       `json:"metadata"`
+	Subsets  []EndpointSubset `json:"subsets"`
+}
+
+func (s *Service) meta() *ObjectMeta { return &s.Metadata }
+
+func (e *Endpoints) meta() *ObjectMeta { return &e.Metadata }
+
+// ConfigMap is a Kubernetes v1 ConfigMap
+type ConfigMap struct {
+	TypeMeta `json:",inline"`
+	Metadata ObjectMeta        `json:"metadata"`
+	Data     map[string]string `json:"data"`
+}
+
+func (c *ConfigMap) meta() *ObjectMeta { return &c.Metadata }
+
+// Secret is a Kubernetes v1 Secret, StringData is written as is and stored base64 encoded by the api server
+type Secret struct {
+	TypeMeta   `json:",inline"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	StringData map[string]string `json:"stringData,omitempty"`
+}
+
+func (s *Secret) meta() *ObjectMeta { return &s.Metadata }
+
+// PodTemplate is the pod template of a workload, only its metadata is used by kubongo
+type PodTemplate struct {
+	Metadata ObjectMeta `json:"metadata"`
+}
+
+// WorkloadSpec is the part of a Deployment's or StatefulSet's spec kubongo reads and patches
+type WorkloadSpec struct {
+	Template PodTemplate `json:"template"`
+}
+
+// Workload is a Deployment or StatefulSet
+type Workload struct {
+	TypeMeta `json:",inline"`
+	Metadata ObjectMeta   `json:"metadata"`
+	Spec     WorkloadSpec `json:"spec"`
+}
+
+// WorkloadList is a list of Deployments or StatefulSets
+type WorkloadList struct {
+	TypeMeta `json:",inline"`
+	Metadata ListMeta   `json:"metadata"`
+	Items    []Workload `json:"items"`
+}
+
+// Status is the body the Kubernetes api returns for failed requests
+type Status struct {
+	TypeMeta `json:",inline"`
+	Status   string `json:"status,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Code     int    `json:"code,omitempty"`
+}
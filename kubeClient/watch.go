@@ -0,0 +1,126 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeClient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Watch event types sent by the Kubernetes api
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+	Error    = "ERROR"
+)
+
+// WatchEvent is a single event of a watch, Object is left raw so the caller can decode it into its own type
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Watcher streams WatchEvents from the api server until Stop is called or the connection ends
+type Watcher struct {
+	events   chan WatchEvent
+	body     io.ReadCloser
+	stopOnce sync.Once
+	mutex    sync.Mutex
+	stopped  bool
+	err      error
+}
+
+// watch starts a watch on the collection path from resourceVersion
+func (c *Controller) watch(path, resourceVersion string) (*Watcher, error) {
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "false")
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	watchURL := fmt.Sprintf("%s?%s", c.url(path), query.Encode())
+	req, reqErr := http.NewRequest("GET", watchURL, nil)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req.Header.Set("Accept", "application/json")
+	res, resErr := c.rawClient.Do(req)
+	if resErr != nil {
+		return nil, resErr
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		apiErr := &APIError{StatusCode: res.StatusCode, Method: "GET", URL: watchURL}
+		resBody, _ := ioutil.ReadAll(res.Body)
+		json.Unmarshal(resBody, &apiErr.Status)
+		return nil, apiErr
+	}
+	w := &Watcher{
+		events: make(chan WatchEvent),
+		body:   res.Body,
+	}
+	go w.receive()
+	return w, nil
+}
+
+func (w *Watcher) receive() {
+	defer close(w.events)
+	defer w.body.Close()
+	decoder := json.NewDecoder(w.body)
+	for {
+		event := WatchEvent{}
+		decodeErr := decoder.Decode(&event)
+		if decodeErr != nil {
+			w.mutex.Lock()
+			if decodeErr != io.EOF && !w.stopped {
+				w.err = decodeErr
+			}
+			w.mutex.Unlock()
+			return
+		}
+		w.events <- event
+	}
+}
+
+// ResultChan is closed when the watch ends
+func (w *Watcher) ResultChan() <-chan WatchEvent {
+	return w.events
+}
+
+// Err is why the watch ended, nil if the api server closed it cleanly or Stop was called
+func (w *Watcher) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// Stop ends the watch, any events still in flight are discarded
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		w.mutex.Lock()
+		w.stopped = true
+		w.mutex.Unlock()
+		w.body.Close()
+		go func() {
+			for range w.events {
+			}
+		}()
+	})
+}
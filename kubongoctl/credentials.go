@@ -0,0 +1,84 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cpg1111/kubongo/auth"
+)
+
+// Credentials are what kubongoctl presents to Kubongo's api server
+type Credentials struct {
+	// Token is sent as a bearer token, TokenFile is read for one if Token is empty
+	Token     string
+	TokenFile string
+	// CertFile and KeyFile are a client certificate, CAFile verifies the server
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// TLS talks https even without a CA or client certificate, trusting the system roots
+	TLS bool
+}
+
+// Scheme is https if any TLS setting is given and http otherwise
+func (c *Credentials) Scheme() string {
+	if c.TLS || c.CAFile != "" || c.CertFile != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// tokenTransport adds a bearer token to every request
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authed := new(http.Request)
+	*authed = *req
+	authed.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authed.Header[k] = v
+	}
+	authed.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(authed)
+}
+
+// Client returns an http client presenting the credentials
+func (c *Credentials) Client() (*http.Client, error) {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if c.Scheme() == "https" {
+		tlsConf, tlsErr := auth.ClientTLSConfig(c.CAFile, c.CertFile, c.KeyFile)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		transport.TLSClientConfig = tlsConf
+	}
+	token := c.Token
+	if token == "" && c.TokenFile != "" {
+		tokenBytes, readErr := ioutil.ReadFile(c.TokenFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		token = strings.TrimSpace(string(tokenBytes))
+	}
+	if token == "" {
+		return &http.Client{Transport: transport}, nil
+	}
+	return &http.Client{Transport: &tokenTransport{token: token, base: transport}}, nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestCredentialsPresentToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	tokenFile, _ := ioutil.TempFile(os.TempDir(), "kubongo-token")
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("file-token\n")
+	tokenFile.Close()
+
+	creds := &Credentials{TokenFile: tokenFile.Name()}
+	if creds.Scheme() != "http" {
+		t.Errorf("expected a token alone to talk http, got %s", creds.Scheme())
+	}
+	client, clientErr := creds.Client()
+	if clientErr != nil {
+		t.Fatal(clientErr)
+	}
+	res, resErr := client.Get(server.URL)
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	header, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(header) != "Bearer file-token" {
+		t.Errorf("expected the token file's token to be presented, got %q", header)
+	}
+	if (&Credentials{CAFile: "ca.crt"}).Scheme() != "https" {
+		t.Error("expected a CA to talk https")
+	}
+}
@@ -14,7 +14,6 @@ limitations under the License.
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -25,6 +24,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cpg1111/kubongo/client"
 	mongo "github.com/cpg1111/kubongo/mongoInstance"
 
 	yaml "gopkg.in/yaml.v2"
@@ -37,10 +37,22 @@ func printHelp() {
 	log.Println("USAGE")
 	log.Println("kubongoctl [options] ACTION [action arguments] TARGET | TARGET EDIT")
 	log.Println(" ")
+	log.Println("ACTIONS")
+	log.Println("info instances [ZONE NAME]", "List every instance, or get the one named NAME in ZONE")
+	log.Println("create instances FILE", "Create or register the instance described by a json or yaml FILE")
+	log.Println("delete instances ZONE NAME", "Delete the instance named NAME in ZONE")
+	log.Println("watch instances", "Print every change to the instances, their health and failovers")
+	log.Println(" ")
 	log.Println("OPTIONS")
 	log.Println("--platform-config", "./config.json", "Set the path to a json config for cloud platform, defaults to ./config.json")
 	log.Println("--port", "8888", "Set the port number that kubungo's api server is listening on, defaults to 8888")
 	log.Println("--host", "127.0.0.1", "Set the IP address of Kubongo's api server")
+	log.Println("--token", "$KUBONGO_TOKEN", "Set the bearer token to present to Kubongo's api server")
+	log.Println("--token-file", "", "Set a file to read the bearer token from")
+	log.Println("--client-cert", "$KUBONGO_CLIENT_CERT", "Set the client certificate to present, talks https")
+	log.Println("--client-key", "$KUBONGO_CLIENT_KEY", "Set the key of --client-cert")
+	log.Println("--ca-cert", "$KUBONGO_CA_CERT", "Set the CA that signed Kubongo's serving certificate, talks https")
+	log.Println("--tls", "false", "Talk https to Kubongo's api server trusting the system roots")
 	os.Exit(0)
 }
 
@@ -61,85 +73,118 @@ func DecodeInstanceFile(filename string, file []byte) (*mongo.InstanceTemplate,
 	return instance, err
 }
 
-// Create will send a post to the Specified endpoint to create the resource that corolates to the endpoint
-func Create(url string) (*http.Response, error) {
-	var instanceConf string
-	if len(os.Args) > 3 {
-		instanceConf = os.Args[3]
-		confInfo, confErr := os.Stat(instanceConf)
-		if confInfo != nil || confErr == nil {
-			confBytes, readErr := ioutil.ReadFile(instanceConf)
-			if readErr != nil {
-				return nil, errors.New("could not open file to create instance")
-			}
-			inst, instErr := DecodeInstanceFile(instanceConf, confBytes)
-			if instErr != nil {
-				return nil, instErr
-			}
-			instPayload, payloadErr := json.Marshal(inst)
-			if payloadErr != nil {
-				return nil, payloadErr
-			}
-			return http.Post(url, "json", bytes.NewBuffer(instPayload))
-		}
+// Create creates or registers the instance described by the file in os.Args[3] depending on its kind,
+// it returns the operation doing so
+func Create(c *client.Client) (*mongo.Operation, error) {
+	if len(os.Args) < 4 {
+		return nil, errors.New("no input given to create instance")
+	}
+	instanceConf := os.Args[3]
+	confBytes, readErr := ioutil.ReadFile(instanceConf)
+	if readErr != nil {
+		return nil, errors.New("could not open file to create instance")
 	}
-	return nil, errors.New("no input given to create instance")
+	inst, instErr := DecodeInstanceFile(instanceConf, confBytes)
+	if instErr != nil {
+		return nil, instErr
+	}
+	if inst.Kind == "Register" {
+		return c.RegisterInstance(inst.Zone, inst.Name, inst.Labels)
+	}
+	return c.CreateInstance(inst)
 }
 
-// Destroy will destroy a resource that corolates with the specified endpoint
-func Destroy(url string) (*http.Response, error) {
-	if len(os.Args) > 3 {
-		zoneName := os.Args[3]
-		if len(os.Args) > 4 {
-			instanceName := os.Args[3]
-			req, reqErr := http.NewRequest("DELETE", url, bytes.NewBuffer([]byte(fmt.Sprintf("{\"zone\":\"%s\", \"name\":\"%s\"}", zoneName, instanceName))))
-			if reqErr != nil {
-				return nil, reqErr
-			}
-			req.Header.Set("content-type", "json")
-			client := &http.Client{}
-			res, resErr := client.Do(req)
-			if resErr != nil {
-				return nil, resErr
-			}
-			return res, nil
-		}
+// Destroy deletes the instance in the zone in os.Args[3] named os.Args[4], it returns the operation doing so
+func Destroy(c *client.Client) (*mongo.Operation, error) {
+	if len(os.Args) < 4 {
+		return nil, errors.New("no instance zone given")
+	}
+	if len(os.Args) < 5 {
 		return nil, errors.New("no instance name given")
 	}
-	return nil, errors.New("no instance zone given")
+	return c.DeleteInstance(os.Args[3], os.Args[4])
 }
 
-// Request will send a request to the Kubongo server
-func Request(host, port, method, endpoint string) (res *http.Response, resErr error) {
-	targetURL := fmt.Sprintf("http://%s:%s/%s", host, port, endpoint)
-	switch method {
+// Info lists every instance, or the instance in the zone in os.Args[3] named os.Args[4]
+func Info(c *client.Client) (interface{}, error) {
+	if len(os.Args) > 4 {
+		return c.GetInstance(os.Args[3], os.Args[4])
+	}
+	return c.ListInstances()
+}
+
+// Watch prints every event of the instances until the server ends the watch
+func Watch(c *client.Client) error {
+	watcher, watchErr := c.Watch(0)
+	if watchErr != nil {
+		return watchErr
+	}
+	defer watcher.Stop()
+	encoder := json.NewEncoder(os.Stdout)
+	for event := range watcher.ResultChan() {
+		encoder.Encode(&event)
+	}
+	return watcher.Err()
+}
+
+// apiClient and apiScheme present the Credentials given on the command line
+var (
+	apiClient = http.DefaultClient
+	apiScheme = "http"
+)
+
+// Request runs action on the endpoint of the Kubongo server at host:port and returns what it answered
+func Request(host, port, action, endpoint string) (interface{}, error) {
+	if endpoint != "instances" {
+		return nil, fmt.Errorf("unknown endpoint %q, only instances is supported", endpoint)
+	}
+	c := client.New(fmt.Sprintf("%s://%s:%s", apiScheme, host, port), apiClient)
+	switch action {
 	case "info":
-		res, resErr = http.Get(targetURL)
-		break
+		return Info(c)
 	case "create":
-		res, resErr = Create(targetURL)
-		break
+		return Create(c)
 	case "delete":
-		res, resErr = Destroy(targetURL)
-		break
+		return Destroy(c)
+	case "watch":
+		return nil, Watch(c)
 	default:
-		res = nil
-		resErr = errors.New("Invalid Method")
-		break
+		return nil, errors.New("Invalid Method")
 	}
-	return
 }
 
 func main() {
 	var (
-		platConfPath = flag.String("-platform-config", "./config.json", "Set the path to a json config for cloud platform, defaults to ./config.json")
-		port         = flag.Int("-port", 8888, "Set the port number that kubungo's api server is listening on, defaults to 8888")
-		host         = flag.String("-host", "127.0.0.1", "Set the IP address of Kubongo's api server")
-		help         = flag.Bool("-help", false, "Prints info on Kubongoctl")
+		platConfPath = flag.String("platform-config", "./config.json", "Set the path to a json config for cloud platform, defaults to ./config.json")
+		port         = flag.Int("port", 8888, "Set the port number that kubungo's api server is listening on, defaults to 8888")
+		host         = flag.String("host", "127.0.0.1", "Set the IP address of Kubongo's api server")
+		token        = flag.String("token", os.Getenv("KUBONGO_TOKEN"), "Set the bearer token to present to Kubongo's api server, defaults to $KUBONGO_TOKEN")
+		tokenFile    = flag.String("token-file", "", "Set a file to read the bearer token from, defaults to empty")
+		clientCert   = flag.String("client-cert", os.Getenv("KUBONGO_CLIENT_CERT"), "Set the client certificate to present, defaults to $KUBONGO_CLIENT_CERT")
+		clientKey    = flag.String("client-key", os.Getenv("KUBONGO_CLIENT_KEY"), "Set the key of --client-cert, defaults to $KUBONGO_CLIENT_KEY")
+		caCert       = flag.String("ca-cert", os.Getenv("KUBONGO_CA_CERT"), "Set the CA that signed Kubongo's serving certificate, defaults to $KUBONGO_CA_CERT")
+		useTLS       = flag.Bool("tls", false, "Talk https to Kubongo's api server trusting the system roots, defaults to false")
+		help         = flag.Bool("help", false, "Prints info on Kubongoctl")
 	)
+	flag.Parse()
 	if *help {
 		printHelp()
 	}
+	// the actions read their arguments from os.Args, so drop the flags that were parsed
+	os.Args = append([]string{os.Args[0]}, flag.Args()...)
+	creds := &Credentials{
+		Token:     *token,
+		TokenFile: *tokenFile,
+		CertFile:  *clientCert,
+		KeyFile:   *clientKey,
+		CAFile:    *caCert,
+		TLS:       *useTLS,
+	}
+	client, clientErr := creds.Client()
+	if clientErr != nil {
+		log.Fatal(clientErr)
+	}
+	apiClient, apiScheme = client, creds.Scheme()
 	confInfo, confErr := os.Stat(*platConfPath)
 	log.Println(confInfo)
 	if confErr == nil {
@@ -157,5 +202,12 @@ func main() {
 	if len(os.Args) > 2 {
 		endpoint = os.Args[2]
 	}
-	log.Println(Request(*host, fmt.Sprintf("%v", *port), method, endpoint))
+	result, reqErr := Request(*host, fmt.Sprintf("%v", *port), method, endpoint)
+	if reqErr != nil {
+		log.Fatal(reqErr)
+	}
+	if result != nil {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
 }
@@ -14,48 +14,268 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/auth"
+	"github.com/cpg1111/kubongo/grpcAPI"
+	"github.com/cpg1111/kubongo/health"
 	kube "github.com/cpg1111/kubongo/kubeClient"
 	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/metrics"
 	mongo "github.com/cpg1111/kubongo/mongoInstance"
+	"github.com/cpg1111/kubongo/mongoWire"
+	"github.com/cpg1111/kubongo/notify"
+	"github.com/cpg1111/kubongo/openapi"
+	"github.com/cpg1111/kubongo/operator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
 	var (
-		platform        = flag.String("-platform", "local", "Set which cloud platform to use, defaults to gcloud")
-		project         = flag.String("-project", "", "Set which project/organization to use, defaults to empty")
-		platConfPath    = flag.String("-platform-config", "./config.json", "Set the path to a json config for cloud platform, defaults to ./config.json")
-		port            = flag.Int("-port", 8888, "Set the port number for kubungo's api server to listen on, defaults to 8888")
-		kubeEnvVarName  = flag.String("-kube-env-var-name", "DB_CONNECT_STRING", "Set the environment variable name for mongo's service discovery in Kubernetes, defaults to \"DB_CONNECT_STRING\"")
-		kubeNamespace   = flag.String("-kube-namespace", "default", "set the Kubernetes namespace to update with the mongo endpoint, defaults to \"default\"")
-		initKubeMaster  = flag.String("init-kube-master", "127.0.0.1:8080", "Set the IP address and port of the Kubernetes master, defaults to 127.0.0.1:8080")
+		platform        = flag.String("platform", "local", "Set which cloud platform to use, defaults to gcloud")
+		project         = flag.String("project", "", "Set which project/organization to use, defaults to empty")
+		platConfPath    = flag.String("platform-config", "./config.json", "Set the path to a json config for cloud platform, defaults to ./config.json")
+		port            = flag.Int("port", 8888, "Set the port number for kubungo's api server to listen on, defaults to 8888")
+		kubeEnvVarName  = flag.String("kube-env-var-name", "DB_CONNECT_STRING", "Set the environment variable name for mongo's service discovery in Kubernetes, defaults to \"DB_CONNECT_STRING\"")
+		kubeNamespace   = flag.String("kube-namespace", "", "set the Kubernetes namespace to update with the mongo endpoint, defaults to the kubeconfig context's or service account's namespace, or \"default\"")
+		kubeAuth        = flag.String("kube-auth", "insecure", "Set how to authenticate with Kubernetes, one of \"insecure\", \"in-cluster\" or \"kubeconfig\", defaults to \"insecure\"")
+		kubeconfig      = flag.String("kubeconfig", os.ExpandEnv("$HOME/.kube/config"), "Set the path to the kubeconfig used when --kube-auth is \"kubeconfig\", defaults to $HOME/.kube/config")
+		kubeContext     = flag.String("kube-context", "", "Set the kubeconfig context to use, defaults to the current-context")
+		kubeServiceName = flag.String("kube-service-name", "mongo", "Set the name of the Kubernetes Service and Endpoints that mongo is published under, defaults to \"mongo\"")
+		kubeConsumes    = flag.String("kube-consumes-annotation", kube.DefaultConsumesAnnotation, "Set the annotation marking Deployments and StatefulSets to restart when the connection string changes, defaults to \"kubongo.io/consumes\"")
+		mongoReplSet    = flag.String("mongo-replica-set", "", "Set the replica set created instances join and that is written into the connection string, defaults to empty for standalone instances")
+		mongoReadPref   = flag.String("mongo-read-preference", "primary", "Set the read preference written into the connection string, defaults to \"primary\"")
+		mongoDatabase   = flag.String("mongo-database", "", "Set the database written into the connection string, defaults to empty")
+		mongoUser       = flag.String("mongo-username", "", "Set the user written into the connection string, the password is read from $MONGO_PASSWORD, defaults to empty")
+		mongoAdminUser  = flag.String("mongo-admin-username", "", "Set the user kubongo authenticates as with SCRAM-SHA-256 to manage mongod, the password is read from $MONGO_ADMIN_PASSWORD, defaults to empty for mongods without auth")
+		mongoAdminDB    = flag.String("mongo-admin-source", "admin", "Set the database --mongo-admin-username is defined in, defaults to \"admin\"")
+		initKubeMaster  = flag.String("init-kube-master", "127.0.0.1:8080", "Set the IP address and port of the Kubernetes master when --kube-auth is \"insecure\", defaults to 127.0.0.1:8080")
 		initMongoMaster = flag.String("init-mongo-master", "127.0.0.1:27017", "Set the IP address and port of the master mongod or mongos for monitoring, default is 127.0.0.1:27017")
-		masterZone      = flag.String("-master-zone", "local", "Set default zone/region for master mongo instance, default is us-central1-f")
-		help            = flag.Bool("-help", false, "Prints info on Kubongo")
+		masterZone      = flag.String("master-zone", "local", "Set default zone/region for master mongo instance, default is us-central1-f")
+		probeInterval   = flag.Duration("probe-interval", 3*time.Second, "Set how often every registered instance is health checked, defaults to 3s")
+		probeTimeout    = flag.Duration("probe-timeout", mongo.DefaultHealthCheckTimeout, "Set how long a single health check may take, defaults to 3s")
+		probeFailures   = flag.Int("probe-failure-threshold", 3, "Set how many consecutive failed health checks mark an instance down, defaults to 3")
+		probeSuccesses  = flag.Int("probe-success-threshold", 1, "Set how many consecutive passed health checks mark a failed instance healthy again, defaults to 1")
+		manualFailover  = flag.Bool("failover-manual-only", false, "Never fail over automatically, only on POST /failover, defaults to false")
+		failoverMinGap  = flag.Duration("failover-min-interval", 5*time.Minute, "Set the least time between two automatic failover attempts, executed or failed, defaults to 5m")
+		failoverMax     = flag.Int("failover-max", 3, "Set the most automatic failover attempts per --failover-window, 0 is no limit, defaults to 3")
+		failoverWindow  = flag.Duration("failover-window", time.Hour, "Set the window --failover-max applies to, defaults to 1h")
+		maxLag          = flag.Duration("max-replication-lag", 30*time.Second, "Set how far a secondary may be behind the primary before it is lagging, lagging secondaries are left out of secondary reads and never promoted, 0 disables it, defaults to 30s")
+		minOplogRoom    = flag.Duration("min-oplog-headroom", time.Hour, "Set how much of the primary's oplog window a secondary's lag must leave to spare before it is lagging, 0 disables it, defaults to 1h")
+		failoverQuorum  = flag.Bool("failover-quorum", true, "Only fail over when a strict majority of the replica set's votes see the primary down, defaults to true")
+		statePath       = flag.String("state-path", "./kubongo-registry.json", "Set the file registered instances are persisted to and restored from on start, empty keeps them in memory only, defaults to ./kubongo-registry.json")
+		tlsCert         = flag.String("tls-cert", "", "Set the certificate to serve the api over TLS with, defaults to empty for plain HTTP")
+		tlsKey          = flag.String("tls-key", "", "Set the private key of --tls-cert, defaults to empty")
+		tlsClientCA     = flag.String("tls-client-ca", "", "Set the CA that signs client certificates, their CN is the user and O the groups, defaults to empty for no client certificates")
+		authTokenFile   = flag.String("auth-token-file", "", "Set the file of bearer tokens as token,user,uid,\"group1,group2\" lines, defaults to empty")
+		authPolicy      = flag.String("auth-policy", "", "Set the RBAC policy file granting users and groups verbs on resources, defaults to empty which leaves the api open")
+		auditPath       = flag.String("audit-path", "./kubongo-audit.log", "Set the file mutating api calls and the manager's actions are audited to as JSON lines, empty disables auditing, defaults to ./kubongo-audit.log")
+		auditMaxSize    = flag.Int64("audit-max-size", audit.DefaultMaxSize, "Set the size in bytes the audit file is rotated at, defaults to 10MB")
+		auditBackups    = flag.Int("audit-max-backups", audit.DefaultMaxBackups, "Set how many rotated audit files are kept, defaults to 5")
+		notifyConfig    = flag.String("notify-config", "", "Set the YAML or JSON file of webhook, Slack and SMTP sinks told about instances going down and recovering, failovers and provider errors, defaults to empty which notifies no one")
+		grpcPort        = flag.Int("grpc-port", 8889, "Set the port number for kubongo's gRPC api to listen on, 0 disables it, defaults to 8889")
+		operatorMode    = flag.Bool("operator", false, "Registers the MongoCluster CRD and converges MongoCluster objects in --kube-namespace alongside the api server, defaults to false")
+		help            = flag.Bool("help", false, "Prints info on Kubongo")
 	)
 	flag.Parse()
 	if *help {
 		flag.PrintDefaults()
 	}
+	log.Println("main:93 kubongo", health.Version, "commit", health.Commit, "built", health.BuildDate)
+	if *mongoAdminUser != "" {
+		mongo.AdminCredential = &mongoWire.Credential{Username: *mongoAdminUser, Password: os.Getenv("MONGO_ADMIN_PASSWORD"), Source: *mongoAdminDB}
+	}
 	portNum := fmt.Sprintf(":%v", *port)
 	server := http.NewServeMux()
-	instances := metadata.New(nil)
-	mongoHandler := mongo.NewHandler(*platform, *project, *platConfPath, *instances)
+	registry := metadata.NewRegistry()
+	if *statePath != "" {
+		var regErr error
+		registry, regErr = metadata.OpenRegistry(metadata.NewFileStore(*statePath))
+		if regErr != nil {
+			log.Fatal(regErr)
+		}
+		log.Println("main:106 restored", registry.Len(), "instances from", *statePath)
+	}
+	mongoHandler := mongo.NewHandler(*platform, *project, *platConfPath, registry)
+	var auditLog *audit.Log
+	if *auditPath != "" {
+		auditLog = &audit.Log{Path: *auditPath, MaxSize: *auditMaxSize, MaxBackups: *auditBackups}
+		mongoHandler.Manager.Audit = auditLog
+	}
+	if *notifyConfig != "" {
+		notifier, notifyErr := notify.LoadConfig(*notifyConfig)
+		if notifyErr != nil {
+			log.Fatal(notifyErr)
+		}
+		mongoHandler.Manager.Notifier = notifier
+	}
 	server.Handle("/instances", mongoHandler)
-	log.Println("main:49 Kubongo Process started and is listening on port", *port)
-	kubeClient := kube.New(*initKubeMaster, *kubeNamespace, *kubeEnvVarName)
+	server.Handle("/v1/instances", mongoHandler)
+	server.Handle("/v1/instances/", mongoHandler)
+	server.Handle("/failover", &mongo.FailoverHandler{Manager: mongoHandler.Manager})
+	operationsHandler := &mongo.OperationsHandler{Manager: mongoHandler.Manager}
+	server.Handle("/v1/operations", operationsHandler)
+	server.Handle("/v1/operations/", operationsHandler)
+	server.Handle("/v1/audit", &audit.QueryHandler{Log: auditLog})
+	server.Handle(openapi.Path, openapi.NewHandler(openapi.Kubongo()))
+	readiness := health.NewReadiness()
+	readiness.Add("platform", mongoHandler.Manager.PingPlatform)
+	readiness.Add("registry", func() error {
+		if !mongoHandler.Manager.Reconciled() {
+			return errors.New("the registry has not been restored and reconciled yet")
+		}
+		return nil
+	})
+	server.HandleFunc(health.LivenessPath, health.Liveness)
+	server.Handle(health.ReadinessPath, readiness)
+	server.HandleFunc(health.VersionPath, health.VersionHandler)
+	metrics.Register(mongoHandler.Manager.Collector())
+	server.Handle(metrics.Path, metrics.Default)
+	if *tlsCert == "" && (*tlsKey != "" || *tlsClientCA != "") {
+		log.Fatal("--tls-key and --tls-client-ca need --tls-cert, without it the api would be served over plain HTTP")
+	}
+	if *authTokenFile != "" && *tlsCert == "" {
+		log.Println("main:147 --auth-token-file is used without --tls-cert, bearer tokens will cross the network in plain text")
+	}
+	var apiHandler http.Handler = server
+	var authenticator *auth.Authenticator
+	rpcServer := &grpcAPI.Server{Manager: mongoHandler.Manager, Audit: auditLog}
+	if *authPolicy != "" {
+		policy, policyErr := auth.LoadPolicy(*authPolicy)
+		if policyErr != nil {
+			log.Fatal(policyErr)
+		}
+		authenticator = auth.NewAuthenticator()
+		if *authTokenFile != "" {
+			tokenErr := authenticator.LoadTokenFile(*authTokenFile)
+			if tokenErr != nil {
+				log.Fatal(tokenErr)
+			}
+		}
+		apiHandler = &auth.Handler{Authenticator: authenticator, Policy: policy, Next: server, Public: map[string]bool{
+			openapi.Path:         true,
+			health.LivenessPath:  true,
+			health.ReadinessPath: true,
+			health.VersionPath:   true,
+		}}
+		rpcServer.Authenticator, rpcServer.Policy = authenticator, policy
+	} else if *authTokenFile != "" || *tlsClientCA != "" {
+		log.Fatal("--auth-token-file and --tls-client-ca authenticate users but without --auth-policy nothing is authorized, the api would be open to anyone")
+	} else {
+		log.Println("main:174 no --auth-policy was given, anyone who can reach the api can create and delete instances")
+	}
+	if auditLog != nil {
+		// outside of auth so that denied requests are audited too
+		apiHandler = &audit.Handler{Log: auditLog, Next: apiHandler, Actor: func(req *http.Request) string {
+			if identity, ok := auth.IdentityOf(req); ok && identity.Name != auth.Anonymous {
+				return identity.Name
+			}
+			return ""
+		}}
+	}
+	if authenticator != nil {
+		// authenticated before auditing so the audit log and the auth.Handler share the identity
+		apiHandler = &auth.Identifier{Authenticator: authenticator, Next: apiHandler}
+	}
+	apiHandler = &metrics.Handler{Next: apiHandler, Route: func(req *http.Request) string {
+		_, pattern := server.Handler(req)
+		return pattern
+	}}
+	apiServer := &http.Server{Addr: portNum, Handler: apiHandler}
+	if *tlsCert != "" {
+		tlsConf, tlsErr := auth.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+		if tlsErr != nil {
+			log.Fatal(tlsErr)
+		}
+		apiServer.TLSConfig = tlsConf
+	}
+	log.Println("main:201 Kubongo Process started and is listening on port", *port)
+	var (
+		kubeConf    *kube.Config
+		kubeConfErr error
+	)
+	switch *kubeAuth {
+	case "in-cluster":
+		kubeConf, kubeConfErr = kube.InClusterConfig()
+	case "kubeconfig":
+		kubeConf, kubeConfErr = kube.LoadKubeconfig(*kubeconfig, *kubeContext)
+	case "insecure":
+		kubeConf = kube.InsecureConfig(*initKubeMaster)
+	default:
+		kubeConfErr = fmt.Errorf("unknown --kube-auth %s", *kubeAuth)
+	}
+	if kubeConfErr != nil {
+		log.Fatal(kubeConfErr)
+	}
+	kubeClient, kubeErr := kube.NewWithConfig(kubeConf, *kubeNamespace, *kubeEnvVarName, *kubeServiceName)
+	if kubeErr != nil {
+		log.Fatal(kubeErr)
+	}
+	kubeClient.ConsumesAnnotation = *kubeConsumes
+	kubeClient.Connection = kube.ConnectionConfig{
+		ReplicaSet:     *mongoReplSet,
+		ReadPreference: *mongoReadPref,
+		Database:       *mongoDatabase,
+		Username:       *mongoUser,
+		Password:       os.Getenv("MONGO_PASSWORD"),
+	}
 	pingErr := kubeClient.Ping()
 	if pingErr != nil {
 		log.Fatal(pingErr)
 	}
+	readiness.Add("kubernetes", kubeClient.Ping)
 	mongoHandler.Manager.SetKubeCtl(kubeClient)
-	log.Println("main:56 Registering", *initMongoMaster)
-	mongoHandler.Manager.Register(*masterZone, "master", instances)
-	log.Println("main:58 monitoring", *initMongoMaster)
-	mongoHandler.Manager.Monitor(initMongoMaster, instances)
-	log.Fatal(http.ListenAndServe(portNum, server))
+	mongoHandler.Manager.ReplicaSet = *mongoReplSet
+	if *operatorMode {
+		op := operator.New(kubeClient, mongoHandler.Manager)
+		go func() {
+			log.Fatal(op.Run(nil))
+		}()
+	}
+	mongoHandler.Manager.Reconcile()
+	log.Println("main:245 Registering", *initMongoMaster)
+	mongoHandler.Manager.Register(*masterZone, "master")
+	log.Println("main:247 monitoring", *initMongoMaster)
+	mongoHandler.Manager.Prober.SetConfig(mongo.ProbeConfig{
+		Interval:         *probeInterval,
+		Timeout:          *probeTimeout,
+		FailureThreshold: *probeFailures,
+		SuccessThreshold: *probeSuccesses,
+	})
+	mongoHandler.Manager.Policy = mongo.FailoverPolicy{
+		ManualOnly:    *manualFailover,
+		MinInterval:   *failoverMinGap,
+		MaxFailovers:  *failoverMax,
+		Window:        *failoverWindow,
+		RequireQuorum: *failoverQuorum,
+	}
+	mongoHandler.Manager.LagThresholds = mongo.LagThresholds{MaxLag: *maxLag, MinOplogHeadroom: *minOplogRoom}
+	go mongoHandler.Manager.Monitor(initMongoMaster)
+	if *grpcPort != 0 {
+		var rpcOpts []grpc.ServerOption
+		if apiServer.TLSConfig != nil {
+			rpcOpts = append(rpcOpts, grpc.Creds(credentials.NewTLS(apiServer.TLSConfig)))
+		}
+		rpcListener, listenErr := net.Listen("tcp", fmt.Sprintf(":%v", *grpcPort))
+		if listenErr != nil {
+			log.Fatal(listenErr)
+		}
+		log.Println("main:272 gRPC api is listening on port", *grpcPort)
+		go func() {
+			log.Fatal(rpcServer.GRPCServer(rpcOpts...).Serve(rpcListener))
+		}()
+	}
+	if apiServer.TLSConfig != nil {
+		log.Fatal(apiServer.ListenAndServeTLS("", ""))
+	}
+	log.Fatal(apiServer.ListenAndServe())
 }
@@ -14,54 +14,45 @@ limitations under the License.
 package metadata
 
 import (
-	"fmt"
-
 	"github.com/cpg1111/kubongo/hostProvider"
 )
 
 // Instances is a slice of instances
 type Instances []hostProvider.Instance
 
-// ToMap converts slice of instances to a map of instances
-func (inst Instances) ToMap() (instanceMap map[string]hostProvider.Instance) {
-	for i := range inst {
-		castInst := inst[i].(hostProvider.LocalInstance)
-		instanceMap[fmt.Sprintf("%v", castInst.Name)] = inst[i]
-	}
-	return
+// MemberOptions are an instance's settings in its replica set config, nil Priority and Votes leave mongod's defaults
+type MemberOptions struct {
+	Priority    *float64          `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Votes       *int              `json:"votes,omitempty" yaml:"votes,omitempty"`
+	Hidden      bool              `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	ArbiterOnly bool              `json:"arbiterOnly,omitempty" yaml:"arbiterOnly,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
 }
 
-var current *Instances
-
-// New creates a new Instance slice
-func New(firstInstance *hostProvider.Instance) *Instances {
-	if current != nil {
-		return current
-	}
-	if firstInstance != nil {
-		current = &Instances{*firstInstance}
-		return current
-	}
-	current = &Instances{}
-	return current
+// Origin is the machine type, image and source kubongo created an instance from
+type Origin struct {
+	MachineType string `json:"machineType,omitempty"`
+	SourceImage string `json:"sourceImage,omitempty"`
+	Source      string `json:"source,omitempty"`
 }
 
-// AddInstance will add an instance to the Instances slice
-func AddInstance(list *Instances, instance hostProvider.Instance) *Instances {
-	newList := append(*list, instance)
-	list = &newList
-	return list
+// Member is an instance that belongs to a replica set, along with its place in it
+type Member struct {
+	hostProvider.Instance `json:"instance"`
+	ReplicaSet            string        `json:"replicaSet"`
+	MemberID              int           `json:"memberId"`
+	Host                  string        `json:"host"`
+	Role                  string        `json:"role"`
+	Options               MemberOptions `json:"options"`
+	// Origin is empty for an instance that was registered rather than created
+	Origin Origin `json:"origin"`
 }
 
-// RemoveInstance will remove an instance from the slice
-func RemoveInstance(list Instances, instance hostProvider.Instance) Instances {
-	newList := make(Instances, len(list)-1)
-	for i := range list {
-		castInst := list[i].(hostProvider.LocalInstance)
-		castOther := instance.(hostProvider.LocalInstance)
-		if castInst.Name != castOther.Name {
-			newList[i] = list[i]
-		}
+// ToMap converts slice of instances to a map of instances keyed by name
+func (inst Instances) ToMap() map[string]hostProvider.Instance {
+	instanceMap := make(map[string]hostProvider.Instance)
+	for i := range inst {
+		instanceMap[inst[i].GetName()] = inst[i]
 	}
-	return newList
+	return instanceMap
 }
@@ -0,0 +1,387 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+// NotFoundError is returned for an instance name that is not registered
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("instance %s is not registered", e.Name)
+}
+
+// ConflictError is returned when registering a name that is already registered
+type ConflictError struct {
+	Name string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("instance %s is already registered", e.Name)
+}
+
+// IsNotFound returns true if err is a *NotFoundError
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+// IsConflict returns true if err is a *ConflictError
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+var errNoName = errors.New("cannot register an instance without a name")
+
+// RoleOf returns the replica set role of a Member, or "" for any other instance
+func RoleOf(instance hostProvider.Instance) string {
+	if member, ok := instance.(*Member); ok {
+		return member.Role
+	}
+	return ""
+}
+
+type entry struct {
+	instance hostProvider.Instance
+	labels   map[string]string
+}
+
+// index maps a zone, role or label to the names of the instances with it
+type index map[string]map[string]bool
+
+func (idx index) add(key, name string) {
+	if idx[key] == nil {
+		idx[key] = make(map[string]bool)
+	}
+	idx[key][name] = true
+}
+
+func (idx index) remove(key, name string) {
+	delete(idx[key], name)
+	if len(idx[key]) == 0 {
+		delete(idx, key)
+	}
+}
+
+func labelKey(key, value string) string {
+	return key + "=" + value
+}
+
+// Registry holds every instance kubongo manages, keyed by name and indexed by zone, role and labels,
+// it is safe to use from multiple goroutines. Instances must be treated as immutable once registered,
+// change one by passing a changed copy to Update.
+type Registry struct {
+	mutex   sync.RWMutex
+	entries map[string]*entry
+	zones   index
+	roles   index
+	labels  index
+	store   Store
+	// resourceVersion counts every change, history holds the latest events for watchers
+	resourceVersion uint64
+	history         []Event
+	watchers        map[*Watcher]bool
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		entries:  make(map[string]*entry),
+		zones:    make(index),
+		roles:    make(index),
+		labels:   make(index),
+		watchers: make(map[*Watcher]bool),
+	}
+}
+
+// OpenRegistry creates a Registry holding the instances in store, every change to it is saved back to store
+func OpenRegistry(store Store) (*Registry, error) {
+	r := NewRegistry()
+	records, resourceVersion, loadErr := store.Load()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	for i := range records {
+		instance, decodeErr := DecodeInstance(records[i])
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		addErr := r.Add(instance, records[i].Labels)
+		if addErr != nil {
+			return nil, addErr
+		}
+	}
+	// carry on from the saved resource version, so versions handed out before a restart are too old to watch from
+	if resourceVersion > r.resourceVersion {
+		r.resourceVersion = resourceVersion
+	}
+	r.history = nil
+	r.store = store
+	return r, nil
+}
+
+// persist saves every entry and the resource version the next change gets to the store, the caller holds the write lock
+func (r *Registry) persist() error {
+	if r.store == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	records := make([]Record, 0, len(names))
+	for _, name := range names {
+		record, encodeErr := EncodeInstance(r.entries[name].instance, r.entries[name].labels)
+		if encodeErr != nil {
+			log.Println("registry:159 not persisting", name, encodeErr)
+			continue
+		}
+		records = append(records, record)
+	}
+	saveErr := r.store.Save(records, r.resourceVersion+1)
+	if saveErr != nil {
+		log.Println("registry:166 could not persist the registry:", saveErr)
+		return fmt.Errorf("could not persist the registry: %v", saveErr)
+	}
+	return nil
+}
+
+// commit persists a change the caller holds the write lock for and emits it, if it cannot be saved undo reverts it
+func (r *Registry) commit(eventType, name string, e *entry, undo func()) error {
+	saveErr := r.persist()
+	if saveErr != nil {
+		undo()
+		return saveErr
+	}
+	r.emit(eventType, name, e)
+	return nil
+}
+
+func (r *Registry) indexEntry(name string, e *entry) {
+	r.zones.add(e.instance.GetZone(), name)
+	r.roles.add(RoleOf(e.instance), name)
+	for k, v := range e.labels {
+		r.labels.add(labelKey(k, v), name)
+	}
+}
+
+func (r *Registry) unindexEntry(name string, e *entry) {
+	r.zones.remove(e.instance.GetZone(), name)
+	r.roles.remove(RoleOf(e.instance), name)
+	for k, v := range e.labels {
+		r.labels.remove(labelKey(k, v), name)
+	}
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+// Add registers instance under its name with labels
+func (r *Registry) Add(instance hostProvider.Instance, labels map[string]string) error {
+	name := instance.GetName()
+	if name == "" {
+		return errNoName
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.entries[name]; exists {
+		return &ConflictError{Name: name}
+	}
+	e := &entry{instance: instance, labels: copyLabels(labels)}
+	r.entries[name] = e
+	r.indexEntry(name, e)
+	return r.commit(Added, name, e, func() {
+		r.unindexEntry(name, e)
+		delete(r.entries, name)
+	})
+}
+
+// Get returns the instance registered as name
+func (r *Registry) Get(name string) (hostProvider.Instance, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return e.instance, true
+}
+
+// Labels returns the labels of the instance registered as name
+func (r *Registry) Labels(name string) (map[string]string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return copyLabels(e.labels), true
+}
+
+// Update replaces the registered instance with the same name, keeping its labels
+func (r *Registry) Update(instance hostProvider.Instance) error {
+	name := instance.GetName()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return &NotFoundError{Name: name}
+	}
+	previous := e.instance
+	r.unindexEntry(name, e)
+	e.instance = instance
+	r.indexEntry(name, e)
+	return r.commit(Modified, name, e, func() {
+		r.unindexEntry(name, e)
+		e.instance = previous
+		r.indexEntry(name, e)
+	})
+}
+
+// SetLabels replaces the labels of the instance registered as name
+func (r *Registry) SetLabels(name string, labels map[string]string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return &NotFoundError{Name: name}
+	}
+	previous := e.labels
+	r.unindexEntry(name, e)
+	e.labels = copyLabels(labels)
+	r.indexEntry(name, e)
+	return r.commit(Modified, name, e, func() {
+		r.unindexEntry(name, e)
+		e.labels = previous
+		r.indexEntry(name, e)
+	})
+}
+
+// Remove unregisters the instance registered as name and returns it
+func (r *Registry) Remove(name string) (hostProvider.Instance, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, &NotFoundError{Name: name}
+	}
+	r.unindexEntry(name, e)
+	delete(r.entries, name)
+	saveErr := r.commit(Deleted, name, e, func() {
+		r.entries[name] = e
+		r.indexEntry(name, e)
+	})
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	return e.instance, nil
+}
+
+// Len returns the number of registered instances
+func (r *Registry) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.entries)
+}
+
+// collect returns the instances called names sorted by name, the caller holds the lock
+func (r *Registry) collect(names map[string]bool) Instances {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	instances := make(Instances, len(sorted))
+	for i, name := range sorted {
+		instances[i] = r.entries[name].instance
+	}
+	return instances
+}
+
+// allNames returns the set of registered names, the caller holds the lock
+func (r *Registry) allNames() map[string]bool {
+	names := make(map[string]bool, len(r.entries))
+	for name := range r.entries {
+		names[name] = true
+	}
+	return names
+}
+
+// List returns every registered instance sorted by name
+func (r *Registry) List() Instances {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.collect(r.allNames())
+}
+
+// Zones returns every zone with a registered instance, sorted
+func (r *Registry) Zones() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	zones := make([]string, 0, len(r.zones))
+	for zone := range r.zones {
+		if zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// ByZone returns the instances in zone sorted by name
+func (r *Registry) ByZone(zone string) Instances {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.collect(r.zones[zone])
+}
+
+// ByRole returns the replica set members with role sorted by name
+func (r *Registry) ByRole(role string) Instances {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.collect(r.roles[role])
+}
+
+// ByLabels returns the instances that have every label in selector sorted by name, an empty selector matches all
+func (r *Registry) ByLabels(selector map[string]string) Instances {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	matches := r.allNames()
+	for k, v := range selector {
+		withLabel := r.labels[labelKey(k, v)]
+		for name := range matches {
+			if !withLabel[name] {
+				delete(matches, name)
+			}
+		}
+	}
+	return r.collect(matches)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+func names(instances Instances) []string {
+	found := []string{}
+	for _, instance := range instances {
+		found = append(found, instance.GetName())
+	}
+	return found
+}
+
+func TestRegistryLookups(t *testing.T) {
+	registry := NewRegistry()
+	gce := hostProvider.GcloudInstance{Name: "db-0", Zone: "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-f"}
+	primary := &Member{Instance: gce, ReplicaSet: "rs0", Role: "primary"}
+	local := &hostProvider.LocalInstance{Name: "db-1", Zone: "local"}
+	if addErr := registry.Add(primary, map[string]string{"tier": "db"}); addErr != nil {
+		t.Fatal(addErr)
+	}
+	if addErr := registry.Add(local, map[string]string{"tier": "db", "env": "dev"}); addErr != nil {
+		t.Fatal(addErr)
+	}
+	if addErr := registry.Add(hostProvider.LocalInstance{Name: "db-1"}, nil); !IsConflict(addErr) {
+		t.Errorf("expected adding db-1 twice to conflict, got %v", addErr)
+	}
+
+	if got := fmt.Sprint(names(registry.ByZone("us-central1-f"))); got != "[db-0]" {
+		t.Errorf("expected db-0 in us-central1-f, got %s", got)
+	}
+	if got := fmt.Sprint(names(registry.ByRole("primary"))); got != "[db-0]" {
+		t.Errorf("expected db-0 to be primary, got %s", got)
+	}
+	if got := fmt.Sprint(names(registry.ByLabels(map[string]string{"tier": "db"}))); got != "[db-0 db-1]" {
+		t.Errorf("expected both instances with tier=db, got %s", got)
+	}
+	if got := fmt.Sprint(names(registry.ByLabels(map[string]string{"tier": "db", "env": "dev"}))); got != "[db-1]" {
+		t.Errorf("expected db-1 with tier=db,env=dev, got %s", got)
+	}
+	if got := fmt.Sprint(registry.Zones()); got != "[local us-central1-f]" {
+		t.Errorf("expected zones local and us-central1-f, got %s", got)
+	}
+
+	demoted := *primary
+	demoted.Role = "secondary"
+	if updateErr := registry.Update(&demoted); updateErr != nil {
+		t.Fatal(updateErr)
+	}
+	if len(registry.ByRole("primary")) != 0 || len(registry.ByRole("secondary")) != 1 {
+		t.Error("expected the role index to follow the update")
+	}
+	if labels, _ := registry.Labels("db-0"); labels["tier"] != "db" {
+		t.Errorf("expected update to keep labels, got %v", labels)
+	}
+
+	if _, removeErr := registry.Remove("db-0"); removeErr != nil {
+		t.Fatal(removeErr)
+	}
+	if _, removeErr := registry.Remove("db-0"); !IsNotFound(removeErr) {
+		t.Errorf("expected removing db-0 twice to be not found, got %v", removeErr)
+	}
+	if got := fmt.Sprint(names(registry.List())); got != "[db-1]" {
+		t.Errorf("expected only db-1 left, got %s", got)
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	registry := NewRegistry()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("db-%d", i)
+			registry.Add(&hostProvider.LocalInstance{Name: name, Zone: "local"}, nil)
+			registry.List()
+			registry.ByZone("local")
+			if i%2 == 0 {
+				registry.Remove(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if registry.Len() != 10 {
+		t.Errorf("expected 10 instances left, got %d", registry.Len())
+	}
+}
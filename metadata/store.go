@@ -0,0 +1,218 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+// SchemaVersion is the version of the persisted registry format written by this kubongo
+const SchemaVersion = 1
+
+// Kinds of instances a Record can hold
+const (
+	KindLocal  = "local"
+	KindGcloud = "gce"
+	KindEc2    = "ec2"
+	KindMember = "member"
+)
+
+// Record is a single registered instance as persisted, Instance is decoded according to Kind
+type Record struct {
+	Kind     string            `json:"kind"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Instance json.RawMessage   `json:"instance"`
+}
+
+// memberRecord is a Member as persisted, its Instance is itself a Record
+type memberRecord struct {
+	ReplicaSet string        `json:"replicaSet"`
+	MemberID   int           `json:"memberId"`
+	Host       string        `json:"host"`
+	Role       string        `json:"role"`
+	Options    MemberOptions `json:"options"`
+	Origin     Origin        `json:"origin"`
+	Instance   Record        `json:"instance"`
+}
+
+// Store persists the instances of a Registry, Save is called with every instance after each change.
+// The Registry's resource version is kept along so watchers can resume across restarts.
+type Store interface {
+	Load() ([]Record, uint64, error)
+	Save(records []Record, resourceVersion uint64) error
+}
+
+// EncodeInstance turns an instance into a Record
+func EncodeInstance(instance hostProvider.Instance, labels map[string]string) (Record, error) {
+	record := Record{Labels: labels}
+	var body interface{} = instance
+	switch castInst := instance.(type) {
+	case hostProvider.LocalInstance, *hostProvider.LocalInstance:
+		record.Kind = KindLocal
+	case hostProvider.GcloudInstance, *hostProvider.GcloudInstance:
+		record.Kind = KindGcloud
+	case hostProvider.Ec2Instance, *hostProvider.Ec2Instance:
+		record.Kind = KindEc2
+	case *Member:
+		inner, innerErr := EncodeInstance(castInst.Instance, nil)
+		if innerErr != nil {
+			return record, innerErr
+		}
+		record.Kind = KindMember
+		body = &memberRecord{
+			ReplicaSet: castInst.ReplicaSet,
+			MemberID:   castInst.MemberID,
+			Host:       castInst.Host,
+			Role:       castInst.Role,
+			Options:    castInst.Options,
+			Origin:     castInst.Origin,
+			Instance:   inner,
+		}
+	default:
+		return record, fmt.Errorf("cannot persist instances of type %T", instance)
+	}
+	raw, jErr := json.Marshal(body)
+	record.Instance = raw
+	return record, jErr
+}
+
+// DecodeInstance turns a Record back into an instance, instances are always decoded as pointers
+func DecodeInstance(record Record) (hostProvider.Instance, error) {
+	switch record.Kind {
+	case KindLocal:
+		local := &hostProvider.LocalInstance{}
+		return local, json.Unmarshal(record.Instance, local)
+	case KindGcloud:
+		gcloud := &hostProvider.GcloudInstance{}
+		return gcloud, json.Unmarshal(record.Instance, gcloud)
+	case KindEc2:
+		ec2 := &hostProvider.Ec2Instance{}
+		return ec2, json.Unmarshal(record.Instance, ec2)
+	case KindMember:
+		stored := &memberRecord{}
+		jErr := json.Unmarshal(record.Instance, stored)
+		if jErr != nil {
+			return nil, jErr
+		}
+		inner, innerErr := DecodeInstance(stored.Instance)
+		if innerErr != nil {
+			return nil, innerErr
+		}
+		return &Member{
+			Instance:   inner,
+			ReplicaSet: stored.ReplicaSet,
+			MemberID:   stored.MemberID,
+			Host:       stored.Host,
+			Role:       stored.Role,
+			Options:    stored.Options,
+			Origin:     stored.Origin,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown instance kind %q", record.Kind)
+}
+
+// fileState is the content of a FileStore's file
+type fileState struct {
+	Version int `json:"version"`
+	// ResourceVersion is the Registry's when it was saved, files written before it was kept have 0
+	ResourceVersion uint64   `json:"resourceVersion,omitempty"`
+	Instances       []Record `json:"instances"`
+}
+
+// FileStore keeps the registry in a single JSON file, every Save atomically replaces the file
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore creates a FileStore at path, the file is created on the first Save
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load reads every record and the resource version from the file, a missing file is an empty registry
+func (f *FileStore) Load() ([]Record, uint64, error) {
+	data, readErr := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(readErr) {
+		return nil, 0, nil
+	}
+	if readErr != nil {
+		return nil, 0, readErr
+	}
+	state := &fileState{}
+	jErr := json.Unmarshal(data, state)
+	if jErr != nil {
+		return nil, 0, fmt.Errorf("%s is not a kubongo registry: %s", f.Path, jErr)
+	}
+	migrateErr := migrate(state)
+	if migrateErr != nil {
+		return nil, 0, fmt.Errorf("%s: %s", f.Path, migrateErr)
+	}
+	return state.Instances, state.ResourceVersion, nil
+}
+
+// migrate upgrades state written by an older kubongo to SchemaVersion, older versions get a case here when the format changes
+func migrate(state *fileState) error {
+	switch state.Version {
+	case SchemaVersion:
+		return nil
+	}
+	return fmt.Errorf("registry schema version %d is not supported, this kubongo reads version %d", state.Version, SchemaVersion)
+}
+
+// Save writes records to a temporary file next to the file, syncs it and renames it over the file
+// so a crash never leaves a partly written registry behind
+func (f *FileStore) Save(records []Record, resourceVersion uint64) error {
+	data, jErr := json.MarshalIndent(&fileState{Version: SchemaVersion, ResourceVersion: resourceVersion, Instances: records}, "", "  ")
+	if jErr != nil {
+		return jErr
+	}
+	dir := filepath.Dir(f.Path)
+	mkErr := os.MkdirAll(dir, 0700)
+	if mkErr != nil {
+		return mkErr
+	}
+	tmp, tmpErr := ioutil.TempFile(dir, filepath.Base(f.Path)+".tmp")
+	if tmpErr != nil {
+		return tmpErr
+	}
+	_, writeErr := tmp.Write(data)
+	if writeErr == nil {
+		writeErr = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return writeErr
+	}
+	renameErr := os.Rename(tmp.Name(), f.Path)
+	if renameErr != nil {
+		os.Remove(tmp.Name())
+		return renameErr
+	}
+	// make the rename itself durable
+	if dirFile, dirErr := os.Open(dir); dirErr == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+	return nil
+}
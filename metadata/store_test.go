@@ -0,0 +1,177 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "kubongo-store")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "registry.json")
+
+	registry, openErr := OpenRegistry(NewFileStore(path))
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	gce := hostProvider.GcloudInstance{Name: "db-0", Zone: "zones/us-central1-f"}
+	gce.NetworkInterfaces = []hostProvider.GcloudNetworkInterface{{Name: "eth0", NetworkIP: "10.0.0.2"}}
+	priority := float64(2)
+	registry.Add(&Member{
+		Instance:   gce,
+		ReplicaSet: "rs0",
+		MemberID:   3,
+		Host:       "10.0.0.2:27017",
+		Role:       "primary",
+		Options:    MemberOptions{Priority: &priority},
+		Origin:     Origin{MachineType: "n1-standard-4", SourceImage: "mongo-3-2"},
+	}, map[string]string{"tier": "db"})
+	registry.Add(&hostProvider.LocalInstance{Name: "db-1", IP: "127.0.0.1", ProcessPort: 27018, Zone: "local"}, nil)
+	registry.Add(&hostProvider.LocalInstance{Name: "db-2", IP: "127.0.0.1", Zone: "local"}, nil)
+	registry.Add(&hostProvider.Ec2Instance{InstanceID: "i-0abc", Name: "db-3", AvailabilityZone: "us-east-1a", PrivateIPAddress: "10.0.1.3"}, nil)
+	registry.Remove("db-2")
+
+	restored, reopenErr := OpenRegistry(NewFileStore(path))
+	if reopenErr != nil {
+		t.Fatal(reopenErr)
+	}
+	if restored.Len() != 3 {
+		t.Fatalf("expected 3 restored instances, got %d", restored.Len())
+	}
+	instance, _ := restored.Get("db-0")
+	member, isMember := instance.(*Member)
+	if !isMember {
+		t.Fatalf("expected db-0 to be restored as a member, got %T", instance)
+	}
+	if member.MemberID != 3 || member.Role != "primary" || *member.Options.Priority != 2 || member.GetInternalIP() != "10.0.0.2" || member.GetZone() != "us-central1-f" {
+		t.Errorf("db-0 was not restored as it was registered: %+v", member)
+	}
+	if member.Origin.MachineType != "n1-standard-4" || member.Origin.SourceImage != "mongo-3-2" {
+		t.Errorf("expected db-0 to keep what it was created from, got %+v", member.Origin)
+	}
+	if labels, _ := restored.Labels("db-0"); labels["tier"] != "db" {
+		t.Errorf("expected db-0's labels to be restored, got %v", labels)
+	}
+	if local, _ := restored.Get("db-1"); local.(*hostProvider.LocalInstance).ProcessPort != 27018 {
+		t.Errorf("expected db-1 to keep its port, got %+v", local)
+	}
+	if ec2, _ := restored.Get("db-3"); ec2.(*hostProvider.Ec2Instance).InstanceID != "i-0abc" || ec2.GetZone() != "us-east-1a" {
+		t.Errorf("expected db-3 to keep its instance id and availability zone, got %+v", ec2)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "state", "*.tmp*")); len(leftovers) != 0 {
+		t.Errorf("expected no temporary files to be left behind, got %v", leftovers)
+	}
+}
+
+func TestFileStoreRejectsUnknownVersion(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "kubongo-store")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+	ioutil.WriteFile(path, []byte(`{"version": 99, "instances": []}`), 0600)
+	if _, openErr := OpenRegistry(NewFileStore(path)); openErr == nil {
+		t.Error("expected a registry from a newer kubongo to be refused")
+	}
+}
+
+func TestFileStoreKeepsTheResourceVersion(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "kubongo-store")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+
+	registry, _ := OpenRegistry(NewFileStore(path))
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, nil)
+	registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local"}, nil)
+	registry.Remove("db-1")
+
+	restored, reopenErr := OpenRegistry(NewFileStore(path))
+	if reopenErr != nil {
+		t.Fatal(reopenErr)
+	}
+	if restored.ResourceVersion() != 3 {
+		t.Fatalf("expected to carry on from resource version 3, got %d", restored.ResourceVersion())
+	}
+	if _, watchErr := restored.Watch(2); !IsGone(watchErr) {
+		t.Errorf("expected resuming from before the restart to be gone, got %v", watchErr)
+	}
+	w, watchErr := restored.Watch(3)
+	if watchErr != nil {
+		t.Fatal(watchErr)
+	}
+	defer w.Stop()
+	restored.Add(&hostProvider.LocalInstance{Name: "db-2", Zone: "local"}, nil)
+	if event := nextEvent(t, w); event.Name != "db-2" || event.ResourceVersion != 4 {
+		t.Errorf("expected db-2 to be added at version 4, got %+v", event)
+	}
+}
+
+// brokenStore loads nothing and fails to save once broken is set
+type brokenStore struct {
+	broken bool
+}
+
+func (s *brokenStore) Load() ([]Record, uint64, error) {
+	return nil, 0, nil
+}
+
+func (s *brokenStore) Save(records []Record, resourceVersion uint64) error {
+	if s.broken {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func TestRegistryRollsBackChangesThatCannotBeSaved(t *testing.T) {
+	store := &brokenStore{}
+	registry, _ := OpenRegistry(store)
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, map[string]string{"tier": "db"})
+	store.broken = true
+
+	if addErr := registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local"}, nil); addErr == nil {
+		t.Error("expected Add to fail when the registry cannot be saved")
+	}
+	if _, ok := registry.Get("db-1"); ok {
+		t.Error("expected db-1 not to be registered")
+	}
+	if labelErr := registry.SetLabels("db-0", map[string]string{"tier": "cache"}); labelErr == nil {
+		t.Error("expected SetLabels to fail when the registry cannot be saved")
+	}
+	if len(registry.ByLabels(map[string]string{"tier": "db"})) != 1 {
+		t.Error("expected db-0 to keep its labels")
+	}
+	if _, removeErr := registry.Remove("db-0"); removeErr == nil {
+		t.Error("expected Remove to fail when the registry cannot be saved")
+	}
+	if len(registry.ByZone("local")) != 1 {
+		t.Error("expected db-0 to stay registered")
+	}
+	if registry.ResourceVersion() != 1 {
+		t.Errorf("expected failed changes not to bump the resource version, got %d", registry.ResourceVersion())
+	}
+}
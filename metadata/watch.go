@@ -0,0 +1,175 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+// Registry event types, named like the Kubernetes watch API's
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+)
+
+// historySize is how many events the Registry keeps for watchers resuming from an older resource version
+const historySize = 1000
+
+// watchBuffer is how many events a watcher may fall behind before it is dropped
+const watchBuffer = 100
+
+// ErrWatcherTooSlow is the Err of a Watcher that fell too far behind, watch again from the last event's resource version
+var ErrWatcherTooSlow = errors.New("watcher fell too far behind the registry")
+
+// GoneError is returned when watching from a resource version the Registry no longer has the events after
+type GoneError struct {
+	ResourceVersion uint64
+}
+
+func (e *GoneError) Error() string {
+	return fmt.Sprintf("resource version %d is too old to watch from, list again", e.ResourceVersion)
+}
+
+// IsGone returns true if err is a *GoneError
+func IsGone(err error) bool {
+	_, ok := err.(*GoneError)
+	return ok
+}
+
+// Event is a single change to the Registry, Instance is the instance after the change or before it was deleted
+type Event struct {
+	Type            string                `json:"type"`
+	ResourceVersion uint64                `json:"resourceVersion"`
+	Name            string                `json:"name"`
+	Instance        hostProvider.Instance `json:"instance"`
+	Labels          map[string]string     `json:"labels,omitempty"`
+}
+
+// Watcher receives the Registry's events in order until it is stopped or falls too far behind
+type Watcher struct {
+	registry *Registry
+	events   chan Event
+	once     sync.Once
+	err      error
+}
+
+// ResultChan returns the channel events are delivered on, it is closed when the watch ends
+func (w *Watcher) ResultChan() <-chan Event {
+	return w.events
+}
+
+// Err returns why the watch ended, nil if it was stopped
+func (w *Watcher) Err() error {
+	w.registry.mutex.RLock()
+	defer w.registry.mutex.RUnlock()
+	return w.err
+}
+
+// Stop ends the watch
+func (w *Watcher) Stop() {
+	w.registry.mutex.Lock()
+	defer w.registry.mutex.Unlock()
+	w.registry.dropWatcher(w, nil)
+}
+
+// dropWatcher removes w and closes its channel, the caller holds the write lock
+func (r *Registry) dropWatcher(w *Watcher, err error) {
+	w.once.Do(func() {
+		delete(r.watchers, w)
+		w.err = err
+		close(w.events)
+	})
+}
+
+// emit records an event and hands it to every watcher without blocking, the caller holds the write lock
+func (r *Registry) emit(eventType, name string, e *entry) {
+	r.resourceVersion++
+	event := Event{
+		Type:            eventType,
+		ResourceVersion: r.resourceVersion,
+		Name:            name,
+		Instance:        e.instance,
+		Labels:          copyLabels(e.labels),
+	}
+	r.history = append(r.history, event)
+	if len(r.history) > historySize {
+		r.history = r.history[len(r.history)-historySize:]
+	}
+	for w := range r.watchers {
+		select {
+		case w.events <- event:
+		default:
+			r.dropWatcher(w, ErrWatcherTooSlow)
+		}
+	}
+}
+
+// ResourceVersion returns the resource version of the last change
+func (r *Registry) ResourceVersion() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.resourceVersion
+}
+
+// Snapshot returns every registered instance sorted by name with the resource version they are at,
+// watching from that version misses no change
+func (r *Registry) Snapshot() (Instances, uint64) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.collect(r.allNames()), r.resourceVersion
+}
+
+// Watch returns a Watcher for every change after resourceVersion, 0 starts with an Added event for every registered instance
+func (r *Registry) Watch(resourceVersion uint64) (*Watcher, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	replay := []Event{}
+	if resourceVersion == 0 {
+		instances := r.collect(r.allNames())
+		for _, instance := range instances {
+			name := instance.GetName()
+			replay = append(replay, Event{
+				Type:            Added,
+				ResourceVersion: r.resourceVersion,
+				Name:            name,
+				Instance:        instance,
+				Labels:          copyLabels(r.entries[name].labels),
+			})
+		}
+	} else {
+		oldest := r.resourceVersion + 1
+		if len(r.history) > 0 {
+			oldest = r.history[0].ResourceVersion
+		}
+		if resourceVersion > r.resourceVersion || resourceVersion+1 < oldest {
+			return nil, &GoneError{ResourceVersion: resourceVersion}
+		}
+		for _, event := range r.history {
+			if event.ResourceVersion > resourceVersion {
+				replay = append(replay, event)
+			}
+		}
+	}
+	w := &Watcher{registry: r, events: make(chan Event, len(replay)+watchBuffer)}
+	for _, event := range replay {
+		w.events <- event
+	}
+	r.watchers[w] = true
+	return w, nil
+}
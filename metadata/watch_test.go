@@ -0,0 +1,99 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+)
+
+func nextEvent(t *testing.T, w *Watcher) Event {
+	select {
+	case event, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatalf("watch ended: %v", w.Err())
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestRegistryWatch(t *testing.T) {
+	registry := NewRegistry()
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, nil)
+
+	w, watchErr := registry.Watch(0)
+	if watchErr != nil {
+		t.Fatal(watchErr)
+	}
+	defer w.Stop()
+	if event := nextEvent(t, w); event.Type != Added || event.Name != "db-0" || event.ResourceVersion != 1 {
+		t.Errorf("expected db-0 to be listed as added at 1, got %+v", event)
+	}
+
+	registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local"}, nil)
+	registry.SetLabels("db-1", map[string]string{"tier": "db"})
+	registry.Remove("db-0")
+	expected := []string{"ADDED db-1 2", "MODIFIED db-1 3", "DELETED db-0 4"}
+	for _, want := range expected {
+		event := nextEvent(t, w)
+		if got := fmt.Sprintf("%s %s %d", event.Type, event.Name, event.ResourceVersion); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	resumed, resumeErr := registry.Watch(2)
+	if resumeErr != nil {
+		t.Fatal(resumeErr)
+	}
+	defer resumed.Stop()
+	if event := nextEvent(t, resumed); event.ResourceVersion != 3 {
+		t.Errorf("expected resuming from 2 to start at 3, got %+v", event)
+	}
+	if _, futureErr := registry.Watch(10); !IsGone(futureErr) {
+		t.Errorf("expected watching from a future version to be gone, got %v", futureErr)
+	}
+}
+
+func TestSlowWatcherDoesNotBlockWriters(t *testing.T) {
+	registry := NewRegistry()
+	slow, watchErr := registry.Watch(0)
+	if watchErr != nil {
+		t.Fatal(watchErr)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < watchBuffer*2; i++ {
+			registry.Add(&hostProvider.LocalInstance{Name: fmt.Sprintf("db-%d", i)}, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writers were blocked by a watcher that does not read")
+	}
+	received := 0
+	for range slow.ResultChan() {
+		received++
+	}
+	if received != watchBuffer || slow.Err() != ErrWatcherTooSlow {
+		t.Errorf("expected the slow watcher to get %d events then be dropped, got %d and %v", watchBuffer, received, slow.Err())
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIRequests and APIDuration are kubongo's http and gRPC api calls, api is http or grpc
+var (
+	APIRequests = NewCounterVec("kubongo_api_requests_total", "Calls to kubongo's apis by route, method and status code.", "api", "route", "method", "code")
+	APIDuration = NewHistogramVec("kubongo_api_request_duration_seconds", "How long kubongo's apis took to answer.", nil, "api", "route", "method")
+)
+
+func init() {
+	Register(APIRequests, APIDuration)
+}
+
+// Handler counts and times every request Next serves
+type Handler struct {
+	Next http.Handler
+	// Route names the route of a request, such as the ServeMux pattern it matched, so paths with names in them
+	// do not each become a series
+	Route func(req *http.Request) string
+}
+
+// statusRecorder remembers the status a handler answered with, it flushes for watches
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(data)
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	started := time.Now()
+	recorder := &statusRecorder{ResponseWriter: res}
+	h.Next.ServeHTTP(recorder, req)
+	route := ""
+	if h.Route != nil {
+		route = h.Route(req)
+	}
+	if route == "" {
+		route = "other"
+	}
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	APIRequests.Inc("http", route, req.Method, strconv.Itoa(recorder.status))
+	APIDuration.Observe(time.Since(started).Seconds(), "http", route, req.Method)
+}
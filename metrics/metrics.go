@@ -0,0 +1,197 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics collects kubongo's metrics and serves them in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Path is where the Default registry is served
+const Path = "/metrics"
+
+// Metric types of a Family
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Label is a single label of a Sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a Family
+type Sample struct {
+	// Suffix is appended to the Family's name, histograms use _bucket, _sum and _count
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is every Sample of a metric
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Add appends a sample of value with labels
+func (f *Family) Add(value float64, labels ...Label) {
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// Collector returns its families every time it is scraped
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc is a func used as a Collector
+type CollectorFunc func() []Family
+
+// Collect calls f
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry is every Collector that is served together
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the Registry kubongo serves on Path
+var Default = NewRegistry()
+
+// Register adds collectors to the Default registry
+func Register(collectors ...Collector) {
+	Default.Register(collectors...)
+}
+
+// Register adds collectors to r
+func (r *Registry) Register(collectors ...Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Gather collects every family sorted by name, families with the same name are merged
+func (r *Registry) Gather() []Family {
+	r.mutex.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mutex.Unlock()
+	byName := make(map[string]*Family)
+	var names []string
+	for _, collector := range collectors {
+		for _, family := range collector.Collect() {
+			if existing, ok := byName[family.Name]; ok {
+				existing.Samples = append(existing.Samples, family.Samples...)
+				continue
+			}
+			copied := family
+			byName[family.Name] = &copied
+			names = append(names, family.Name)
+		}
+	}
+	sort.Strings(names)
+	families := make([]Family, len(names))
+	for i, name := range names {
+		families[i] = *byName[name]
+	}
+	return families
+}
+
+// WriteTo writes every family of r in the text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for _, family := range r.Gather() {
+		if family.Help != "" {
+			buf.WriteString("# HELP " + family.Name + " " + helpEscaper.Replace(family.Help) + "\n")
+		}
+		if family.Type != "" {
+			buf.WriteString("# TYPE " + family.Name + " " + family.Type + "\n")
+		}
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name + sample.Suffix)
+			if len(sample.Labels) > 0 {
+				buf.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteString(label.Name + `="` + labelEscaper.Replace(label.Value) + `"`)
+				}
+				buf.WriteByte('}')
+			}
+			buf.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	flushErr := buf.Flush()
+	return counter.n, flushErr
+}
+
+// ServeHTTP serves r to Prometheus
+func (r *Registry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		res.Header().Set("Allow", "GET, HEAD")
+		http.Error(res, "method "+req.Method+" is not allowed on "+req.URL.Path, http.StatusMethodNotAllowed)
+		return
+	}
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(res)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.w.Write(data)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	requests := NewCounterVec("test_requests_total", "Requests.\nBy code.", "code")
+	latency := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1})
+	registry.Register(requests, latency, CollectorFunc(func() []Family {
+		family := Family{Name: "test_info", Type: TypeGauge}
+		family.Add(1, Label{Name: "path", Value: `C:\data "primary"`})
+		return []Family{family}
+	}))
+	requests.Inc("200")
+	requests.Add(2, "200")
+	requests.Add(-1, "200")
+	requests.Inc("500")
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(3)
+
+	out := &bytes.Buffer{}
+	registry.WriteTo(out)
+	expected := `test_info{path="C:\\data \"primary\""} 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.15
+test_latency_seconds_count 3
+# HELP test_requests_total Requests.\nBy code.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 3
+test_requests_total{code="500"} 1
+`
+	if !strings.HasSuffix(out.String(), expected) {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+	if requests.Value("404") != 0 || strings.Contains(out.String(), "404") {
+		t.Error("expected reading a counter not to create it")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/instances/", func(res http.ResponseWriter, req *http.Request) {
+		http.NotFound(res, req)
+	})
+	handler := &Handler{Next: mux, Route: func(req *http.Request) string {
+		_, pattern := mux.Handler(req)
+		return pattern
+	}}
+	before := APIRequests.Value("http", "/v1/instances/", "GET", "404")
+	for _, path := range []string{"/v1/instances/a/db-0", "/v1/instances/b/db-1"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if after := APIRequests.Value("http", "/v1/instances/", "GET", "404"); after != before+2 {
+		t.Errorf("expected both requests to be counted under their route, got %v more", after-before)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds histograms of latencies use
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// vec holds a value per combination of label values
+type vec struct {
+	mutex  sync.Mutex
+	name   string
+	help   string
+	labels []string
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// counts and sum are only used by histograms, counts[i] is the number of observations in bucket i alone
+	counts []uint64
+	sum    float64
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+// get returns the series of values, creating it if it is new, v must be locked
+func (v *vec) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " takes the labels " + strings.Join(v.labels, ", "))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns every series ordered by its label values, v must be locked
+func (v *vec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, len(keys))
+	for i, key := range keys {
+		sorted[i] = v.series[key]
+	}
+	return sorted
+}
+
+func (v *vec) labelsOf(s *series, extra ...Label) []Label {
+	labels := make([]Label, 0, len(v.labels)+len(extra))
+	for i, name := range v.labels {
+		labels = append(labels, Label{Name: name, Value: s.values[i]})
+	}
+	return append(labels, extra...)
+}
+
+// CounterVec is a counter per combination of its labels' values
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec creates a CounterVec, every Inc and Add must give a value for each of labels
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: newVec(name, help, labels)}
+}
+
+// Inc adds 1 to the counter of values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta to the counter of values, counters only go up so a negative delta is ignored
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.get(values).value += delta
+}
+
+// Value returns the counter of values
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok := c.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// Collect returns the counters as a single family
+func (c *CounterVec) Collect() []Family {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, s := range c.sorted() {
+		family.Add(s.value, c.labelsOf(s)...)
+	}
+	return []Family{family}
+}
+
+// HistogramVec is a histogram per combination of its labels' values
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec creates a HistogramVec with the sorted upper bounds buckets, nil uses DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{vec: newVec(name, help, labels), buckets: buckets}
+}
+
+// Observe adds value to the histogram of values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := h.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets)+1)
+	}
+	i := sort.SearchFloat64s(h.buckets, value)
+	s.counts[i]++
+	s.sum += value
+}
+
+// Collect returns the histograms as a single family with cumulative buckets
+func (h *HistogramVec) Collect() []Family {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, s := range h.sorted() {
+		if s.counts == nil {
+			continue
+		}
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+			family.Samples = append(family.Samples, Sample{Suffix: "_bucket", Labels: h.labelsOf(s, Label{Name: "le", Value: formatValue(bound)}), Value: float64(cumulative)})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_sum", Labels: h.labelsOf(s), Value: s.sum},
+			Sample{Suffix: "_count", Labels: h.labelsOf(s), Value: float64(cumulative)},
+		)
+	}
+	return []Family{family}
+}
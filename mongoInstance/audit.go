@@ -0,0 +1,95 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/auth"
+	"golang.org/x/net/context"
+)
+
+// actorOf names who made req, the authenticated identity if there is one and the remote address otherwise
+func actorOf(req *http.Request) string {
+	if identity, ok := auth.IdentityOf(req); ok && identity.Name != auth.Anonymous {
+		return identity.Name
+	}
+	return req.RemoteAddr
+}
+
+// operationActor is who started the operation of ctx, or audit.System if ctx does not belong to one
+func operationActor(ctx context.Context) string {
+	// Actor is never changed after Start, it can be read without the lock
+	if t, ok := ctx.Value(trackedKey{}).(*tracked); ok && t.op.Actor != "" {
+		return t.op.Actor
+	}
+	return audit.System
+}
+
+// audit records an action the Manager took on target, request is encoded as the entry's request
+func (m *Manager) audit(ctx context.Context, action, target string, started time.Time, request interface{}, err error) {
+	if m.Audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Time:       started,
+		Source:     audit.SourceManager,
+		Actor:      operationActor(ctx),
+		Action:     action,
+		Target:     target,
+		Outcome:    audit.OutcomeSuccess,
+		DurationMS: int64(time.Since(started) / time.Millisecond),
+	}
+	if request != nil {
+		entry.Request, _ = json.Marshal(request)
+	}
+	if err != nil {
+		entry.Outcome, entry.Error = audit.OutcomeFailure, err.Error()
+	}
+	m.Audit.Record(entry)
+}
+
+// addFailover adds record to the history, counts and audits it, repeats of the same suppression are only counted
+// and audited once
+func (m *Manager) addFailover(record FailoverRecord, started time.Time) {
+	if !m.history.add(record) {
+		return
+	}
+	failovers.Inc(record.Outcome, strconv.FormatBool(record.Manual))
+	m.failoverEvent(record)
+	if m.Audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Time:       started,
+		Source:     audit.SourceManager,
+		Actor:      audit.System,
+		Action:     "failover",
+		Target:     record.From,
+		Outcome:    audit.OutcomeSuccess,
+		DurationMS: int64(time.Since(started) / time.Millisecond),
+	}
+	entry.Request, _ = json.Marshal(&record)
+	switch record.Outcome {
+	case FailoverSuppressed:
+		entry.Outcome, entry.Error = audit.OutcomeSuppressed, record.Reason
+	case FailoverFailed:
+		entry.Outcome, entry.Error = audit.OutcomeFailure, record.Reason
+	}
+	m.Audit.Record(entry)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 100
+
+// broadcaster hands events to every subscriber without blocking the publisher, a subscriber that falls too far behind
+// has its channel closed
+type broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan interface{}]bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: make(map[chan interface{}]bool)}
+}
+
+func (b *broadcaster) subscribe() (<-chan interface{}, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	events := make(chan interface{}, subscriberBuffer)
+	b.subscribers[events] = true
+	return events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.drop(events)
+	}
+}
+
+// drop closes events once, the caller holds the lock
+func (b *broadcaster) drop(events chan interface{}) {
+	if b.subscribers[events] {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+func (b *broadcaster) publish(event interface{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			b.drop(events)
+		}
+	}
+}
+
+// Subscribe returns a channel of the Manager's events, an InstanceStatus whenever an instance changes state
+// and a FailoverRecord whenever a failover is executed, suppressed or fails, and a func to unsubscribe.
+// The channel is closed if the subscriber falls too far behind.
+func (m *Manager) Subscribe() (<-chan interface{}, func()) {
+	return m.events.subscribe()
+}
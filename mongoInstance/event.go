@@ -0,0 +1,79 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"log"
+
+	kube "github.com/cpg1111/kubongo/kubeClient"
+)
+
+// Reasons of the Events the Manager posts, in the CamelCase Kubernetes uses for its own
+const (
+	ReasonCreated                      = "Created"
+	ReasonCreateFailed                 = "CreateFailed"
+	ReasonRegistered                   = "Registered"
+	ReasonRegisterFailed               = "RegisterFailed"
+	ReasonRemoved                      = "Removed"
+	ReasonRemoveFailed                 = "RemoveFailed"
+	ReasonFailedOver                   = "FailedOver"
+	ReasonFailoverFailed               = "FailoverFailed"
+	ReasonFailoverSuppressed           = "FailoverSuppressed"
+	ReasonEndpointsUpdateFailed        = "EndpointsUpdateFailed"
+	ReasonConnectionStringUpdateFailed = "ConnectionStringUpdateFailed"
+)
+
+// actionReasons are the reasons an action is posted with when it succeeds and when it fails
+var actionReasons = map[string][2]string{
+	"create":             {ReasonCreated, ReasonCreateFailed},
+	"create replica set": {ReasonCreated, ReasonCreateFailed},
+	"register":           {ReasonRegistered, ReasonRegisterFailed},
+	"remove":             {ReasonRemoved, ReasonRemoveFailed},
+}
+
+// event posts an Event about mongo's Service, it does nothing without a kubeClient controller
+func (m *Manager) event(eventType, reason, message string) {
+	if m.kubeCtl == nil {
+		return
+	}
+	eventErr := m.kubeCtl.RecordEvent(eventType, reason, message)
+	if eventErr != nil {
+		log.Println("event:52 could not post", reason, "event:", eventErr)
+	}
+}
+
+// actionEvent posts the Event for the Manager's action on target, a Warning if it failed with err
+func (m *Manager) actionEvent(action, target string, err error) {
+	reasons, ok := actionReasons[action]
+	if !ok {
+		return
+	}
+	if err != nil {
+		m.event(kube.EventWarning, reasons[1], action+" "+target+" failed: "+err.Error())
+		return
+	}
+	m.event(kube.EventNormal, reasons[0], action+" "+target)
+}
+
+// failoverEvent posts the Event for how a failover from record.From ended
+func (m *Manager) failoverEvent(record FailoverRecord) {
+	switch record.Outcome {
+	case FailoverExecuted:
+		m.event(kube.EventNormal, ReasonFailedOver, "failed over from "+record.From+" to "+record.To+", "+record.Reason)
+	case FailoverFailed:
+		m.event(kube.EventWarning, ReasonFailoverFailed, "could not fail over from "+record.From+": "+record.Reason)
+	case FailoverSuppressed:
+		m.event(kube.EventWarning, ReasonFailoverSuppressed, "did not fail over from "+record.From+": "+record.Reason)
+	}
+}
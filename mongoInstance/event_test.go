@@ -0,0 +1,90 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kube "github.com/cpg1111/kubongo/kubeClient"
+	"golang.org/x/net/context"
+)
+
+// eventRecorder stands in for the Kubernetes api, keeping the Events posted to it
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []kube.Event
+}
+
+func (r *eventRecorder) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" || req.URL.Path != "/api/v1/namespaces/default/events" {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	event := kube.Event{}
+	json.NewDecoder(req.Body).Decode(&event)
+	r.mutex.Lock()
+	r.events = append(r.events, event)
+	r.mutex.Unlock()
+	res.WriteHeader(http.StatusCreated)
+}
+
+func TestEvents(t *testing.T) {
+	manager, primary := newLagManager(t)
+	defer primary.Close()
+	recorder := &eventRecorder{}
+	api := httptest.NewServer(recorder)
+	defer api.Close()
+	manager.SetKubeCtl(kube.New(strings.TrimPrefix(api.URL, "http://"), "default", "DB_CONNECT_STRING", "mongo"))
+
+	manager.actionEvent("register", "us-east1-b/db-2", nil)
+	manager.RemoveContext(context.Background(), "us-east1-b", "missing")
+	manager.LagThresholds.MaxLag = time.Nanosecond
+	manager.checkReplication(primary.Addr())
+	manager.Failover(primary.Addr())
+
+	expected := []struct{ eventType, reason string }{
+		{kube.EventNormal, ReasonRegistered},
+		{kube.EventWarning, ReasonRemoveFailed},
+		{kube.EventWarning, ReasonFailoverFailed},
+	}
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	events := []kube.Event{}
+	for _, event := range recorder.events {
+		// publishing may warn about the Service and ConfigMap the stand in does not serve
+		if event.Reason != ReasonEndpointsUpdateFailed && event.Reason != ReasonConnectionStringUpdateFailed {
+			events = append(events, event)
+		}
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i := range expected {
+		if events[i].Type != expected[i].eventType || events[i].Reason != expected[i].reason {
+			t.Errorf("expected a %s %s event, got %s %s", expected[i].eventType, expected[i].reason, events[i].Type, events[i].Reason)
+		}
+		if events[i].InvolvedObject.Name != "mongo" || events[i].Metadata.Namespace != "default" {
+			t.Errorf("event %s is not about the mongo Service: %+v", events[i].Reason, events[i].InvolvedObject)
+		}
+	}
+	if !strings.Contains(events[1].Message, "missing") {
+		t.Errorf("expected the failed removal to name its target, got %s", events[1].Message)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+	"golang.org/x/net/context"
+)
+
+// DefaultElectionTimeout is how long the replica set gets to elect a primary on its own, mongod's default election timeout is 10s
+const DefaultElectionTimeout = 30 * time.Second
+
+// maxPriority is the highest priority mongod accepts for a member
+const maxPriority = 1000
+
+// failover makes sure a surviving member is primary after the primary at deadHost failed its health checks and returns
+// the new primary's host, the dead member is replaced by a fresh secondary in the background
+func (m *Manager) failover(deadHost string) (string, error) {
+	var dead *metadata.Member
+	survivors := []*metadata.Member{}
+	for _, member := range m.members() {
+		if member.Host == deadHost {
+			dead = member
+		} else {
+			survivors = append(survivors, member)
+		}
+	}
+	if len(survivors) == 0 {
+		return "", fmt.Errorf("replica set %s has no members besides %s to fail over to", m.ReplicaSet, deadHost)
+	}
+	if len(m.promotable(survivors)) == 0 {
+		return "", fmt.Errorf("every electable member of replica set %s besides %s is lagging, refusing to promote one", m.ReplicaSet, deadHost)
+	}
+	// the old primary may still answer while failing health checks, make sure it does not keep taking writes
+	stepDown(deadHost, 60)
+	primary, primaryErr := awaitPrimary(memberAddresses(survivors), deadHost, m.ElectionTimeout)
+	if primaryErr != nil {
+		log.Println("failover:56 no primary was elected in", m.ElectionTimeout, "forcing one")
+		primary, primaryErr = m.forcePrimary(survivors)
+		if primaryErr != nil {
+			return "", primaryErr
+		}
+	}
+	m.refreshRoles()
+	m.publish()
+	// a primary failed over from manually may be healthy and just stepped down, only replace dead ones
+	if dead != nil && !m.CheckHealth(dead.Host).Healthy {
+		m.Operations.Start("replace", dead.GetZone()+"/"+dead.GetName(), audit.System, func(ctx context.Context) ([]byte, error) {
+			return m.replaceMember(detach(ctx), dead, primary)
+		})
+	}
+	return primary, nil
+}
+
+// awaitPrimary polls addresses until they agree on a primary other than exclude or timeout passes
+func awaitPrimary(addresses []string, exclude string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		primary, primaryErr := findPrimary(addresses)
+		if primaryErr == nil && primary != exclude {
+			return primary, nil
+		}
+		if time.Now().After(deadline) {
+			return "", errNoPrimary
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// forcePrimary picks a healthy electable survivor that is not lagging and force reconfigures the replica set from it without the
+// members it cannot reach and with the survivor at the highest priority. This is MongoDB's procedure for a set that lost its majority,
+// a set where every member is reachable but none is elected is refused as dropping no one would not help it.
+func (m *Manager) forcePrimary(survivors []*metadata.Member) (string, error) {
+	var candidate *metadata.Member
+	for _, member := range m.promotable(survivors) {
+		if health := m.CheckHealth(member.Host); health.Healthy && health.Role == RoleSecondary {
+			candidate = member
+			break
+		}
+	}
+	if candidate == nil {
+		return "", fmt.Errorf("replica set %s has no healthy electable secondary that is not lagging to promote", m.ReplicaSet)
+	}
+	log.Println("failover:102 promoting", candidate.Host, "in", m.ReplicaSet)
+	reconfErr := reconfig(candidate.Host, true, func(members []interface{}) ([]interface{}, error) {
+		reachable := []interface{}{}
+		highest := float64(1)
+		for _, raw := range members {
+			existing, _ := raw.(mongoWire.Doc)
+			host := existing.String("host")
+			if host != candidate.Host && !m.CheckHealth(host).Healthy {
+				log.Println("failover:110 dropping unreachable", host, "from", m.ReplicaSet)
+				continue
+			}
+			reachable = append(reachable, raw)
+			if priority, hasPriority := existing.Get("priority").(float64); hasPriority && priority > highest {
+				highest = priority
+			}
+		}
+		if len(reachable) == len(members) {
+			return nil, fmt.Errorf("every member of replica set %s is reachable but none was elected primary, refusing to force a reconfig", m.ReplicaSet)
+		}
+		members = reachable
+		promoted := highest + 1
+		if promoted > maxPriority {
+			promoted = maxPriority
+		}
+		updated := []interface{}{}
+		for _, raw := range members {
+			existing, _ := raw.(mongoWire.Doc)
+			if existing.String("host") != candidate.Host {
+				updated = append(updated, raw)
+				continue
+			}
+			withPriority := mongoWire.Doc{}
+			for _, elem := range existing {
+				if elem.Key != "priority" {
+					withPriority = append(withPriority, elem)
+				}
+			}
+			updated = append(updated, append(withPriority, mongoWire.Elem{Key: "priority", Value: promoted}))
+		}
+		return updated, nil
+	})
+	if reconfErr != nil {
+		return "", reconfErr
+	}
+	return awaitPrimary(memberAddresses(survivors), "", m.ElectionTimeout)
+}
+
+// replacementTmpl is the template the replacement for dead is created from, in dead's zone and from what dead was created from.
+// A member that was registered rather than created falls back to the platform's master template, on the same port if it is local.
+func (m *Manager) replacementTmpl(dead *metadata.Member, labels map[string]string) *InstanceTemplate {
+	tmpl := localMasterTmpl()
+	switch m.Platform {
+	case "GCE":
+		tmpl = gcloudMasterTmpl()
+	case "EC2":
+		tmpl = ec2MasterTmpl()
+	}
+	switch {
+	case dead.Origin != metadata.Origin{}:
+		tmpl.MachineType, tmpl.SourceImage, tmpl.Source = dead.Origin.MachineType, dead.Origin.SourceImage, dead.Origin.Source
+	case m.Platform == "local":
+		if _, port, splitErr := net.SplitHostPort(dead.Host); splitErr == nil {
+			tmpl.MachineType = port
+		}
+	}
+	tmpl.Name = dead.GetName()
+	if zone := dead.GetZone(); zone != "" {
+		tmpl.Zone = zone
+	}
+	tmpl.Options = dead.Options
+	tmpl.Labels = labels
+	return tmpl
+}
+
+// replaceMember removes dead from the replica set and its platform, then creates a new instance in its place
+// which joins as a secondary and resyncs from primary
+func (m *Manager) replaceMember(ctx context.Context, dead *metadata.Member, primary string) ([]byte, error) {
+	name := dead.GetName()
+	zone := dead.GetZone()
+	planSteps(ctx, stepLeaveReplicaSet, stepDeleteServer, stepUnregister)
+	beginStep(ctx, stepLeaveReplicaSet)
+	removeErr := removeMember(primary, dead.Host)
+	if _, forced := removeErr.(*notMemberError); forced {
+		// a forced reconfig already dropped it
+		removeErr = nil
+	}
+	if removeErr != nil {
+		log.Println("failover:189 could not remove", dead.Host, "from", m.ReplicaSet, "leaving it in place:", removeErr)
+		return nil, removeErr
+	}
+	beginStep(ctx, stepDeleteServer)
+	deleteErr := m.deleteServer(ctx, zone, name)
+	if deleteErr != nil {
+		log.Println("failover:195 could not delete", name, "creating its replacement anyway:", deleteErr)
+	}
+	beginStep(ctx, stepUnregister)
+	labels, _ := m.Registry.Labels(name)
+	_, unregErr := m.Registry.Remove(name)
+	if unregErr != nil {
+		log.Println("failover:201 could not unregister", name, "creating its replacement anyway:", unregErr)
+	}
+	log.Println("failover:203 replacing", name, "with a new secondary")
+	created, createErr := m.CreateContext(ctx, m.replacementTmpl(dead, labels))
+	if createErr != nil {
+		log.Println("failover:206 could not replace", name, createErr)
+	}
+	return created, createErr
+}
@@ -15,9 +15,11 @@ package mongoInstance
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/cpg1111/kubongo/hostProvider"
 	"github.com/cpg1111/kubongo/metadata"
@@ -28,20 +30,21 @@ type MongoHandler struct {
 	ProjectID   string
 	Platform    string
 	platformCtl hostProvider.HostProvider
-	Manager     Manager
-	Instances   metadata.Instances
+	Manager     *Manager
 }
 
 // NewHandler creates a new mongo handler struct
-func NewHandler(platform, projectID, confPath string, inst metadata.Instances) *MongoHandler {
+func NewHandler(platform, projectID, confPath string, registry *metadata.Registry) *MongoHandler {
 	var host hostProvider.HostProvider
 	var hErr error
 	switch platform {
 	case "GCE":
 		host = *hostProvider.NewGcloud(projectID, confPath)
 		hErr = nil
+	case "EC2":
+		host, hErr = hostProvider.NewEc2(projectID, confPath)
 	case "local":
-		host = *hostProvider.NewLocal()
+		host = hostProvider.NewLocal()
 		hErr = nil
 	}
 	if hErr != nil {
@@ -51,41 +54,93 @@ func NewHandler(platform, projectID, confPath string, inst metadata.Instances) *
 		ProjectID:   projectID,
 		Platform:    platform,
 		platformCtl: host,
-		Manager:     *NewManager(platform, projectID, &host, &inst),
-		Instances:   inst,
+		Manager:     NewManager(projectID, platform, &host, registry),
 	}
 }
 
-// ServeHTTP serves http for mongo instance
+// ServeHTTP serves http for mongo instance, /instances and /v1/instances are the collection
+// and /v1/instances/{zone}/{name} is a single instance
 func (m MongoHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	log.Println("handler:61 HTTP request:", *req)
+	log.Println("handler:64 HTTP request:", req.Method, req.URL.Path, "from", req.RemoteAddr)
+	if strings.HasPrefix(req.URL.Path, instancesPath) && strings.Trim(req.URL.Path[len(instancesPath):], "/") != "" {
+		m.serveInstance(res, req)
+		return
+	}
 	switch req.Method {
 	case "GET":
+		if req.URL.Query().Get("watch") == "true" || wantsEventStream(req) {
+			m.Watch(res, req)
+			return
+		}
 		m.Get(res, req)
 	case "POST":
 		m.Post(res, req)
 	case "DELETE":
 		m.Delete(res, req)
+	default:
+		methodNotAllowed(res, req, "GET, POST, DELETE")
+	}
+}
+
+// ErrorRes is the body of every error response
+type ErrorRes struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// statusOf maps an error from the Manager to a status code
+func statusOf(err error) int {
+	switch {
+	case metadata.IsNotFound(err):
+		return http.StatusNotFound
+	case metadata.IsConflict(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
-type infoRes struct {
+// writeError writes err as an ErrorRes with status
+func writeError(res http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.Println("handler:106 request failed:", err)
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(&ErrorRes{Error: err.Error(), Code: status})
+}
+
+func methodNotAllowed(res http.ResponseWriter, req *http.Request, allowed string) {
+	res.Header().Set("Allow", allowed)
+	writeError(res, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed on %s", req.Method, req.URL.Path))
+}
+
+// InstanceList is served on GET /instances and /v1/instances
+type InstanceList struct {
 	Platform          string             `json:"platform"`
 	ProjectName       string             `json:"projectName"`
 	NumberOfInstances int                `json:"numberOfInstances"`
 	Zones             []string           `json:"zones"`
 	Instances         metadata.Instances `json:"instances"`
+	Health            []InstanceStatus   `json:"health"`
+	// Items are the instances as served on /v1/instances/{zone}/{name}
+	Items []InstanceRes `json:"items"`
 }
 
 // Get for GET method on /instances
 func (m *MongoHandler) Get(res http.ResponseWriter, req *http.Request) {
-	numInsts := len(m.Instances)
-	payload := &infoRes{
+	instances := m.Manager.Registry.List()
+	payload := &InstanceList{
 		Platform:          m.Platform,
 		ProjectName:       m.ProjectID,
-		NumberOfInstances: numInsts,
-		Zones:             []string{},
-		Instances:         m.Instances,
+		NumberOfInstances: len(instances),
+		Zones:             m.Manager.Registry.Zones(),
+		Instances:         instances,
+		Health:            m.Manager.Prober.Statuses(),
+		Items:             []InstanceRes{},
+	}
+	for _, instance := range instances {
+		payload.Items = append(payload.Items, *m.instanceRes(instance))
 	}
 	header := res.Header()
 	encoder := json.NewEncoder(res)
@@ -104,9 +159,15 @@ type InstanceTemplate struct {
 	MachineType string `json:"machineType" yaml:"machineType"`
 	SourceImage string `json:"sourceImage" yaml:"sourceImage"`
 	Source      string `json:"source" yaml:"source"`
+	Version     string `json:"version,omitempty" yaml:"version,omitempty"` // rejected, the mongod version is sourceImage's
+	// Members > 1 creates a whole replica set of instances named <name>-<i>
+	Members int                    `json:"members,omitempty" yaml:"members,omitempty"`
+	Options metadata.MemberOptions `json:"options,omitempty" yaml:"options,omitempty"`
+	Labels  map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
 }
 
-// Post will either create or register an instance based the "kind" field in the request body
+// Post will either create or register an instance based the "kind" field in the request body,
+// it answers 202 with the operation doing so
 // Request Body Srtuct:
 // type InstanceTemplate struct{
 //     Kind        string `json:"kind"` // should equal "Create" or "Register"
@@ -122,21 +183,50 @@ func (m *MongoHandler) Post(res http.ResponseWriter, req *http.Request) {
 	newInstanceTmpl := &InstanceTemplate{}
 	deErr := reqDecoder.Decode(newInstanceTmpl)
 	if deErr != nil {
-		log.Fatal(deErr)
+		writeError(res, http.StatusBadRequest, deErr)
+		return
 	}
-	if newInstanceTmpl.Kind == "Create" {
-		serverRes, serverErr := m.Manager.Create(newInstanceTmpl, &m.Instances)
-		if serverErr != nil {
-			log.Fatal(serverErr)
-		}
-		res.Write(serverRes)
-	} else {
-		serverRes, serverErr := m.Manager.Register(newInstanceTmpl.Zone, newInstanceTmpl.Name, &m.Instances)
-		if serverErr != nil {
-			log.Fatal(serverRes, serverErr)
+	validErr := newInstanceTmpl.Validate()
+	if validErr != nil {
+		writeError(res, http.StatusBadRequest, validErr)
+		return
+	}
+	if newInstanceTmpl.Kind == "Create" && newInstanceTmpl.Members > 1 {
+		if m.Manager.ReplicaSet == "" {
+			writeError(res, http.StatusBadRequest, errors.New("no replica set name was configured"))
+			return
 		}
-		res.Write([]byte("{\"message\":\"201 CREATED\"}"))
+		writeOperation(res, m.Manager.StartCreate(newInstanceTmpl, actorOf(req)))
+		return
+	}
+	if _, exists := m.Manager.Registry.Get(newInstanceTmpl.Name); exists {
+		writeError(res, http.StatusConflict, &metadata.ConflictError{Name: newInstanceTmpl.Name})
+		return
 	}
+	writeOperation(res, m.Manager.StartCreate(newInstanceTmpl, actorOf(req)))
+}
+
+// errVersionUnsupported is returned for templates that ask for a mongod version
+var errVersionUnsupported = errors.New("version can not be chosen, the mongod version is the one installed on sourceImage")
+
+// Validate checks a template has what Create and Register need
+func (tmpl *InstanceTemplate) Validate() error {
+	if tmpl.Kind != "Create" && tmpl.Kind != "Register" {
+		return fmt.Errorf("kind must be Create or Register, got %q", tmpl.Kind)
+	}
+	if tmpl.Name == "" {
+		return errors.New("name is required")
+	}
+	if tmpl.Zone == "" {
+		return errors.New("zone is required")
+	}
+	if tmpl.Members < 0 {
+		return errors.New("members can not be negative")
+	}
+	if tmpl.Version != "" {
+		return errVersionUnsupported
+	}
+	return nil
 }
 
 // DeleteData is req data to delete instance
@@ -145,18 +235,120 @@ type DeleteData struct {
 	Name string `json:"name"`
 }
 
-// Delete will delete instances
+// Delete will delete instances, it answers 202 with the operation doing so
 func (m *MongoHandler) Delete(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	reqDecoder := json.NewDecoder(req.Body)
 	data := &DeleteData{}
 	reqErr := reqDecoder.Decode(data)
 	if reqErr != nil {
-		log.Fatal(reqErr)
+		writeError(res, http.StatusBadRequest, reqErr)
+		return
 	}
-	dErr := m.Manager.Remove(data.Zone, data.Name)
-	if dErr != nil {
-		log.Fatal(dErr)
+	if data.Name == "" {
+		writeError(res, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	if _, registered := m.Manager.Registry.Get(data.Name); !registered {
+		writeError(res, http.StatusNotFound, &metadata.NotFoundError{Name: data.Name})
+		return
+	}
+	writeOperation(res, m.Manager.StartRemove(data.Zone, data.Name, actorOf(req)))
+}
+
+// FailoverHandler handles http requests for failovers, GET lists recorded failovers and POST fails over manually
+type FailoverHandler struct {
+	Manager *Manager
+}
+
+// FailoverData is req data to fail over manually, From defaults to the current primary
+type FailoverData struct {
+	From string `json:"from"`
+}
+
+// ServeHTTP serves http for failovers
+func (f *FailoverHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	encoder := json.NewEncoder(res)
+	switch req.Method {
+	case "GET":
+		encoder.Encode(f.Manager.Failovers())
+	case "POST":
+		defer req.Body.Close()
+		data := &FailoverData{}
+		if req.ContentLength != 0 {
+			deErr := json.NewDecoder(req.Body).Decode(data)
+			if deErr != nil {
+				writeError(res, http.StatusBadRequest, deErr)
+				return
+			}
+		}
+		record, failErr := f.Manager.Failover(data.From)
+		if failErr != nil {
+			writeError(res, statusOf(failErr), failErr)
+			return
+		}
+		encoder.Encode(record)
+	default:
+		methodNotAllowed(res, req, "GET, POST")
+	}
+}
+
+// writeOperation answers 202 with op, which can be followed at its Location
+func writeOperation(res http.ResponseWriter, op Operation) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Location", operationsPath+"/"+op.ID)
+	res.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(res).Encode(&op)
+}
+
+// operationsPath lists operations, a single one is at operationsPath/{id} and is cancelled at operationsPath/{id}/cancel
+const operationsPath = "/v1/operations"
+
+// OperationsHandler handles http requests for operations
+type OperationsHandler struct {
+	Manager *Manager
+}
+
+// ServeHTTP serves http for operations, GET lists or gets them and POST on {id}/cancel cancels one
+func (o *OperationsHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, operationsPath), "/"), "/")
+	encoder := json.NewEncoder(res)
+	switch {
+	case parts[0] == "" && len(parts) == 1:
+		if req.Method != "GET" {
+			methodNotAllowed(res, req, "GET")
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		encoder.Encode(o.Manager.Operations.List())
+	case len(parts) == 1:
+		if req.Method != "GET" {
+			methodNotAllowed(res, req, "GET")
+			return
+		}
+		op, ok := o.Manager.Operations.Get(parts[0])
+		if !ok {
+			writeError(res, http.StatusNotFound, fmt.Errorf("operation %s not found", parts[0]))
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		encoder.Encode(&op)
+	case len(parts) == 2 && parts[1] == "cancel":
+		if req.Method != "POST" {
+			methodNotAllowed(res, req, "POST")
+			return
+		}
+		op, ok := o.Manager.Operations.Cancel(parts[0])
+		if !ok {
+			writeError(res, http.StatusNotFound, fmt.Errorf("operation %s not found", parts[0]))
+			return
+		}
+		if op.Done() {
+			writeError(res, http.StatusConflict, fmt.Errorf("operation %s already finished as %s", op.ID, op.Status))
+			return
+		}
+		writeOperation(res, op)
+	default:
+		writeError(res, http.StatusNotFound, fmt.Errorf("%s is not an operation", req.URL.Path))
 	}
-	res.Write([]byte("{\"message\":\"200 OK\"}"))
 }
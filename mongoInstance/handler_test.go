@@ -0,0 +1,115 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+func doRequest(t *testing.T, method, url, body string) *http.Response {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	res, resErr := http.DefaultClient.Do(req)
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	return res
+}
+
+// expectError checks res has status and an ErrorRes body with the same code
+func expectError(t *testing.T, res *http.Response, status int) {
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		t.Errorf("%s %s: expected %d, got %s", res.Request.Method, res.Request.URL.Path, status, res.Status)
+		return
+	}
+	body := &ErrorRes{}
+	decodeErr := json.NewDecoder(res.Body).Decode(body)
+	if decodeErr != nil || body.Code != status || body.Error == "" {
+		t.Errorf("%s %s: expected an error body with code %d, got %+v %v", res.Request.Method, res.Request.URL.Path, status, body, decodeErr)
+	}
+}
+
+func newTestServer() (*httptest.Server, *metadata.Registry) {
+	registry := metadata.NewRegistry()
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, map[string]string{"tier": "db"})
+	return httptest.NewServer(NewHandler("local", "test", "", registry)), registry
+}
+
+func TestInstanceResource(t *testing.T) {
+	server, registry := newTestServer()
+	defer server.Close()
+
+	res := doRequest(t, "GET", server.URL+"/v1/instances/local/db-0", "")
+	instance := &InstanceRes{}
+	json.NewDecoder(res.Body).Decode(instance)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || instance.Name != "db-0" || instance.Zone != "local" || instance.Labels["tier"] != "db" {
+		t.Errorf("expected db-0 in local labeled tier=db, got %s %+v", res.Status, instance)
+	}
+
+	res = doRequest(t, "PATCH", server.URL+"/v1/instances/local/db-0", `{"labels":{"tier":null,"team":"data"}}`)
+	res.Body.Close()
+	labels, _ := registry.Labels("db-0")
+	if res.StatusCode != http.StatusOK || len(labels) != 1 || labels["team"] != "data" {
+		t.Errorf("expected PATCH to replace tier with team, got %s %v", res.Status, labels)
+	}
+
+	res = doRequest(t, "PUT", server.URL+"/v1/instances/local/db-0", `{"labels":{"tier":"cache"}}`)
+	res.Body.Close()
+	labels, _ = registry.Labels("db-0")
+	if res.StatusCode != http.StatusOK || len(labels) != 1 || labels["tier"] != "cache" {
+		t.Errorf("expected PUT to replace every label, got %s %v", res.Status, labels)
+	}
+
+	expectError(t, doRequest(t, "GET", server.URL+"/v1/instances/other/db-0", ""), http.StatusNotFound)
+	expectError(t, doRequest(t, "GET", server.URL+"/v1/instances/local", ""), http.StatusNotFound)
+	expectError(t, doRequest(t, "DELETE", server.URL+"/v1/instances/local/db-9", ""), http.StatusNotFound)
+	expectError(t, doRequest(t, "PATCH", server.URL+"/v1/instances/local/db-0", `{"labels":`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "PATCH", server.URL+"/v1/instances/local/db-0", `{"options":{"hidden":true}}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "PUT", server.URL+"/v1/instances/local/db-0", `{"name":"db-1"}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "PUT", server.URL+"/v1/instances/other/db-0", `{}`), http.StatusConflict)
+
+	res = doRequest(t, "POST", server.URL+"/v1/instances/local/db-0", "")
+	if allow := res.Header.Get("Allow"); allow != "GET, PUT, PATCH, DELETE" {
+		t.Errorf("expected the allowed methods to be listed, got %q", allow)
+	}
+	expectError(t, res, http.StatusMethodNotAllowed)
+}
+
+func TestCollectionErrors(t *testing.T) {
+	server, _ := newTestServer()
+	defer server.Close()
+
+	expectError(t, doRequest(t, "POST", server.URL+"/instances", `{"kind":`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "POST", server.URL+"/v1/instances", `{"kind":"Destroy","name":"db-1","zone":"local"}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "POST", server.URL+"/instances", `{"kind":"Register","name":"db-0","zone":"local"}`), http.StatusConflict)
+	expectError(t, doRequest(t, "POST", server.URL+"/instances", `{"kind":"Create","name":"db-1","zone":"local","version":"3.2"}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "DELETE", server.URL+"/instances", `{"zone":"local","name":"db-9"}`), http.StatusNotFound)
+	expectError(t, doRequest(t, "DELETE", server.URL+"/instances", `not json`), http.StatusBadRequest)
+	expectError(t, doRequest(t, "PUT", server.URL+"/instances", ""), http.StatusMethodNotAllowed)
+
+	// the daemon is still serving after every malformed request
+	res := doRequest(t, "GET", server.URL+"/v1/instances", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected the collection to still be served, got %s", res.Status)
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	kube "github.com/cpg1111/kubongo/kubeClient"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+	"golang.org/x/net/context"
+)
+
+// LagThresholds decide when a secondary is lagging, lagging secondaries are not published for secondary reads
+// and are never promoted
+type LagThresholds struct {
+	// MaxLag is the most a secondary may be behind the primary, 0 disables it
+	MaxLag time.Duration `json:"maxLag"`
+	// MinOplogHeadroom is the least a secondary's lag may be below the primary's oplog window, a secondary that falls
+	// off the oplog has to resync from scratch, 0 disables it
+	MinOplogHeadroom time.Duration `json:"minOplogHeadroom"`
+}
+
+// DefaultLagThresholds allow 30s of lag and want an hour of oplog to spare
+func DefaultLagThresholds() LagThresholds {
+	return LagThresholds{MaxLag: 30 * time.Second, MinOplogHeadroom: time.Hour}
+}
+
+// lagging returns why a secondary lag behind a primary with an oplog of window crosses t, or "" if it does not
+func (t LagThresholds) lagging(lag, window time.Duration) string {
+	if t.MaxLag > 0 && lag > t.MaxLag {
+		return fmt.Sprintf("%v behind the primary, more than %v", lag, t.MaxLag)
+	}
+	if t.MinOplogHeadroom > 0 && window > 0 && window-lag < t.MinOplogHeadroom {
+		return fmt.Sprintf("%v behind a %v oplog window, less than %v to spare", lag, window, t.MinOplogHeadroom)
+	}
+	return ""
+}
+
+// MemberLag is how far a secondary was behind the primary when last measured
+type MemberLag struct {
+	Host    string        `json:"host"`
+	Lag     time.Duration `json:"lag"`
+	Lagging bool          `json:"lagging"`
+	Reason  string        `json:"reason,omitempty"`
+	// Since is when Lagging last changed
+	Since     time.Time `json:"since"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Replication is the replica set's lag as last measured on its primary
+type Replication struct {
+	Primary     string        `json:"primary"`
+	OplogWindow time.Duration `json:"oplogWindow"`
+	Members     []MemberLag   `json:"members"`
+	CheckedAt   time.Time     `json:"checkedAt"`
+}
+
+// lagTracker keeps the last measured lag of every secondary
+type lagTracker struct {
+	mutex       sync.Mutex
+	primary     string
+	oplogWindow time.Duration
+	checkedAt   time.Time
+	members     map[string]*MemberLag
+}
+
+func newLagTracker() *lagTracker {
+	return &lagTracker{members: make(map[string]*MemberLag)}
+}
+
+// update replaces the measured lags with lags, it returns the hosts whose Lagging changed
+func (l *lagTracker) update(primary string, window time.Duration, lags map[string]time.Duration, thresholds LagThresholds, now time.Time) []MemberLag {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	changed := []MemberLag{}
+	members := make(map[string]*MemberLag)
+	for host, lag := range lags {
+		reason := thresholds.lagging(lag, window)
+		current := &MemberLag{Host: host, Lag: lag, Lagging: reason != "", Reason: reason, Since: now, CheckedAt: now}
+		previous, known := l.members[host]
+		if known && previous.Lagging == current.Lagging {
+			current.Since = previous.Since
+		} else if known || current.Lagging {
+			changed = append(changed, *current)
+		}
+		members[host] = current
+	}
+	// a member that is no longer a secondary, e.g. the new primary, is not lagging anymore
+	for host, previous := range l.members {
+		if _, still := members[host]; !still && previous.Lagging {
+			changed = append(changed, MemberLag{Host: host, Since: now, CheckedAt: now})
+		}
+	}
+	l.primary, l.oplogWindow, l.checkedAt, l.members = primary, window, now, members
+	return changed
+}
+
+func (l *lagTracker) lagging(host string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	member, ok := l.members[host]
+	return ok && member.Lagging
+}
+
+func (l *lagTracker) member(host string) (MemberLag, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	member, ok := l.members[host]
+	if !ok {
+		return MemberLag{}, false
+	}
+	return *member, true
+}
+
+func (l *lagTracker) replication() Replication {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	hosts := make([]string, 0, len(l.members))
+	for host := range l.members {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	replication := Replication{Primary: l.primary, OplogWindow: l.oplogWindow, CheckedAt: l.checkedAt, Members: make([]MemberLag, len(hosts))}
+	for i, host := range hosts {
+		replication.Members[i] = *l.members[host]
+	}
+	return replication
+}
+
+// secondaryLags is how far every SECONDARY in a replSetGetStatus reply is behind its PRIMARY
+func secondaryLags(status mongoWire.Doc) map[string]time.Duration {
+	var primary time.Time
+	secondaries := make(map[string]time.Time)
+	for _, raw := range status.Array("members") {
+		member, _ := raw.(mongoWire.Doc)
+		optime, _ := member.Get("optimeDate").(time.Time)
+		switch member.String("stateStr") {
+		case "PRIMARY":
+			primary = optime
+		case "SECONDARY":
+			secondaries[member.String("name")] = optime
+		}
+	}
+	lags := make(map[string]time.Duration)
+	for host, optime := range secondaries {
+		lag := time.Duration(0)
+		if !primary.IsZero() && primary.After(optime) {
+			lag = primary.Sub(optime)
+		}
+		lags[host] = lag
+	}
+	return lags
+}
+
+// checkReplication measures every secondary's lag and the oplog window on primary, secondaries that start or stop
+// lagging are republished
+func (m *Manager) checkReplication(primary string) error {
+	conn, dialErr := dial(primary, m.Prober.Config().Timeout)
+	if dialErr != nil {
+		return dialErr
+	}
+	defer conn.Close()
+	status, statusErr := conn.RunCommand("admin", mongoWire.Doc{{Key: "replSetGetStatus", Value: 1}})
+	if statusErr != nil {
+		return statusErr
+	}
+	window := oplogWindow(conn)
+	changed := m.lag.update(primary, window, secondaryLags(status), m.LagThresholds, time.Now())
+	for _, member := range changed {
+		if member.Lagging {
+			log.Println("lag:187", member.Host, "is lagging,", member.Reason)
+		} else {
+			log.Println("lag:189", member.Host, "caught up with the primary")
+		}
+	}
+	if len(changed) > 0 {
+		m.publish()
+	}
+	return nil
+}
+
+// Replication returns the replica set's lag as last measured by Monitor
+func (m *Manager) Replication() Replication {
+	return m.lag.replication()
+}
+
+// Lagging returns true if the member at host was lagging when last measured
+func (m *Manager) Lagging(host string) bool {
+	return m.lag.lagging(host)
+}
+
+// secondaryEndpointInstances is what the secondary reads Service points at, every secondary that is not lagging
+func (m *Manager) secondaryEndpointInstances() metadata.Instances {
+	secondaries := metadata.Instances{}
+	for _, member := range m.members() {
+		if member.Role == RoleSecondary && !m.Lagging(member.Host) {
+			secondaries = append(secondaries, member)
+		}
+	}
+	return secondaries
+}
+
+// SecondaryServiceSuffix is appended to the Service name to name the Service for secondary reads
+const SecondaryServiceSuffix = "-secondary"
+
+// publishSecondaries points the secondary reads Service at every secondary that is not lagging
+func (m *Manager) publishSecondaries() {
+	started := time.Now()
+	secondaries := m.secondaryEndpointInstances()
+	serviceName := m.kubeCtl.ServiceName + SecondaryServiceSuffix
+	pubErr := m.kubeCtl.WithServiceName(serviceName).UpdateServiceEndPoint(secondaries)
+	if pubErr != nil {
+		log.Println("lag:229 could not update Kubernetes endpoints for secondary reads:", pubErr)
+		m.event(kube.EventWarning, ReasonEndpointsUpdateFailed, "could not update the endpoints of "+serviceName+": "+pubErr.Error())
+	}
+	addresses := []string{}
+	for _, instance := range secondaries {
+		addresses = append(addresses, instance.GetInternalIP())
+	}
+	m.audit(context.Background(), "update endpoints", serviceName, started, addresses, pubErr)
+}
+
+// electable returns true if member may become primary by its replica set options
+func electable(member *metadata.Member) bool {
+	opts := member.Options
+	return !opts.Hidden && !opts.ArbiterOnly && (opts.Priority == nil || *opts.Priority != 0)
+}
+
+// promotable are the electable members that were not lagging when last measured
+func (m *Manager) promotable(members []*metadata.Member) []*metadata.Member {
+	candidates := []*metadata.Member{}
+	for _, member := range members {
+		if electable(member) && !m.Lagging(member.Host) {
+			candidates = append(candidates, member)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+func TestLagThresholds(t *testing.T) {
+	thresholds := LagThresholds{MaxLag: 30 * time.Second, MinOplogHeadroom: time.Hour}
+	if reason := thresholds.lagging(10*time.Second, 2*time.Hour); reason != "" {
+		t.Errorf("expected 10s of lag on a 2h oplog to be fine, got %s", reason)
+	}
+	if reason := thresholds.lagging(time.Minute, 2*time.Hour); reason == "" {
+		t.Error("expected a minute of lag to cross a 30s MaxLag")
+	}
+	if reason := thresholds.lagging(10*time.Second, 30*time.Minute); reason == "" {
+		t.Error("expected a 30m oplog window to leave less than an hour to spare")
+	}
+	if reason := (LagThresholds{}).lagging(24*time.Hour, time.Minute); reason != "" {
+		t.Errorf("expected zero thresholds to disable lag checks, got %s", reason)
+	}
+}
+
+// newLagManager has a primary and two secondaries, db-2 is 2m behind, the primary answers replSetGetStatus
+func newLagManager(t *testing.T) (*Manager, *mongoWire.FakeServer) {
+	primary, serverErr := mongoWire.NewFakeServer()
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	optime := time.Now().UTC().Truncate(time.Second)
+	primary.Handle("replSetGetStatus", func(cmd mongoWire.Doc) mongoWire.Doc {
+		return ok(mongoWire.Doc{{Key: "members", Value: []interface{}{
+			mongoWire.Doc{{Key: "name", Value: primary.Addr()}, {Key: "stateStr", Value: "PRIMARY"}, {Key: "optimeDate", Value: optime}},
+			mongoWire.Doc{{Key: "name", Value: "10.0.0.1:27017"}, {Key: "stateStr", Value: "SECONDARY"}, {Key: "optimeDate", Value: optime.Add(-time.Second)}},
+			mongoWire.Doc{{Key: "name", Value: "10.0.0.2:27017"}, {Key: "stateStr", Value: "SECONDARY"}, {Key: "optimeDate", Value: optime.Add(-2 * time.Minute)}},
+		}}})
+	})
+	var host hostProvider.HostProvider = hostProvider.NewLocal()
+	manager := NewManager("test", "local", &host, metadata.NewRegistry())
+	manager.ReplicaSet = "rs0"
+	manager.LagThresholds = LagThresholds{MaxLag: 30 * time.Second}
+	for i, memberHost := range []string{primary.Addr(), "10.0.0.1:27017", "10.0.0.2:27017"} {
+		role := RoleSecondary
+		if i == 0 {
+			role = RolePrimary
+		}
+		name := "db-" + strconv.Itoa(i)
+		manager.Registry.Add(&metadata.Member{
+			Instance:   &hostProvider.LocalInstance{Name: name, Zone: "local", IP: strings.Split(memberHost, ":")[0]},
+			ReplicaSet: "rs0",
+			Host:       memberHost,
+			Role:       role,
+		}, nil)
+	}
+	return manager, primary
+}
+
+func TestCheckReplication(t *testing.T) {
+	manager, primary := newLagManager(t)
+	defer primary.Close()
+	checkErr := manager.checkReplication(primary.Addr())
+	if checkErr != nil {
+		t.Fatal(checkErr)
+	}
+	if manager.Lagging("10.0.0.1:27017") || !manager.Lagging("10.0.0.2:27017") {
+		t.Errorf("expected only db-2 to be lagging, got %+v", manager.Replication())
+	}
+	replication := manager.Replication()
+	if replication.Primary != primary.Addr() || len(replication.Members) != 2 || replication.Members[1].Lag != 2*time.Minute {
+		t.Errorf("expected both secondaries' lag to be measured on the primary, got %+v", replication)
+	}
+	secondaries := manager.secondaryEndpointInstances()
+	if len(secondaries) != 1 || secondaries[0].GetName() != "db-1" {
+		t.Errorf("expected only db-1 to be published for secondary reads, got %v", secondaries)
+	}
+
+	manager.LagThresholds.MaxLag = 5 * time.Minute
+	manager.checkReplication(primary.Addr())
+	if manager.Lagging("10.0.0.2:27017") || len(manager.secondaryEndpointInstances()) != 2 {
+		t.Errorf("expected db-2 to catch up under a 5m MaxLag, got %+v", manager.Replication())
+	}
+}
+
+func TestFailoverRefusesLaggingMembers(t *testing.T) {
+	manager, primary := newLagManager(t)
+	defer primary.Close()
+	manager.LagThresholds.MaxLag = time.Nanosecond
+	manager.checkReplication(primary.Addr())
+	if promotable := manager.promotable(manager.members()); len(promotable) != 1 || promotable[0].Host != primary.Addr() {
+		t.Errorf("expected only the primary to be promotable, got %v", promotable)
+	}
+	_, failErr := manager.failover(primary.Addr())
+	if failErr == nil || !strings.Contains(failErr.Error(), "lagging") {
+		t.Errorf("expected failing over to only lagging secondaries to be refused, got %v", failErr)
+	}
+	for _, cmd := range primary.Received() {
+		if len(cmd) > 0 && cmd[0].Key == "replSetStepDown" {
+			t.Error("expected the primary not to be stepped down when no member can replace it")
+		}
+	}
+}
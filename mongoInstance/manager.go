@@ -15,14 +15,22 @@ package mongoInstance
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cpg1111/kubongo/audit"
 	"github.com/cpg1111/kubongo/hostProvider"
 	kube "github.com/cpg1111/kubongo/kubeClient"
 	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+	"github.com/cpg1111/kubongo/notify"
+	"golang.org/x/net/context"
 )
 
 // Manager manages mongo instances
@@ -33,14 +41,133 @@ type Manager struct {
 	Platform string
 	// Struct to Controls cloud provider actions
 	platformCtl hostProvider.HostProvider
-	// list of registered instances
-	data *metadata.Instances
+	// Registry holds every registered instance
+	Registry *metadata.Registry
 	// controller for talking to the Kubernetes api
 	kubeCtl *kube.Controller
+	// ReplicaSet is the name of the replica set created instances join, empty leaves them standalone
+	ReplicaSet string
+	// BootstrapTimeout is how long to wait for a new instance's mongod to answer before initiating or adding it
+	BootstrapTimeout time.Duration
+	// ElectionTimeout is how long failover waits for the replica set to elect a primary before forcing one
+	ElectionTimeout time.Duration
+	// Prober tracks the health of every registered instance while Monitor runs
+	Prober *Prober
+	// Policy guards automatic failovers
+	Policy FailoverPolicy
+	// LagThresholds mark secondaries lagging while Monitor runs
+	LagThresholds LagThresholds
+	// Operations are the Manager's long running changes started over http
+	Operations *Operations
+	// Audit records every create, register, remove, failover and endpoint update, nil records nothing
+	Audit *audit.Log
+	// Notifier is told about instances going down and recovering, failovers and provider errors, nil tells no one
+	Notifier *notify.Notifier
+	// down are the instances Notifier was told are down
+	down     map[string]bool
+	downLock sync.Mutex
+	// reserved are the names of instances being created or registered
+	reserved     map[string]bool
+	reservedLock sync.Mutex
+	history      *failoverHistory
+	events       *broadcaster
+	lag          *lagTracker
+	// reconciled is set once Reconcile has checked every restored instance
+	reconciled int32
 }
 
-func addToInstances(instances *metadata.Instances, newServer hostProvider.Instance) {
-	instances = metadata.AddInstance(instances, newServer)
+// DefaultBootstrapTimeout is how long a new instance gets to boot, GCE instances take minutes
+const DefaultBootstrapTimeout = 5 * time.Minute
+
+// ClusterLabel is the registry label holding the name of the MongoCluster an instance was created for. Such instances
+// form a replica set named after their cluster and are left out of the Manager's own replica set, Endpoints and connection string.
+const ClusterLabel = "kubongo.io/mongocluster"
+
+// reserve claims names for instances that are about to be created or registered, failing with a ConflictError if
+// one is registered or claimed already. Calling the returned func gives them up once they are registered or abandoned.
+func (m *Manager) reserve(names ...string) (func(), error) {
+	m.reservedLock.Lock()
+	defer m.reservedLock.Unlock()
+	for _, name := range names {
+		if _, exists := m.Registry.Get(name); exists || m.reserved[name] {
+			return nil, &metadata.ConflictError{Name: name}
+		}
+	}
+	for _, name := range names {
+		m.reserved[name] = true
+	}
+	return func() {
+		m.reservedLock.Lock()
+		defer m.reservedLock.Unlock()
+		for _, name := range names {
+			delete(m.reserved, name)
+		}
+	}, nil
+}
+
+// publish rewrites the Kubernetes Endpoints and connection string for mongo with the currently registered instances
+func (m *Manager) publish() {
+	if m.kubeCtl == nil {
+		return
+	}
+	started := time.Now()
+	endpoints := m.endpointInstances()
+	pubErr := m.kubeCtl.UpdateServiceEndPoint(endpoints)
+	if pubErr != nil {
+		log.Println("manager:117 could not update Kubernetes endpoints:", pubErr)
+		m.event(kube.EventWarning, ReasonEndpointsUpdateFailed, "could not update the endpoints of "+m.kubeCtl.ServiceName+": "+pubErr.Error())
+	}
+	addresses := []string{}
+	for _, instance := range endpoints {
+		addresses = append(addresses, instance.GetInternalIP())
+	}
+	m.audit(context.Background(), "update endpoints", m.kubeCtl.ServiceName, started, addresses, pubErr)
+	if m.ReplicaSet != "" {
+		m.publishSecondaries()
+	}
+	connErr := m.kubeCtl.UpdateConnectionString(m.ownInstances())
+	if connErr != nil {
+		log.Println("manager:130 could not update Kubernetes connection string:", connErr)
+		m.event(kube.EventWarning, ReasonConnectionStringUpdateFailed, "could not update the connection string of "+m.kubeCtl.ConfigName()+": "+connErr.Error())
+	}
+}
+
+// endpointInstances is what the Service's Endpoints point at, the primary if the replica set has one and every instance otherwise
+func (m *Manager) endpointInstances() metadata.Instances {
+	own := m.ownInstances()
+	for _, member := range m.members() {
+		if member.Role == RolePrimary {
+			return metadata.Instances{member}
+		}
+	}
+	return own
+}
+
+// ownInstances are the registered instances that do not belong to a MongoCluster
+func (m *Manager) ownInstances() metadata.Instances {
+	own := metadata.Instances{}
+	for _, instance := range m.Registry.List() {
+		if labels, _ := m.Registry.Labels(instance.GetName()); labels[ClusterLabel] == "" {
+			own = append(own, instance)
+		}
+	}
+	return own
+}
+
+// replicaSetOf returns the replica set an instance with labels joins and its current members,
+// MongoCluster instances join the replica set of their cluster and every other instance the Manager's
+func (m *Manager) replicaSetOf(labels map[string]string) (string, []*metadata.Member) {
+	cluster := labels[ClusterLabel]
+	if cluster == "" {
+		return m.ReplicaSet, m.members()
+	}
+	members := []*metadata.Member{}
+	for _, instance := range m.Registry.ByLabels(map[string]string{ClusterLabel: cluster}) {
+		if member, ok := instance.(*metadata.Member); ok {
+			members = append(members, member)
+		}
+	}
+	return cluster, members
 }
 
 // SetKubeCtl sets the kubernetes api controller, this is not done in the New() function so that the manager depends souly on mongo-side things
@@ -49,51 +176,521 @@ func (m *Manager) SetKubeCtl(ktl *kube.Controller) {
 	m.kubeCtl = ktl
 }
 
-// Create a new mongo instance
-func (m *Manager) Create(newInstanceTmpl *InstanceTemplate, instances *metadata.Instances) ([]byte, error) {
-	newServer, serverErr := m.platformCtl.CreateServer(
-		m.Platform,
-		newInstanceTmpl.Zone,
-		newInstanceTmpl.Name,
-		newInstanceTmpl.MachineType,
-		newInstanceTmpl.SourceImage,
-		newInstanceTmpl.Source,
-	)
+// Steps of the Manager's operations
+const (
+	stepCreateServer    = "create server"
+	stepFindServer      = "find server"
+	stepDeleteServer    = "delete server"
+	stepJoinReplicaSet  = "join replica set"
+	stepLeaveReplicaSet = "leave replica set"
+	stepInitiate        = "initiate replica set"
+	stepRegister        = "register"
+	stepUnregister      = "unregister"
+)
+
+// StartCreate starts an operation for actor that creates tmpl, or a replica set if tmpl.Members is more than 1,
+// or registers it depending on its kind. tmpl should be validated first.
+func (m *Manager) StartCreate(tmpl *InstanceTemplate, actor string) Operation {
+	target := tmpl.Zone + "/" + tmpl.Name
+	switch {
+	case tmpl.Kind == "Create" && tmpl.Members > 1:
+		return m.Operations.Start("createReplicaSet", target, actor, func(ctx context.Context) ([]byte, error) {
+			return m.CreateReplicaSetContext(ctx, tmpl, tmpl.Members)
+		})
+	case tmpl.Kind == "Create":
+		return m.Operations.Start("create", target, actor, func(ctx context.Context) ([]byte, error) {
+			return m.CreateContext(ctx, tmpl)
+		})
+	}
+	return m.Operations.Start("register", target, actor, func(ctx context.Context) ([]byte, error) {
+		registered, regErr := m.RegisterContext(ctx, tmpl.Zone, tmpl.Name)
+		if regErr == nil && len(tmpl.Labels) > 0 {
+			regErr = m.Registry.SetLabels(tmpl.Name, tmpl.Labels)
+		}
+		return registered, regErr
+	})
+}
+
+// Create a new mongo instance, it is added to the replica set if the Manager has one
+func (m *Manager) Create(newInstanceTmpl *InstanceTemplate) ([]byte, error) {
+	return m.CreateContext(context.Background(), newInstanceTmpl)
+}
+
+// CreateContext creates an instance like Create, recording its steps if ctx belongs to an operation.
+// Cancelling ctx stops it until the platform has created the server, after that it runs to the end.
+func (m *Manager) CreateContext(ctx context.Context, newInstanceTmpl *InstanceTemplate) (created []byte, err error) {
+	started := time.Now()
+	defer func() {
+		m.audit(ctx, "create", newInstanceTmpl.Zone+"/"+newInstanceTmpl.Name, started, newInstanceTmpl, err)
+		m.actionEvent("create", newInstanceTmpl.Zone+"/"+newInstanceTmpl.Name, err)
+	}()
+	if newInstanceTmpl.Version != "" {
+		return nil, errVersionUnsupported
+	}
+	release, reserveErr := m.reserve(newInstanceTmpl.Name)
+	if reserveErr != nil {
+		return nil, reserveErr
+	}
+	defer release()
+	replicaSet, _ := m.replicaSetOf(newInstanceTmpl.Labels)
+	steps := []string{stepCreateServer}
+	if replicaSet != "" {
+		steps = append(steps, stepJoinReplicaSet)
+	}
+	planSteps(ctx, append(steps, stepRegister)...)
+	if stepErr := beginStep(ctx, stepCreateServer); stepErr != nil {
+		return nil, stepErr
+	}
+	newServer, serverErr := m.createServer(ctx, newInstanceTmpl)
 	if serverErr != nil {
 		return nil, serverErr
 	}
-	addToInstances(instances, newServer)
-	m.data = instances
+	ctx = detach(ctx)
+	var joinErr error
+	if replicaSet != "" {
+		beginStep(ctx, stepJoinReplicaSet)
+		newServer, joinErr = m.joinReplicaSet(newServer, newInstanceTmpl)
+	}
+	beginStep(ctx, stepRegister)
+	addErr := m.Registry.Add(newServer, newInstanceTmpl.Labels)
+	if addErr != nil {
+		return nil, addErr
+	}
+	if joinErr != nil {
+		return nil, joinErr
+	}
+	_, members := m.replicaSetOf(newInstanceTmpl.Labels)
+	m.refreshMemberRoles(members)
+	m.publish()
 	newServerJSON, jErr := json.Marshal(&newServer)
 	return newServerJSON, jErr
 }
 
+// createServer creates tmpl on the platform, following the platform's operation through ctx if it can
+func (m *Manager) createServer(ctx context.Context, tmpl *InstanceTemplate) (hostProvider.Instance, error) {
+	var (
+		instance  hostProvider.Instance
+		createErr error
+	)
+	if platform, follows := m.platformCtl.(hostProvider.ContextHostProvider); follows {
+		instance, createErr = platform.CreateServerContext(ctx, m.Project, tmpl.Zone, tmpl.Name, tmpl.MachineType, tmpl.SourceImage, tmpl.Source)
+	} else {
+		instance, createErr = m.platformCtl.CreateServer(
+			m.Project,
+			tmpl.Zone,
+			tmpl.Name,
+			tmpl.MachineType,
+			tmpl.SourceImage,
+			tmpl.Source,
+		)
+	}
+	return instance, m.providerCall("create", createErr)
+}
+
+// deleteServer deletes name from the platform, following the platform's operation through ctx if it can
+func (m *Manager) deleteServer(ctx context.Context, zone, name string) error {
+	if platform, follows := m.platformCtl.(hostProvider.ContextHostProvider); follows {
+		return m.providerCall("delete", platform.DeleteServerContext(ctx, m.Project, zone, name))
+	}
+	return m.providerCall("delete", m.platformCtl.DeleteServer(m.Project, zone, name))
+}
+
+// CreateReplicaSet creates count instances named <name>-<i> from the template and initiates the Manager's replica set on them
+func (m *Manager) CreateReplicaSet(newInstanceTmpl *InstanceTemplate, count int) ([]byte, error) {
+	return m.CreateReplicaSetContext(context.Background(), newInstanceTmpl, count)
+}
+
+// CreateReplicaSetContext creates a replica set like CreateReplicaSet, recording its steps if ctx belongs to an operation.
+// Cancelling ctx stops it before the next server is created, servers that were already created stay registered.
+func (m *Manager) CreateReplicaSetContext(ctx context.Context, newInstanceTmpl *InstanceTemplate, count int) (created []byte, err error) {
+	started := time.Now()
+	defer func() {
+		m.audit(ctx, "create replica set", newInstanceTmpl.Zone+"/"+newInstanceTmpl.Name, started, newInstanceTmpl, err)
+		m.actionEvent("create replica set", newInstanceTmpl.Zone+"/"+newInstanceTmpl.Name, err)
+	}()
+	if newInstanceTmpl.Version != "" {
+		return nil, errVersionUnsupported
+	}
+	if m.ReplicaSet == "" {
+		return nil, errors.New("no replica set name was configured")
+	}
+	if len(m.members()) > 0 {
+		return nil, fmt.Errorf("replica set %s already has members, create instances one at a time to add to it", m.ReplicaSet)
+	}
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", newInstanceTmpl.Name, i)
+	}
+	release, reserveErr := m.reserve(names...)
+	if reserveErr != nil {
+		return nil, reserveErr
+	}
+	defer release()
+	steps := []string{}
+	for i := 0; i < count; i++ {
+		steps = append(steps, stepCreateServer+" "+names[i])
+	}
+	planSteps(ctx, append(steps, stepInitiate)...)
+	members := []*metadata.Member{}
+	for i := 0; i < count; i++ {
+		memberTmpl := *newInstanceTmpl
+		memberTmpl.Name = names[i]
+		if stepErr := beginStep(ctx, steps[i]); stepErr != nil {
+			return nil, stepErr
+		}
+		newServer, serverErr := m.createServer(ctx, &memberTmpl)
+		if serverErr != nil {
+			return nil, serverErr
+		}
+		member := &metadata.Member{
+			Instance:   newServer,
+			ReplicaSet: m.ReplicaSet,
+			MemberID:   i,
+			Host:       memberHost(newServer),
+			Role:       RoleUnknown,
+			Options:    newInstanceTmpl.Options,
+			Origin:     newInstanceTmpl.origin(),
+		}
+		addErr := m.Registry.Add(member, newInstanceTmpl.Labels)
+		if addErr != nil {
+			return nil, addErr
+		}
+		members = append(members, member)
+	}
+	beginStep(detach(ctx), stepInitiate)
+	initErr := initiate(m.ReplicaSet, members, m.BootstrapTimeout)
+	if initErr != nil {
+		return nil, initErr
+	}
+	m.refreshRoles()
+	m.publish()
+	return json.Marshal(members)
+}
+
+// members returns the instances that belong to the Manager's replica set
+func (m *Manager) members() []*metadata.Member {
+	members := []*metadata.Member{}
+	for _, instance := range m.ownInstances() {
+		member, ok := instance.(*metadata.Member)
+		if ok && member.ReplicaSet == m.ReplicaSet {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+func memberAddresses(members []*metadata.Member) []string {
+	addresses := make([]string, len(members))
+	for i := range members {
+		addresses[i] = members[i].Host
+	}
+	return addresses
+}
+
+// origin is what an instance created from tmpl is created from
+func (tmpl *InstanceTemplate) origin() metadata.Origin {
+	return metadata.Origin{MachineType: tmpl.MachineType, SourceImage: tmpl.SourceImage, Source: tmpl.Source}
+}
+
+// joinReplicaSet initiates the replica set of tmpl's instances with newServer as its seed if it has no members yet
+// and adds newServer to it otherwise
+func (m *Manager) joinReplicaSet(newServer hostProvider.Instance, tmpl *InstanceTemplate) (*metadata.Member, error) {
+	replicaSet, existing := m.replicaSetOf(tmpl.Labels)
+	member := &metadata.Member{
+		Instance:   newServer,
+		ReplicaSet: replicaSet,
+		Host:       memberHost(newServer),
+		Role:       RoleUnknown,
+		Options:    tmpl.Options,
+		Origin:     tmpl.origin(),
+	}
+	if len(existing) == 0 {
+		return member, initiate(replicaSet, []*metadata.Member{member}, m.BootstrapTimeout)
+	}
+	primary, primaryErr := findPrimary(memberAddresses(existing))
+	if primaryErr != nil {
+		return member, primaryErr
+	}
+	waitErr := waitForMongod(member.Host, m.BootstrapTimeout)
+	if waitErr != nil {
+		return member, waitErr
+	}
+	log.Println("manager:418 adding", member.Host, "to", replicaSet)
+	return member, addMember(primary, member)
+}
+
+// leaveReplicaSet removes member from the replica set config of an instance with labels, stepping it down first if it is the primary
+func (m *Manager) leaveReplicaSet(member *metadata.Member, labels map[string]string) error {
+	_, members := m.replicaSetOf(labels)
+	others := []string{}
+	for _, other := range members {
+		if other.Host != member.Host {
+			others = append(others, other.Host)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	primary, primaryErr := findPrimary(append(others, member.Host))
+	if primaryErr != nil {
+		return primaryErr
+	}
+	if primary == member.Host {
+		log.Println("manager:439 stepping down", member.Host, "before removing it from", member.ReplicaSet)
+		stepErr := stepDown(primary, 60)
+		if stepErr != nil {
+			return stepErr
+		}
+		deadline := time.Now().Add(m.BootstrapTimeout)
+		for primary, primaryErr = findPrimary(others); primaryErr != nil || primary == member.Host; primary, primaryErr = findPrimary(others) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("no new primary was elected after %s stepped down", member.Host)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return removeMember(primary, member.Host)
+}
+
+// refreshRoles records the role of every member of the Manager's replica set as reported by the primary
+func (m *Manager) refreshRoles() {
+	m.refreshMemberRoles(m.members())
+}
+
+// refreshMemberRoles records the role of every one of members as reported by their primary
+func (m *Manager) refreshMemberRoles(members []*metadata.Member) {
+	if len(members) == 0 {
+		return
+	}
+	primary, primaryErr := findPrimary(memberAddresses(members))
+	if primaryErr != nil {
+		log.Println("manager:467 could not refresh replica set roles:", primaryErr)
+		return
+	}
+	roles, rolesErr := memberRoles(primary)
+	if rolesErr != nil {
+		log.Println("manager:472 could not refresh replica set roles:", rolesErr)
+		return
+	}
+	for i := range members {
+		if role, ok := roles[members[i].Host]; ok && role != members[i].Role {
+			updated := *members[i]
+			updated.Role = role
+			m.Registry.Update(&updated)
+		}
+	}
+}
+
 // Register an existing mongo instance
-func (m *Manager) Register(zone, name string, instances *metadata.Instances) ([]byte, error) {
+func (m *Manager) Register(zone, name string) ([]byte, error) {
+	return m.RegisterContext(context.Background(), zone, name)
+}
+
+// RegisterContext registers an instance like Register, recording its steps if ctx belongs to an operation
+func (m *Manager) RegisterContext(ctx context.Context, zone, name string) (registered []byte, err error) {
+	started := time.Now()
+	defer func() {
+		m.audit(ctx, "register", zone+"/"+name, started, nil, err)
+		m.actionEvent("register", zone+"/"+name, err)
+	}()
+	release, reserveErr := m.reserve(name)
+	if reserveErr != nil {
+		return nil, reserveErr
+	}
+	defer release()
+	steps := []string{stepFindServer}
+	if m.ReplicaSet != "" {
+		steps = append(steps, stepJoinReplicaSet)
+	}
+	planSteps(ctx, append(steps, stepRegister)...)
+	if stepErr := beginStep(ctx, stepFindServer); stepErr != nil {
+		return nil, stepErr
+	}
 	var (
 		newServer hostProvider.Instance
 		serverErr error
 	)
 	if strings.Contains(zone, "local") {
-		newServer, serverErr = m.platformCtl.CreateServer(m.Platform, zone, name, "27017", "mongo", "mongo")
+		newServer, serverErr = m.platformCtl.CreateServer(m.Project, zone, name, "27017", "mongo", "mongo")
+		m.providerCall("create", serverErr)
 	} else {
-		newServer, serverErr = m.platformCtl.GetServer(m.Platform, zone, name)
+		newServer, serverErr = m.platformCtl.GetServer(m.Project, zone, name)
+		m.providerCall("get", serverErr)
 	}
 	if serverErr != nil {
 		return nil, serverErr
 	}
-	addToInstances(instances, newServer)
-	m.data = instances
+	if m.ReplicaSet != "" {
+		beginStep(ctx, stepJoinReplicaSet)
+		newServer = m.registerMember(newServer)
+	}
+	beginStep(ctx, stepRegister)
+	addErr := m.Registry.Add(newServer, nil)
+	if addErr != nil {
+		return nil, addErr
+	}
+	m.publish()
 	newServerJSON, jErr := json.Marshal(&newServer)
 	return newServerJSON, jErr
 }
 
-// Remove existing mongo instance
+// Reconcile re-probes every registered instance after kubongo restarts, refreshing cloud instances from their platform
+// and the roles of replica set members, then publishes them
+func (m *Manager) Reconcile() {
+	for _, instance := range m.Registry.List() {
+		name := instance.GetName()
+		// local processes are not known to a fresh LocalHost, only cloud platforms can be asked again
+		if m.Platform != "local" {
+			fresh, getErr := m.platformCtl.GetServer(m.Project, instance.GetZone(), name)
+			m.providerCall("get", getErr)
+			if getErr != nil {
+				log.Println("manager:547 could not find", name, "on", m.Platform, getErr)
+			} else {
+				if member, isMember := instance.(*metadata.Member); isMember {
+					updated := *member
+					updated.Instance = fresh
+					fresh = &updated
+				}
+				m.Registry.Update(fresh)
+				instance = fresh
+			}
+		}
+		health := m.CheckHealth(memberHost(instance))
+		if health.Healthy {
+			log.Println("manager:560 restored", name, "as", health.Role)
+		} else {
+			log.Println("manager:562 restored", name, "but it fails its health check:", health.Error)
+		}
+	}
+	m.refreshRoles()
+	m.publish()
+	atomic.StoreInt32(&m.reconciled, 1)
+}
+
+// Reconciled returns true once Reconcile has checked every instance restored into the Registry
+func (m *Manager) Reconciled() bool {
+	return atomic.LoadInt32(&m.reconciled) == 1
+}
+
+// PingPlatform checks the platform is reachable and accepts the Manager's credentials,
+// platforms that cannot be pinged are assumed to be up
+func (m *Manager) PingPlatform() error {
+	if pinger, ok := m.platformCtl.(hostProvider.Pinger); ok {
+		return m.providerCall("ping", pinger.Ping())
+	}
+	return nil
+}
+
+// registerMember wraps an existing instance as a Member if its mongod says it belongs to the Manager's replica set
+func (m *Manager) registerMember(instance hostProvider.Instance) hostProvider.Instance {
+	host := memberHost(instance)
+	health := m.CheckHealth(host)
+	if health.ReplicaSet != m.ReplicaSet {
+		return instance
+	}
+	member := &metadata.Member{
+		Instance:   instance,
+		ReplicaSet: m.ReplicaSet,
+		MemberID:   -1,
+		Host:       host,
+		Role:       health.Role,
+	}
+	reply, confErr := runCommand(host, mongoWire.Doc{{Key: "replSetGetConfig", Value: 1}}, DefaultHealthCheckTimeout)
+	if confErr == nil {
+		for _, raw := range reply.Doc("config").Array("members") {
+			existing, _ := raw.(mongoWire.Doc)
+			if existing.String("host") == host {
+				member.MemberID = int(existing.Int("_id"))
+			}
+		}
+	}
+	return member
+}
+
+// Remove existing mongo instance, removing it from the replica set first if it is a member
 func (m *Manager) Remove(zone, name string) error {
-	dErr := m.platformCtl.DeleteServer(m.Platform, zone, name)
-	newData := metadata.RemoveInstance(*m.data, m.data.ToMap()[name])
-	m.data = &newData
-	return dErr
+	return m.RemoveContext(context.Background(), zone, name)
+}
+
+// RemoveContext removes an instance like Remove, recording its steps if ctx belongs to an operation.
+// Cancelling ctx only stops it before it starts.
+func (m *Manager) RemoveContext(ctx context.Context, zone, name string) (err error) {
+	started := time.Now()
+	defer func() {
+		m.audit(ctx, "remove", zone+"/"+name, started, nil, err)
+		m.actionEvent("remove", zone+"/"+name, err)
+	}()
+	instance, registered := m.Registry.Get(name)
+	if !registered {
+		return &metadata.NotFoundError{Name: name}
+	}
+	labels, _ := m.Registry.Labels(name)
+	member, isMember := instance.(*metadata.Member)
+	leave := isMember && member.ReplicaSet != ""
+	steps := []string{stepDeleteServer, stepUnregister}
+	if leave {
+		steps = append([]string{stepLeaveReplicaSet}, steps...)
+	}
+	planSteps(ctx, steps...)
+	if stepErr := beginStep(ctx, steps[0]); stepErr != nil {
+		return stepErr
+	}
+	ctx = detach(ctx)
+	if leave {
+		leaveErr := m.leaveReplicaSet(member, labels)
+		if _, left := leaveErr.(*notMemberError); left {
+			// an earlier attempt removed it from the replica set but could not delete its server
+			leaveErr = nil
+		}
+		if leaveErr != nil {
+			return leaveErr
+		}
+		beginStep(ctx, stepDeleteServer)
+	}
+	dErr := m.deleteServer(ctx, zone, name)
+	if dErr != nil {
+		// keep it registered so that removing it again retries the delete
+		log.Println("manager:653 could not delete", name, "keeping it registered:", dErr)
+		return dErr
+	}
+	beginStep(ctx, stepUnregister)
+	_, unregErr := m.Registry.Remove(name)
+	m.refreshRoles()
+	m.publish()
+	return unregErr
+}
+
+// StartRemove starts an operation for actor that removes zone/name
+func (m *Manager) StartRemove(zone, name, actor string) Operation {
+	return m.Operations.Start("delete", zone+"/"+name, actor, func(ctx context.Context) ([]byte, error) {
+		return nil, m.RemoveContext(ctx, zone, name)
+	})
+}
+
+// SetMemberOptions reconfigures the replica set with new options for the member called name
+func (m *Manager) SetMemberOptions(name string, opts metadata.MemberOptions) error {
+	instance, registered := m.Registry.Get(name)
+	if !registered {
+		return &metadata.NotFoundError{Name: name}
+	}
+	member, isMember := instance.(*metadata.Member)
+	if !isMember || m.ReplicaSet == "" {
+		return fmt.Errorf("%s is not a replica set member", name)
+	}
+	primary, primaryErr := findPrimary(memberAddresses(m.members()))
+	if primaryErr != nil {
+		return primaryErr
+	}
+	updated := *member
+	updated.Options = opts
+	log.Println("manager:686 reconfiguring", updated.Host, "in", m.ReplicaSet)
+	updateErr := updateMember(primary, &updated)
+	if updateErr != nil {
+		return updateErr
+	}
+	m.Registry.Update(&updated)
+	m.refreshRoles()
+	return nil
 }
 
 func localMasterTmpl() *InstanceTemplate {
@@ -122,68 +719,120 @@ func gcloudMasterTmpl() *InstanceTemplate {
 	return &InstanceTemplate{
 		Kind:        "Create",
 		Name:        "master",
+		Zone:        zone,
 		MachineType: machineType,
 		SourceImage: sourceImage,
 		Source:      "",
 	}
 }
 
-func (m *Manager) newMaster(rStatus, nStatus chan error, success chan []byte, instances *metadata.Instances) error {
-	log.Println("manager:132 NEWMASTER")
-	uncastMaster := m.data.ToMap()["master"]
-	if uncastMaster == nil {
-		rStatus <- nil
-	} else {
-		log.Println("manager:137 REMOVE")
-		master := uncastMaster.(hostProvider.LocalInstance)
-		rStatus <- m.Remove(master.Zone, master.Name)
-	}
-	newInstance := localMasterTmpl()
-	newBytes, nErr := m.Create(newInstance, instances)
-	if nErr != nil {
-		nStatus <- nErr
-	} else {
-		success <- newBytes
+// ec2MasterTmpl is gcloudMasterTmpl for EC2, MONGO_INSTANCE_OS must be the AMI to run
+func ec2MasterTmpl() *InstanceTemplate {
+	zone := os.Getenv("DEFAULT_ZONE")
+	if zone == "" {
+		zone = "us-east-1a"
+	}
+	machineType := os.Getenv("MASTER_MONGO_TYPE")
+	if machineType == "" {
+		machineType = "m4.xlarge"
+	}
+	return &InstanceTemplate{
+		Kind:        "Create",
+		Name:        "master",
+		Zone:        zone,
+		MachineType: machineType,
+		SourceImage: os.Getenv("MONGO_INSTANCE_OS"),
+		Source:      "",
 	}
-	return nil
 }
 
-// Monitor master mongo instance
-func (m *Manager) Monitor(masterIP *string, instances *metadata.Instances) error {
-	monitor := newMonitor(masterIP)
-	isHealthy := true
-	healthChannel := make(chan bool)
-	for isHealthy {
-		go monitor.HealthCheck(healthChannel)
-		isHealthy = <-healthChannel
-		log.Println("manager:159 health:", isHealthy)
-		time.Sleep(time.Second * 3)
-	}
-	removeStatus := make(chan error)
-	newMasterStatus := make(chan error)
-	successStatus := make(chan []byte)
-	go m.newMaster(removeStatus, newMasterStatus, successStatus, instances)
+// CheckHealth runs a single health check against address, an ip:port of a mongod
+func (m *Manager) CheckHealth(address string) Health {
+	return CheckHealth(address, DefaultHealthCheckTimeout)
+}
+
+// CheckInstanceHealth runs a single health check against the mongod of instance at the host it is registered with
+func (m *Manager) CheckInstanceHealth(instance hostProvider.Instance) Health {
+	return m.CheckHealth(memberHost(instance))
+}
+
+// probeAddresses maps the name of every registered instance with an address to the host:port it is probed at,
+// masterIP is probed under its own address if it is not a registered instance
+func probeAddresses(masterIP string, instances metadata.Instances) map[string]string {
+	addresses := make(map[string]string)
+	masterRegistered := false
+	for _, instance := range instances {
+		if instance == nil || instance.GetInternalIP() == "" {
+			continue
+		}
+		address := memberHost(instance)
+		addresses[instance.GetName()] = address
+		masterRegistered = masterRegistered || address == masterIP
+	}
+	if !masterRegistered && masterIP != "" {
+		addresses[masterIP] = masterIP
+	}
+	return addresses
+}
+
+// Monitor probes every registered instance and fails over whenever the primary at masterIP goes down,
+// masterIP follows the primary when the replica set elects a new one on its own
+func (m *Manager) Monitor(masterIP *string) error {
+	defer m.Prober.Stop()
 	for {
-		select {
-		case m1 := <-removeStatus:
-			if m1 != nil {
-				return m1
+		m.Prober.Sync(probeAddresses(*masterIP, m.Registry.List()))
+		var master *InstanceStatus
+		for _, status := range m.Prober.Statuses() {
+			status := status
+			if status.Address == *masterIP {
+				master = &status
+			} else if status.State == StateHealthy && status.LastHealth.Role == RolePrimary {
+				log.Println("manager:790", status.Address, "was elected primary in place of", *masterIP)
+				*masterIP = status.Address
+			}
+		}
+		if master != nil && master.State == StateDown {
+			newPrimary, failErr := m.autoFailover(*masterIP)
+			if failErr != nil {
+				log.Println("manager:797 no failover from", *masterIP, failErr)
+			} else {
+				log.Println("manager:799 failed over from", *masterIP, "to", newPrimary)
+				*masterIP = newPrimary
 			}
-			break
-		case m2 := <-newMasterStatus:
-			if m2 != nil {
-				log.Fatal(m2)
-				return m2
+		} else if master != nil && master.State == StateHealthy && master.LastHealth.Role == RolePrimary {
+			lagErr := m.checkReplication(*masterIP)
+			if lagErr != nil {
+				log.Println("manager:805 could not measure replication lag on", *masterIP, lagErr)
 			}
-			break
-		case m3 := <-successStatus:
-			log.Println("manager:180 Created", string(m3))
-			return m.Monitor(masterIP, instances)
 		}
+		time.Sleep(m.Prober.Config().Interval)
 	}
 }
 
 // NewManager creates a new manager struct
-func NewManager(proj, pf string, pfctl *hostProvider.HostProvider, instances *metadata.Instances) *Manager {
-	return &Manager{Project: proj, Platform: pf, platformCtl: *pfctl, data: instances}
+func NewManager(proj, pf string, pfctl *hostProvider.HostProvider, registry *metadata.Registry) *Manager {
+	events := newBroadcaster()
+	prober := NewProber(DefaultProbeConfig())
+	m := &Manager{
+		Project:          proj,
+		Platform:         pf,
+		platformCtl:      *pfctl,
+		Registry:         registry,
+		BootstrapTimeout: DefaultBootstrapTimeout,
+		ElectionTimeout:  DefaultElectionTimeout,
+		Prober:           prober,
+		Policy:           DefaultFailoverPolicy(),
+		LagThresholds:    DefaultLagThresholds(),
+		lag:              newLagTracker(),
+		history:          &failoverHistory{notify: func(record FailoverRecord) { events.publish(record) }},
+		events:           events,
+		Operations:       NewOperations(),
+		down:             make(map[string]bool),
+		reserved:         make(map[string]bool),
+	}
+	prober.notify = func(status InstanceStatus) {
+		events.publish(status)
+		m.notifyHealth(status)
+	}
+	return m
 }
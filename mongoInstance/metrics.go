@@ -0,0 +1,126 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"sync"
+
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/metrics"
+)
+
+// kubongo's own metrics, the figures of each mongod are read when scraped by the Manager's Collector
+var (
+	probeResults   = metrics.NewCounterVec("kubongo_probes_total", "Health checks of registered instances by result.", "name", "result")
+	probeDuration  = metrics.NewHistogramVec("kubongo_probe_duration_seconds", "How long health checks of registered instances took.", nil, "name")
+	failovers      = metrics.NewCounterVec("kubongo_failovers_total", "Failovers by outcome, repeats of the same suppression are counted once.", "outcome", "manual")
+	providerCalls  = metrics.NewCounterVec("kubongo_provider_calls_total", "Calls to the host provider.", "platform", "call")
+	providerErrors = metrics.NewCounterVec("kubongo_provider_errors_total", "Calls to the host provider that failed.", "platform", "call")
+)
+
+func init() {
+	metrics.Register(probeResults, probeDuration, failovers, providerCalls, providerErrors)
+}
+
+// providerCall counts a call to the platform and whether it failed, it returns err
+func (m *Manager) providerCall(call string, err error) error {
+	providerCalls.Inc(m.Platform, call)
+	if err != nil {
+		providerErrors.Inc(m.Platform, call)
+		m.notifyProviderError(call, err)
+	}
+	return err
+}
+
+// Collector returns a metrics.Collector of every registered instance's probe state and mongod figures labeled
+// by name, zone and role, every scrape reads the figures from all the mongods at once
+func (m *Manager) Collector() metrics.Collector {
+	return metrics.CollectorFunc(m.collect)
+}
+
+func (m *Manager) collect() []metrics.Family {
+	var (
+		up          = metrics.Family{Name: "kubongo_instance_up", Help: "Whether the instance's probe state is healthy.", Type: metrics.TypeGauge}
+		state       = metrics.Family{Name: "kubongo_instance_state", Help: "The instance's probe state, always 1.", Type: metrics.TypeGauge}
+		lagging     = metrics.Family{Name: "kubongo_instance_lagging", Help: "Whether the secondary crossed the lag thresholds when last measured.", Type: metrics.TypeGauge}
+		mongoUp     = metrics.Family{Name: "mongodb_up", Help: "Whether serverStatus could be read from the instance's mongod.", Type: metrics.TypeGauge}
+		connections = metrics.Family{Name: "mongodb_connections", Help: "The mongod's current and available connections.", Type: metrics.TypeGauge}
+		opcounters  = metrics.Family{Name: "mongodb_opcounters_total", Help: "Operations the mongod ran since it started by type.", Type: metrics.TypeCounter}
+		memory      = metrics.Family{Name: "mongodb_memory_bytes", Help: "The mongod's resident and virtual memory.", Type: metrics.TypeGauge}
+		memberState = metrics.Family{Name: "mongodb_replset_member_state", Help: "The member's replica set state, 1 is PRIMARY and 2 SECONDARY.", Type: metrics.TypeGauge}
+		lag         = metrics.Family{Name: "mongodb_replset_lag_seconds", Help: "How far the member's optime is behind the primary's.", Type: metrics.TypeGauge}
+		window      = metrics.Family{Name: "mongodb_oplog_window_seconds", Help: "The time between the oldest and newest entry of the member's oplog.", Type: metrics.TypeGauge}
+	)
+	instances := m.Registry.List()
+	timeout := m.Prober.Config().Timeout
+	stats := make([]ServerStats, len(instances))
+	statsErrs := make([]error, len(instances))
+	var wg sync.WaitGroup
+	for i := range instances {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stats[i], statsErrs[i] = FetchStats(memberHost(instances[i]), timeout)
+		}(i)
+	}
+	wg.Wait()
+	for i, instance := range instances {
+		name := instance.GetName()
+		health, probed := m.Prober.Status(name)
+		role := metadata.RoleOf(instance)
+		if role == "" {
+			role = health.LastHealth.Role
+		}
+		if role == "" {
+			role = RoleUnknown
+		}
+		labels := []metrics.Label{{Name: "name", Value: name}, {Name: "zone", Value: instance.GetZone()}, {Name: "role", Value: role}}
+		with := func(name, value string) []metrics.Label {
+			return append(append([]metrics.Label(nil), labels...), metrics.Label{Name: name, Value: value})
+		}
+		if probed {
+			up.Add(boolValue(health.State == StateHealthy), labels...)
+			state.Add(1, with("state", health.State)...)
+		}
+		if member, isMember := instance.(*metadata.Member); isMember {
+			if lag, measured := m.lag.member(member.Host); measured {
+				lagging.Add(boolValue(lag.Lagging), labels...)
+			}
+		}
+		mongoUp.Add(boolValue(statsErrs[i] == nil), labels...)
+		if statsErrs[i] != nil {
+			continue
+		}
+		connections.Add(float64(stats[i].ConnectionsCurrent), with("state", "current")...)
+		connections.Add(float64(stats[i].ConnectionsAvailable), with("state", "available")...)
+		for _, opType := range []string{"insert", "query", "update", "delete", "getmore", "command"} {
+			opcounters.Add(float64(stats[i].Opcounters[opType]), with("type", opType)...)
+		}
+		memory.Add(float64(stats[i].ResidentMB<<20), with("type", "resident")...)
+		memory.Add(float64(stats[i].VirtualMB<<20), with("type", "virtual")...)
+		if stats[i].ReplicaSet {
+			memberState.Add(float64(stats[i].MemberState), labels...)
+			lag.Add(stats[i].Lag.Seconds(), labels...)
+			window.Add(stats[i].OplogWindow.Seconds(), labels...)
+		}
+	}
+	return []metrics.Family{up, state, lagging, mongoUp, connections, opcounters, memory, memberState, lag, window}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
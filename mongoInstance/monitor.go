@@ -14,32 +14,83 @@ limitations under the License.
 package mongoInstance
 
 import (
-	"fmt"
-	"log"
-	"net/http"
+	"time"
+
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// Roles a mongod can report in its hello reply
+const (
+	RolePrimary    = "primary"
+	RoleSecondary  = "secondary"
+	RoleArbiter    = "arbiter"
+	RoleStandalone = "standalone"
+	RoleMongos     = "mongos"
+	// RoleOther is a replica set member that is neither primary, secondary nor arbiter, e.g. STARTUP2 or RECOVERING
+	RoleOther   = "other"
+	RoleUnknown = "unknown"
 )
 
-// Monitor master mongo instance
-type Monitor struct {
-	MasterIP *string
+// DefaultHealthCheckTimeout bounds connecting and each command of a health check
+const DefaultHealthCheckTimeout = 3 * time.Second
+
+// Health is the result of a single health check of a mongod
+type Health struct {
+	Address    string        `json:"address"`
+	Healthy    bool          `json:"healthy"`
+	Role       string        `json:"role"`
+	ReplicaSet string        `json:"replicaSet,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	Error      string        `json:"error,omitempty"`
+	CheckedAt  time.Time     `json:"checkedAt"`
 }
 
-// HealthCheck master mongo instance
-func (m *Monitor) HealthCheck(healthChannel chan bool) {
-	res, resErr := http.Get(fmt.Sprintf("http://%s", *m.MasterIP))
-	if resErr != nil {
-		log.Println(resErr)
-		healthChannel <- false
-	} else if res.Status != "200 OK" {
-		log.Println(fmt.Errorf("healthcheck responded with status other than 200 OK, %s", res.Status))
-		healthChannel <- false
-	} else {
-		log.Println(*m.MasterIP, "passes health check")
-		healthChannel <- true
+func roleOf(hello mongoWire.Doc) string {
+	switch {
+	case hello.String("msg") == "isdbgrid":
+		return RoleMongos
+	case hello.Bool("arbiterOnly"):
+		return RoleArbiter
+	case hello.Bool("isWritablePrimary") || hello.Bool("ismaster"):
+		if hello.String("setName") == "" {
+			return RoleStandalone
+		}
+		return RolePrimary
+	case hello.Bool("secondary"):
+		return RoleSecondary
+	case hello.String("setName") != "":
+		return RoleOther
 	}
-	return
+	return RoleUnknown
 }
 
-func newMonitor(masterIP *string) *Monitor {
-	return &Monitor{masterIP}
+// CheckHealth connects to the mongod at address and runs hello, falling back to isMaster, then ping,
+// the mongod is healthy if both succeed and it reports a serving role
+func CheckHealth(address string, timeout time.Duration) Health {
+	health := Health{Address: address, Role: RoleUnknown, CheckedAt: time.Now()}
+	conn, dialErr := dial(address, timeout)
+	if dialErr != nil {
+		health.Error = dialErr.Error()
+		return health
+	}
+	defer conn.Close()
+	hello, helloErr := conn.RunCommand("admin", mongoWire.Doc{{Key: "hello", Value: 1}})
+	if mongoWire.IsCommandNotFound(helloErr) {
+		hello, helloErr = conn.RunCommand("admin", mongoWire.Doc{{Key: "isMaster", Value: 1}})
+	}
+	if helloErr != nil {
+		health.Error = helloErr.Error()
+		return health
+	}
+	health.Role = roleOf(hello)
+	health.ReplicaSet = hello.String("setName")
+	pingStart := time.Now()
+	_, pingErr := conn.RunCommand("admin", mongoWire.Doc{{Key: "ping", Value: 1}})
+	health.Latency = time.Since(pingStart)
+	if pingErr != nil {
+		health.Error = pingErr.Error()
+		return health
+	}
+	health.Healthy = health.Role != RoleUnknown && health.Role != RoleOther
+	return health
 }
@@ -0,0 +1,67 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+func TestCheckHealthRoles(t *testing.T) {
+	fake, fakeErr := mongoWire.NewFakeServer()
+	if fakeErr != nil {
+		t.Fatal(fakeErr)
+	}
+	defer fake.Close()
+	fake.ReplicaSet = "rs0"
+	for _, role := range []string{RolePrimary, RoleSecondary, RoleArbiter} {
+		fake.SetRole(role)
+		health := CheckHealth(fake.Addr(), time.Second)
+		if !health.Healthy || health.Role != role || health.ReplicaSet != "rs0" {
+			t.Errorf("expected a healthy %s of rs0, got %+v", role, health)
+		}
+	}
+}
+
+func TestCheckHealthFallsBackToIsMaster(t *testing.T) {
+	fake, fakeErr := mongoWire.NewFakeServer()
+	if fakeErr != nil {
+		t.Fatal(fakeErr)
+	}
+	defer fake.Close()
+	fake.Handle("hello", func(cmd mongoWire.Doc) mongoWire.Doc {
+		return mongoWire.Doc{{Key: "ok", Value: float64(0)}, {Key: "code", Value: int32(59)}}
+	})
+	fake.Delay = 10 * time.Millisecond
+	health := CheckHealth(fake.Addr(), time.Second)
+	if !health.Healthy || health.Role != RoleStandalone {
+		t.Errorf("expected a healthy standalone, got %+v", health)
+	}
+	if health.Latency < fake.Delay {
+		t.Errorf("expected a latency of at least %v, got %v", fake.Delay, health.Latency)
+	}
+}
+
+func TestCheckHealthUnreachable(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+	health := CheckHealth(addr, time.Second)
+	if health.Healthy || health.Error == "" {
+		t.Errorf("expected an unhealthy result with an error, got %+v", health)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"github.com/cpg1111/kubongo/notify"
+)
+
+// notifyHealth tells the Notifier about an instance going down, and recovering once it is healthy again
+func (m *Manager) notifyHealth(status InstanceStatus) {
+	m.downLock.Lock()
+	defer m.downLock.Unlock()
+	details := map[string]string{"address": status.Address, "previousState": status.PreviousState}
+	switch {
+	case status.State == StateDown && !m.down[status.Name]:
+		m.down[status.Name] = true
+		details["error"] = status.LastHealth.Error
+		m.Notifier.Notify(notify.Event{
+			Type:    notify.EventInstanceDown,
+			Time:    status.Since,
+			Subject: status.Name,
+			Message: status.Name + " at " + status.Address + " failed its health checks",
+			Details: details,
+		})
+	case status.State == StateHealthy && m.down[status.Name]:
+		delete(m.down, status.Name)
+		details["role"] = status.LastHealth.Role
+		m.Notifier.Notify(notify.Event{
+			Type:    notify.EventInstanceRecovered,
+			Time:    status.Since,
+			Subject: status.Name,
+			Message: status.Name + " at " + status.Address + " passes its health checks again",
+			Details: details,
+		})
+	}
+}
+
+// notifyFailover tells the Notifier a failover from record.From started, or how it ended once record has an Outcome
+func (m *Manager) notifyFailover(record FailoverRecord, reason string) {
+	event := notify.Event{Subject: record.From, Details: map[string]string{"reason": reason, "replicaSet": m.ReplicaSet}}
+	if record.Manual {
+		event.Details["manual"] = "true"
+	}
+	switch record.Outcome {
+	case "":
+		event.Type, event.Message = notify.EventFailoverStarted, "failing over from "+record.From+", "+reason
+	case FailoverExecuted:
+		event.Details["to"] = record.To
+		event.Type, event.Message = notify.EventFailoverCompleted, "failed over from "+record.From+" to "+record.To
+	case FailoverFailed:
+		event.Type, event.Message = notify.EventFailoverFailed, "could not fail over from "+record.From+": "+record.Reason
+	default:
+		return
+	}
+	m.Notifier.Notify(event)
+}
+
+// notifyProviderError tells the Notifier a call to the platform failed
+func (m *Manager) notifyProviderError(call string, err error) {
+	m.Notifier.Notify(notify.Event{
+		Type:    notify.EventProviderError,
+		Subject: m.Platform + "/" + call,
+		Message: call + " on " + m.Platform + " failed: " + err.Error(),
+		Details: map[string]string{"platform": m.Platform, "call": call, "project": m.Project},
+	})
+}
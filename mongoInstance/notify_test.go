@@ -0,0 +1,73 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/notify"
+)
+
+// eventSink collects every event it is sent
+type eventSink chan notify.Event
+
+func (s eventSink) Name() string {
+	return "events"
+}
+
+func (s eventSink) Send(event notify.Event) error {
+	s <- event
+	return nil
+}
+
+func TestNotifications(t *testing.T) {
+	manager, primary := newLagManager(t)
+	defer primary.Close()
+	sink := make(eventSink, 10)
+	manager.Notifier = notify.NewNotifier()
+	manager.Notifier.Add(sink)
+
+	now := time.Now()
+	// a blip that never reaches down is not worth a recovery
+	manager.notifyHealth(InstanceStatus{Name: "db-1", State: StateDegraded, PreviousState: StateHealthy, Since: now})
+	manager.notifyHealth(InstanceStatus{Name: "db-1", State: StateHealthy, PreviousState: StateDegraded, Since: now})
+	manager.notifyHealth(InstanceStatus{Name: "db-0", State: StateDown, PreviousState: StateDegraded, Since: now})
+	manager.notifyHealth(InstanceStatus{Name: "db-0", State: StateDegraded, PreviousState: StateDown, Since: now})
+	manager.notifyHealth(InstanceStatus{Name: "db-0", State: StateHealthy, PreviousState: StateDegraded, Since: now})
+
+	manager.LagThresholds.MaxLag = time.Nanosecond
+	manager.checkReplication(primary.Addr())
+	manager.Failover(primary.Addr())
+	manager.providerCall("get", errors.New("quota exceeded"))
+	manager.Notifier.Close()
+
+	expected := []string{
+		notify.EventInstanceDown,
+		notify.EventInstanceRecovered,
+		notify.EventFailoverStarted,
+		notify.EventFailoverFailed,
+		notify.EventProviderError,
+	}
+	for _, eventType := range expected {
+		event := <-sink
+		if event.Type != eventType {
+			t.Errorf("expected %s, got %+v", eventType, event)
+		}
+	}
+	if len(sink) != 0 {
+		t.Errorf("expected no more events, got %d", len(sink))
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"golang.org/x/net/context"
+)
+
+// Operation statuses
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationDone      = "done"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+	// StepSkipped is a step that never ran because an earlier one failed or the operation was cancelled
+	StepSkipped = "skipped"
+)
+
+// maxOperations is how many finished operations are kept
+const maxOperations = 200
+
+// OperationStep is one stage of an operation
+type OperationStep struct {
+	Name     string     `json:"name"`
+	Status   string     `json:"status"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Operation is a long running change to the instances, such as creating or deleting one
+type Operation struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	// Actor is who started the operation, audit.System for the Manager's own
+	Actor  string `json:"actor,omitempty"`
+	Status string `json:"status"`
+	// Progress is the percentage of Steps that are done
+	Progress int             `json:"progress"`
+	Steps    []OperationStep `json:"steps"`
+	// PlatformOperations are the platform's own operations, such as GCE zone operations, as last polled
+	PlatformOperations []hostProvider.Operation `json:"platformOperations,omitempty"`
+	Error              string                   `json:"error,omitempty"`
+	Result             json.RawMessage          `json:"result,omitempty"`
+	Created            time.Time                `json:"created"`
+	Updated            time.Time                `json:"updated"`
+	Finished           *time.Time               `json:"finished,omitempty"`
+}
+
+// Done reports whether the operation has finished, successfully or not
+func (op *Operation) Done() bool {
+	return op.Status == OperationDone || op.Status == OperationFailed || op.Status == OperationCancelled
+}
+
+// tracked is a running or finished operation
+type tracked struct {
+	ops    *Operations
+	op     Operation
+	cancel context.CancelFunc
+}
+
+// Operations runs long running changes in the background and keeps their records
+type Operations struct {
+	mutex sync.Mutex
+	ops   map[string]*tracked
+	order []string
+}
+
+// NewOperations creates an empty set of operations
+func NewOperations() *Operations {
+	return &Operations{ops: make(map[string]*tracked)}
+}
+
+func newOperationID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return "op-" + hex.EncodeToString(id)
+}
+
+// Start runs run in the background as an operation of kind on target for actor and returns it as it is before it runs
+func (o *Operations) Start(kind, target, actor string, run func(ctx context.Context) ([]byte, error)) Operation {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	t := &tracked{
+		ops:    o,
+		cancel: cancel,
+		op: Operation{
+			ID:      newOperationID(),
+			Kind:    kind,
+			Target:  target,
+			Actor:   actor,
+			Status:  OperationPending,
+			Steps:   []OperationStep{},
+			Created: now,
+			Updated: now,
+		},
+	}
+	o.mutex.Lock()
+	o.ops[t.op.ID] = t
+	o.order = append(o.order, t.op.ID)
+	o.prune()
+	started := t.op
+	o.mutex.Unlock()
+
+	ctx = context.WithValue(ctx, trackedKey{}, t)
+	ctx = hostProvider.WithOperationHook(ctx, t.platform)
+	go func() {
+		t.update(func(op *Operation) { op.Status = OperationRunning })
+		result, runErr := run(ctx)
+		t.finish(ctx, result, runErr)
+		cancel()
+	}()
+	return started
+}
+
+// prune drops the oldest finished operations past maxOperations, the caller holds the lock
+func (o *Operations) prune() {
+	for i := 0; len(o.order) > maxOperations && i < len(o.order); {
+		id := o.order[i]
+		if o.ops[id].op.Done() {
+			delete(o.ops, id)
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// Get returns the operation with id
+func (o *Operations) Get(id string) (Operation, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	t, ok := o.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return t.snapshot(), true
+}
+
+// List returns every kept operation, oldest first
+func (o *Operations) List() []Operation {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	ops := make([]Operation, len(o.order))
+	for i, id := range o.order {
+		ops[i] = o.ops[id].snapshot()
+	}
+	return ops
+}
+
+// Cancel asks the operation with id to stop, it stops at its next cancellation point and is marked cancelled.
+// An operation that has finished is returned as it is.
+func (o *Operations) Cancel(id string) (Operation, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	t, ok := o.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	if !t.op.Done() {
+		log.Println("operation:182 cancelling", id, t.op.Kind, t.op.Target)
+		t.cancel()
+	}
+	return t.snapshot(), true
+}
+
+// snapshot copies the operation so callers can not race its updates, the caller holds the lock
+func (t *tracked) snapshot() Operation {
+	op := t.op
+	op.Steps = append([]OperationStep{}, t.op.Steps...)
+	op.PlatformOperations = append([]hostProvider.Operation(nil), t.op.PlatformOperations...)
+	return op
+}
+
+func (t *tracked) update(change func(op *Operation)) {
+	t.ops.mutex.Lock()
+	defer t.ops.mutex.Unlock()
+	change(&t.op)
+	t.op.Updated = time.Now()
+	done := 0
+	for _, step := range t.op.Steps {
+		if step.Status == OperationDone {
+			done++
+		}
+	}
+	if len(t.op.Steps) > 0 {
+		t.op.Progress = done * 100 / len(t.op.Steps)
+	}
+}
+
+// platform records the last poll of a platform operation
+func (t *tracked) platform(platformOp hostProvider.Operation) {
+	t.update(func(op *Operation) {
+		for i := range op.PlatformOperations {
+			if op.PlatformOperations[i].Name == platformOp.Name {
+				op.PlatformOperations[i] = platformOp
+				return
+			}
+		}
+		op.PlatformOperations = append(op.PlatformOperations, platformOp)
+	})
+}
+
+func (t *tracked) finish(ctx context.Context, result []byte, runErr error) {
+	t.update(func(op *Operation) {
+		now := time.Now()
+		op.Finished = &now
+		status := OperationDone
+		switch {
+		case runErr != nil && runErr == ctx.Err():
+			status = OperationCancelled
+		case runErr != nil:
+			status = OperationFailed
+		}
+		for i := range op.Steps {
+			step := &op.Steps[i]
+			switch {
+			case step.Status == OperationRunning && status == OperationDone:
+				step.Status, step.Finished = OperationDone, &now
+			case step.Status == OperationRunning:
+				step.Status, step.Finished, step.Error = status, &now, runErr.Error()
+			case step.Status == OperationPending:
+				step.Status = StepSkipped
+			}
+		}
+		op.Status = status
+		if runErr != nil {
+			op.Error = runErr.Error()
+			log.Println("operation:250", op.ID, op.Kind, op.Target, status+":", runErr)
+		} else if len(result) > 0 {
+			op.Result = json.RawMessage(result)
+		}
+	})
+}
+
+type trackedKey struct{}
+
+// planSteps lists the steps the operation of ctx will run, if ctx belongs to one
+func planSteps(ctx context.Context, names ...string) {
+	t, ok := ctx.Value(trackedKey{}).(*tracked)
+	if !ok {
+		return
+	}
+	t.update(func(op *Operation) {
+		for _, name := range names {
+			op.Steps = append(op.Steps, OperationStep{Name: name, Status: OperationPending})
+		}
+	})
+}
+
+// beginStep marks the running step of ctx's operation done and starts the step called name,
+// it returns ctx's error so callers can stop at a step if the operation was cancelled
+func beginStep(ctx context.Context, name string) error {
+	t, ok := ctx.Value(trackedKey{}).(*tracked)
+	if !ok {
+		return ctx.Err()
+	}
+	t.update(func(op *Operation) {
+		now := time.Now()
+		next := -1
+		for i := range op.Steps {
+			step := &op.Steps[i]
+			if step.Status == OperationRunning {
+				step.Status, step.Finished = OperationDone, &now
+			}
+			if next < 0 && step.Name == name && step.Status == OperationPending {
+				next = i
+			}
+		}
+		if next < 0 {
+			op.Steps = append(op.Steps, OperationStep{Name: name})
+			next = len(op.Steps) - 1
+		}
+		op.Steps[next].Status, op.Steps[next].Started = OperationRunning, &now
+	})
+	return ctx.Err()
+}
+
+// detached keeps the values of a ctx but is never cancelled
+type detached struct {
+	context.Context
+	values context.Context
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.values.Value(key)
+}
+
+// detach returns a ctx for the steps after an operation's point of no return,
+// they are still recorded in the operation but cancelling it no longer stops them
+func detach(ctx context.Context) context.Context {
+	return detached{Context: context.Background(), values: ctx}
+}
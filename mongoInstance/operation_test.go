@@ -0,0 +1,218 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/audit"
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"golang.org/x/net/context"
+)
+
+// operationHost creates servers through a fake platform operation, or waits to be cancelled if block is set,
+// and fails to delete them with deleteErr
+type operationHost struct {
+	hostProvider.HostProvider
+	block     bool
+	deleteErr error
+}
+
+func (h *operationHost) CreateServerContext(ctx context.Context, namespace, zone, name, machineType, sourceImage, source string) (hostProvider.Instance, error) {
+	hostProvider.ReportOperation(ctx, hostProvider.Operation{Name: "insert-" + name, Type: "insert", Target: name, Status: "RUNNING", Progress: 50})
+	if h.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	hostProvider.ReportOperation(ctx, hostProvider.Operation{Name: "insert-" + name, Type: "insert", Target: name, Status: "DONE", Progress: 100})
+	return &hostProvider.LocalInstance{Name: name, Zone: zone}, nil
+}
+
+func (h *operationHost) DeleteServerContext(ctx context.Context, namespace, zone, name string) error {
+	return h.deleteErr
+}
+
+func newOperationServer(block bool) (*httptest.Server, *Manager) {
+	var host hostProvider.HostProvider = &operationHost{block: block}
+	manager := NewManager("test", "test", &host, metadata.NewRegistry())
+	mux := http.NewServeMux()
+	mux.Handle("/instances", &MongoHandler{Platform: "test", Manager: manager})
+	mux.Handle("/v1/operations/", &OperationsHandler{Manager: manager})
+	return httptest.NewServer(mux), manager
+}
+
+// awaitOperation polls the operation at location until it is finished
+func awaitOperation(t *testing.T, url string) *Operation {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		res := doRequest(t, "GET", url, "")
+		op := &Operation{}
+		json.NewDecoder(res.Body).Decode(op)
+		res.Body.Close()
+		if op.Done() {
+			return op
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the operation")
+	return nil
+}
+
+func TestCreateOperation(t *testing.T) {
+	server, manager := newOperationServer(false)
+	defer server.Close()
+
+	res := doRequest(t, "POST", server.URL+"/instances", `{"kind":"Create","name":"db-0","zone":"us-east1-b"}`)
+	res.Body.Close()
+	location := res.Header.Get("Location")
+	if res.StatusCode != http.StatusAccepted || location == "" {
+		t.Fatalf("expected 202 with the operation's location, got %s %q", res.Status, location)
+	}
+	op := awaitOperation(t, server.URL+location)
+	if op.Status != OperationDone || op.Progress != 100 || op.Kind != "create" || op.Target != "us-east1-b/db-0" {
+		t.Errorf("expected create us-east1-b/db-0 to be done, got %+v", op)
+	}
+	if len(op.Steps) != 2 || op.Steps[0].Name != stepCreateServer || op.Steps[1].Status != OperationDone {
+		t.Errorf("expected create server then register to be done, got %+v", op.Steps)
+	}
+	if len(op.PlatformOperations) != 1 || op.PlatformOperations[0].Status != "DONE" {
+		t.Errorf("expected the platform operation's last poll to be folded in, got %+v", op.PlatformOperations)
+	}
+	if _, registered := manager.Registry.Get("db-0"); !registered {
+		t.Error("expected db-0 to be registered")
+	}
+	expectError(t, doRequest(t, "POST", server.URL+location+"/cancel", ""), http.StatusConflict)
+	expectError(t, doRequest(t, "GET", server.URL+"/v1/operations/op-missing", ""), http.StatusNotFound)
+}
+
+func TestCancelOperation(t *testing.T) {
+	server, manager := newOperationServer(true)
+	defer server.Close()
+	dir, _ := ioutil.TempDir("", "kubongo-audit")
+	defer os.RemoveAll(dir)
+	manager.Audit = audit.NewLog(filepath.Join(dir, "audit.log"))
+
+	op := manager.Operations.Start("create", "us-east1-b/db-0", "alice", func(ctx context.Context) ([]byte, error) {
+		return manager.CreateContext(ctx, &InstanceTemplate{Kind: "Create", Name: "db-0", Zone: "us-east1-b"})
+	})
+	res := doRequest(t, "POST", server.URL+"/v1/operations/"+op.ID+"/cancel", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected cancelling a running operation to be accepted, got %s", res.Status)
+	}
+	cancelled := awaitOperation(t, server.URL+"/v1/operations/"+op.ID)
+	if cancelled.Status != OperationCancelled || cancelled.Steps[0].Status != OperationCancelled || cancelled.Steps[1].Status != StepSkipped {
+		t.Errorf("expected the operation to be cancelled while creating the server, got %+v", cancelled)
+	}
+	if _, registered := manager.Registry.Get("db-0"); registered {
+		t.Error("expected a cancelled create not to register db-0")
+	}
+	if ops := manager.Operations.List(); len(ops) != 1 || ops[0].ID != op.ID {
+		t.Errorf("expected the cancelled operation to be listed, got %+v", ops)
+	}
+	entries, _ := manager.Audit.Query(audit.Query{Actor: "alice"})
+	if len(entries) != 1 || entries[0].Action != "create" || entries[0].Outcome != audit.OutcomeFailure || entries[0].Source != audit.SourceManager {
+		t.Errorf("expected alice's cancelled create to be audited as a failure, got %+v", entries)
+	}
+}
+
+func TestCancelledOperationThatFailsIsFailed(t *testing.T) {
+	operations := NewOperations()
+	op := operations.Start("delete", "us-east1-b/db-0", "alice", func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, errors.New("could not roll back db-0")
+	})
+	operations.Cancel(op.ID)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		finished, _ := operations.Get(op.ID)
+		if finished.Done() {
+			if finished.Status != OperationFailed || finished.Error != "could not roll back db-0" {
+				t.Errorf("expected an operation failing for another reason than its cancellation to be failed, got %+v", finished)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the operation")
+}
+
+func TestFailedDeleteKeepsTheInstanceRegistered(t *testing.T) {
+	platform := &operationHost{deleteErr: errors.New("quota exceeded")}
+	var host hostProvider.HostProvider = platform
+	manager := NewManager("test", "test", &host, metadata.NewRegistry())
+	if _, createErr := manager.Create(&InstanceTemplate{Kind: "Create", Name: "db-0", Zone: "us-east1-b"}); createErr != nil {
+		t.Fatal(createErr)
+	}
+
+	op := manager.Operations.Start("delete", "us-east1-b/db-0", "alice", func(ctx context.Context) ([]byte, error) {
+		return nil, manager.RemoveContext(ctx, "us-east1-b", "db-0")
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for !op.Done() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		op, _ = manager.Operations.Get(op.ID)
+	}
+	if op.Status != OperationFailed || len(op.Steps) != 2 || op.Steps[0].Status != OperationFailed || op.Steps[1].Status != StepSkipped {
+		t.Errorf("expected deleting the server to fail and unregistering to be skipped, got %+v", op)
+	}
+	if _, registered := manager.Registry.Get("db-0"); !registered {
+		t.Fatal("expected db-0 to stay registered so the delete can be retried")
+	}
+	platform.deleteErr = nil
+	if removeErr := manager.Remove("us-east1-b", "db-0"); removeErr != nil {
+		t.Fatal(removeErr)
+	}
+	if _, registered := manager.Registry.Get("db-0"); registered {
+		t.Error("expected retrying the delete to unregister db-0")
+	}
+}
+
+func TestCreateReservesTheName(t *testing.T) {
+	var host hostProvider.HostProvider = &operationHost{block: true}
+	manager := NewManager("test", "test", &host, metadata.NewRegistry())
+	manager.ReplicaSet = "rs0"
+
+	op := manager.Operations.Start("create", "us-east1-b/db-0", "alice", func(ctx context.Context) ([]byte, error) {
+		return manager.CreateContext(ctx, &InstanceTemplate{Kind: "Create", Name: "db-0", Zone: "us-east1-b"})
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(op.PlatformOperations) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		op, _ = manager.Operations.Get(op.ID)
+	}
+	if _, createErr := manager.Create(&InstanceTemplate{Kind: "Create", Name: "db-0", Zone: "us-east1-b"}); !metadata.IsConflict(createErr) {
+		t.Errorf("expected creating db-0 while it is being created to conflict, got %v", createErr)
+	}
+	if _, createErr := manager.CreateReplicaSet(&InstanceTemplate{Kind: "Create", Name: "db"}, 2); !metadata.IsConflict(createErr) {
+		t.Errorf("expected a replica set reusing db-0 to conflict, got %v", createErr)
+	}
+	manager.Operations.Cancel(op.ID)
+	for !op.Done() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		op, _ = manager.Operations.Get(op.ID)
+	}
+	if _, reserveErr := manager.reserve("db-0"); reserveErr != nil {
+		t.Errorf("expected the cancelled create to give up db-0, got %v", reserveErr)
+	}
+}
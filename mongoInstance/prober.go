@@ -0,0 +1,240 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States an instance can be in as tracked by the Prober
+const (
+	// StateUnknown is an instance that has not passed or failed enough probes yet
+	StateUnknown = "unknown"
+	StateHealthy = "healthy"
+	// StateDegraded is an instance that failed probes but fewer than the failure threshold,
+	// or that is recovering from down but has not passed the success threshold yet
+	StateDegraded = "degraded"
+	StateDown     = "down"
+)
+
+// ProbeConfig controls how often and how strictly the Prober checks instances
+type ProbeConfig struct {
+	Interval time.Duration `json:"interval"`
+	Timeout  time.Duration `json:"timeout"`
+	// FailureThreshold is the number of consecutive failed probes before an instance is down
+	FailureThreshold int `json:"failureThreshold"`
+	// SuccessThreshold is the number of consecutive passed probes before an instance is healthy again
+	SuccessThreshold int `json:"successThreshold"`
+}
+
+// DefaultProbeConfig probes every 3s and takes 3 failures to mark an instance down
+func DefaultProbeConfig() ProbeConfig {
+	return ProbeConfig{
+		Interval:         3 * time.Second,
+		Timeout:          DefaultHealthCheckTimeout,
+		FailureThreshold: 3,
+		SuccessThreshold: 1,
+	}
+}
+
+// InstanceStatus is the state of a single instance as tracked by its probe
+type InstanceStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	State   string `json:"state"`
+	// PreviousState is the state before the last transition
+	PreviousState string `json:"previousState,omitempty"`
+	// Since is when the instance entered State
+	Since                time.Time `json:"since"`
+	ConsecutiveFailures  int       `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int       `json:"consecutiveSuccesses"`
+	LastHealth           Health    `json:"lastHealth"`
+}
+
+// target is a probe goroutine for a single instance
+type target struct {
+	address string
+	stop    chan struct{}
+}
+
+// Prober runs a probe goroutine per instance and tracks each instance's state
+type Prober struct {
+	mutex    sync.Mutex
+	conf     ProbeConfig
+	targets  map[string]*target
+	statuses map[string]*InstanceStatus
+	// check is CheckHealth, replaced in tests
+	check func(address string, timeout time.Duration) Health
+	// notify is called with every state change, it must not block
+	notify func(status InstanceStatus)
+}
+
+// NewProber creates a Prober that probes nothing until Sync is called
+func NewProber(conf ProbeConfig) *Prober {
+	return &Prober{
+		conf:     conf,
+		targets:  make(map[string]*target),
+		statuses: make(map[string]*InstanceStatus),
+		check:    CheckHealth,
+	}
+}
+
+// Config returns the Prober's current config
+func (p *Prober) Config() ProbeConfig {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.conf
+}
+
+// SetConfig changes the config, running probes pick it up after their next probe
+func (p *Prober) SetConfig(conf ProbeConfig) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.conf = conf
+}
+
+// Sync starts probing every instance name in addresses that is not probed yet, restarts probes whose address changed
+// and stops probing instances that are gone
+func (p *Prober) Sync(addresses map[string]string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for name, existing := range p.targets {
+		if address, ok := addresses[name]; !ok || address != existing.address {
+			close(existing.stop)
+			delete(p.targets, name)
+			delete(p.statuses, name)
+		}
+	}
+	now := time.Now()
+	for name, address := range addresses {
+		if _, ok := p.targets[name]; ok {
+			continue
+		}
+		t := &target{address: address, stop: make(chan struct{})}
+		p.targets[name] = t
+		p.statuses[name] = &InstanceStatus{Name: name, Address: address, State: StateUnknown, Since: now}
+		go p.probe(name, t)
+	}
+}
+
+// Stop stops every probe
+func (p *Prober) Stop() {
+	p.Sync(map[string]string{})
+}
+
+func (p *Prober) probe(name string, t *target) {
+	for {
+		conf := p.Config()
+		started := time.Now()
+		health := p.check(t.address, conf.Timeout)
+		select {
+		case <-t.stop:
+			return
+		default:
+		}
+		probeDuration.Observe(time.Since(started).Seconds(), name)
+		if health.Healthy {
+			probeResults.Inc(name, "success")
+		} else {
+			probeResults.Inc(name, "failure")
+		}
+		p.record(name, health)
+		select {
+		case <-t.stop:
+			return
+		case <-time.After(conf.Interval):
+		}
+	}
+}
+
+// nextState is the state after a probe given the current state and the consecutive counts including that probe
+func nextState(state string, healthy bool, failures, successes int, conf ProbeConfig) string {
+	if healthy {
+		if successes >= conf.SuccessThreshold {
+			return StateHealthy
+		}
+		if state == StateDown {
+			return StateDegraded
+		}
+		return state
+	}
+	if failures >= conf.FailureThreshold {
+		return StateDown
+	}
+	if state == StateHealthy {
+		return StateDegraded
+	}
+	return state
+}
+
+// record folds the result of a probe into name's status
+func (p *Prober) record(name string, health Health) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	status, ok := p.statuses[name]
+	if !ok {
+		return
+	}
+	status.LastHealth = health
+	if health.Healthy {
+		status.ConsecutiveSuccesses++
+		status.ConsecutiveFailures = 0
+	} else {
+		status.ConsecutiveFailures++
+		status.ConsecutiveSuccesses = 0
+	}
+	state := nextState(status.State, health.Healthy, status.ConsecutiveFailures, status.ConsecutiveSuccesses, p.conf)
+	if state != status.State {
+		log.Println("prober:202", name, "at", status.Address, "went from", status.State, "to", state)
+		status.PreviousState = status.State
+		status.State = state
+		status.Since = health.CheckedAt
+		if status.Since.IsZero() {
+			status.Since = time.Now()
+		}
+		if p.notify != nil {
+			p.notify(*status)
+		}
+	}
+}
+
+// Status returns the status of the instance called name
+func (p *Prober) Status(name string) (InstanceStatus, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	status, ok := p.statuses[name]
+	if !ok {
+		return InstanceStatus{}, false
+	}
+	return *status, true
+}
+
+// Statuses returns the status of every probed instance sorted by name
+func (p *Prober) Statuses() []InstanceStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	names := make([]string, 0, len(p.statuses))
+	for name := range p.statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	statuses := make([]InstanceStatus, len(names))
+	for i, name := range names {
+		statuses[i] = *p.statuses[name]
+	}
+	return statuses
+}
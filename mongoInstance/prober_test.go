@@ -0,0 +1,82 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+func TestProberStates(t *testing.T) {
+	prober := NewProber(ProbeConfig{Interval: time.Hour, FailureThreshold: 2, SuccessThreshold: 2})
+	prober.statuses["db"] = &InstanceStatus{Name: "db", State: StateUnknown}
+	steps := []struct {
+		healthy bool
+		state   string
+	}{
+		{true, StateUnknown},
+		{true, StateHealthy},
+		{false, StateDegraded},
+		{true, StateDegraded},
+		{false, StateDegraded},
+		{false, StateDown},
+		{true, StateDegraded},
+		{true, StateHealthy},
+	}
+	for i, step := range steps {
+		prober.record("db", Health{Healthy: step.healthy, CheckedAt: time.Unix(int64(i), 0)})
+		status, _ := prober.Status("db")
+		if status.State != step.state {
+			t.Fatalf("step %d: expected %s, got %s", i, step.state, status.State)
+		}
+	}
+	status, _ := prober.Status("db")
+	if status.PreviousState != StateDegraded || !status.Since.Equal(time.Unix(7, 0)) {
+		t.Errorf("expected the last transition from degraded at 7s, got %s at %v", status.PreviousState, status.Since)
+	}
+}
+
+func TestProberSync(t *testing.T) {
+	server, serverErr := mongoWire.NewFakeServer()
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	prober := NewProber(ProbeConfig{Interval: 10 * time.Millisecond, Timeout: time.Second, FailureThreshold: 2, SuccessThreshold: 1})
+	defer prober.Stop()
+	prober.Sync(map[string]string{"db": server.Addr()})
+
+	waitForState := func(state string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			status, _ := prober.Status("db")
+			if status.State == state {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected db to become %s, it is %s", state, status.State)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForState(StateHealthy)
+	server.Close()
+	waitForState(StateDown)
+
+	prober.Sync(map[string]string{})
+	if statuses := prober.Statuses(); len(statuses) != 0 {
+		t.Errorf("expected no statuses after db was removed, got %v", statuses)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// DefaultMongoPort is the port mongod listens on unless the instance says otherwise
+const DefaultMongoPort = 27017
+
+// errNoPrimary is returned when no member of the replica set reports itself as primary
+var errNoPrimary = errors.New("no primary found in the replica set")
+
+// memberHost is the host:port an instance is known by in its replica set config
+func memberHost(instance hostProvider.Instance) string {
+	port := DefaultMongoPort
+	switch castInst := instance.(type) {
+	case *metadata.Member:
+		return castInst.Host
+	case hostProvider.LocalInstance:
+		if castInst.ProcessPort != 0 {
+			port = castInst.ProcessPort
+		}
+	case *hostProvider.LocalInstance:
+		if castInst.ProcessPort != 0 {
+			port = castInst.ProcessPort
+		}
+	}
+	return fmt.Sprintf("%s:%d", instance.GetInternalIP(), port)
+}
+
+// AdminCredential is the user kubongo authenticates as on every mongod it connects to, nil connects without auth
+var AdminCredential *mongoWire.Credential
+
+// dial connects to the mongod at address, authenticating as AdminCredential if one is set
+func dial(address string, timeout time.Duration) (*mongoWire.Conn, error) {
+	return mongoWire.DialAuth(address, timeout, AdminCredential)
+}
+
+// runCommand runs a single admin command against the mongod at address
+func runCommand(address string, cmd mongoWire.Doc, timeout time.Duration) (mongoWire.Doc, error) {
+	conn, dialErr := dial(address, timeout)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	defer conn.Close()
+	return conn.RunCommand("admin", cmd)
+}
+
+// memberConfig is a member's entry in a replica set config document
+func memberConfig(member *metadata.Member) mongoWire.Doc {
+	doc := mongoWire.Doc{
+		{Key: "_id", Value: member.MemberID},
+		{Key: "host", Value: member.Host},
+	}
+	opts := member.Options
+	if opts.ArbiterOnly {
+		doc = append(doc, mongoWire.Elem{Key: "arbiterOnly", Value: true})
+	}
+	if opts.Priority != nil {
+		doc = append(doc, mongoWire.Elem{Key: "priority", Value: *opts.Priority})
+	}
+	if opts.Votes != nil {
+		doc = append(doc, mongoWire.Elem{Key: "votes", Value: *opts.Votes})
+	}
+	if opts.Hidden {
+		doc = append(doc, mongoWire.Elem{Key: "hidden", Value: true})
+		if opts.Priority == nil {
+			// hidden members must have priority 0
+			doc = append(doc, mongoWire.Elem{Key: "priority", Value: float64(0)})
+		}
+	}
+	if len(opts.Tags) > 0 {
+		tags := make(map[string]interface{})
+		for k, v := range opts.Tags {
+			tags[k] = v
+		}
+		doc = append(doc, mongoWire.Elem{Key: "tags", Value: tags})
+	}
+	return doc
+}
+
+// waitForMongod retries hello against address until it answers or timeout passes, new instances take a while to boot
+func waitForMongod(address string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, helloErr := runCommand(address, mongoWire.Doc{{Key: "isMaster", Value: 1}}, DefaultHealthCheckTimeout)
+		if helloErr == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not come up within %v: %s", address, timeout, helloErr)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// initiate runs replSetInitiate on the first member with a config of all members
+func initiate(setName string, members []*metadata.Member, timeout time.Duration) error {
+	if len(members) == 0 {
+		return errors.New("cannot initiate a replica set without members")
+	}
+	configMembers := []interface{}{}
+	for i := range members {
+		configMembers = append(configMembers, memberConfig(members[i]))
+	}
+	config := mongoWire.Doc{
+		{Key: "_id", Value: setName},
+		{Key: "members", Value: configMembers},
+	}
+	waitErr := waitForMongod(members[0].Host, timeout)
+	if waitErr != nil {
+		return waitErr
+	}
+	log.Println("replset:134 initiating", setName, "on", members[0].Host, "with", len(members), "members")
+	_, initErr := runCommand(members[0].Host, mongoWire.Doc{{Key: "replSetInitiate", Value: config}}, timeout)
+	return initErr
+}
+
+// findPrimary asks each address who the primary is
+func findPrimary(addresses []string) (string, error) {
+	for _, address := range addresses {
+		hello, helloErr := runCommand(address, mongoWire.Doc{{Key: "isMaster", Value: 1}}, DefaultHealthCheckTimeout)
+		if helloErr != nil {
+			continue
+		}
+		if hello.Bool("ismaster") {
+			return address, nil
+		}
+		if primary := hello.String("primary"); primary != "" {
+			return primary, nil
+		}
+	}
+	return "", errNoPrimary
+}
+
+// reconfig reads the current config from primary, lets mutate change its members and writes it back with a bumped version,
+// force lets a secondary accept the config when there is no primary
+func reconfig(primary string, force bool, mutate func(members []interface{}) ([]interface{}, error)) error {
+	reply, getErr := runCommand(primary, mongoWire.Doc{{Key: "replSetGetConfig", Value: 1}}, DefaultHealthCheckTimeout)
+	if getErr != nil {
+		return getErr
+	}
+	config := reply.Doc("config")
+	if config == nil {
+		return errors.New("replSetGetConfig returned no config")
+	}
+	members, mutateErr := mutate(config.Array("members"))
+	if mutateErr != nil {
+		return mutateErr
+	}
+	newConfig := mongoWire.Doc{}
+	for _, elem := range config {
+		switch elem.Key {
+		case "version":
+			elem.Value = int(config.Int("version") + 1)
+		case "members":
+			elem.Value = members
+		}
+		newConfig = append(newConfig, elem)
+	}
+	cmd := mongoWire.Doc{{Key: "replSetReconfig", Value: newConfig}}
+	if force {
+		cmd = append(cmd, mongoWire.Elem{Key: "force", Value: true})
+	}
+	_, reconfigErr := runCommand(primary, cmd, DefaultHealthCheckTimeout)
+	return reconfigErr
+}
+
+// addMember adds member to the replica set, picking the next free _id
+func addMember(primary string, member *metadata.Member) error {
+	return reconfig(primary, false, func(members []interface{}) ([]interface{}, error) {
+		nextID := int64(0)
+		for _, raw := range members {
+			existing, _ := raw.(mongoWire.Doc)
+			if existing.String("host") == member.Host {
+				return nil, fmt.Errorf("%s is already a member", member.Host)
+			}
+			if existing.Int("_id") >= nextID {
+				nextID = existing.Int("_id") + 1
+			}
+		}
+		member.MemberID = int(nextID)
+		return append(members, memberConfig(member)), nil
+	})
+}
+
+// notMemberError is returned by removeMember when host is not in the replica set config
+type notMemberError struct {
+	host string
+}
+
+func (e *notMemberError) Error() string {
+	return e.host + " is not a member"
+}
+
+// removeMember removes the member with host from the replica set
+func removeMember(primary, host string) error {
+	return reconfig(primary, false, func(members []interface{}) ([]interface{}, error) {
+		kept := []interface{}{}
+		for _, raw := range members {
+			existing, _ := raw.(mongoWire.Doc)
+			if existing.String("host") != host {
+				kept = append(kept, raw)
+			}
+		}
+		if len(kept) == len(members) {
+			return nil, &notMemberError{host: host}
+		}
+		return kept, nil
+	})
+}
+
+// updateMember replaces the config of the member with member's host, keeping its _id
+func updateMember(primary string, member *metadata.Member) error {
+	return reconfig(primary, false, func(members []interface{}) ([]interface{}, error) {
+		for i, raw := range members {
+			existing, _ := raw.(mongoWire.Doc)
+			if existing.String("host") == member.Host {
+				member.MemberID = int(existing.Int("_id"))
+				members[i] = memberConfig(member)
+				return members, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a member", member.Host)
+	})
+}
+
+// stepDown asks the primary to step down for seconds, the primary drops every connection when it does
+// so a network error is expected and ignored
+func stepDown(primary string, seconds int) error {
+	_, stepErr := runCommand(primary, mongoWire.Doc{{Key: "replSetStepDown", Value: seconds}}, DefaultHealthCheckTimeout)
+	if _, isCmdErr := stepErr.(*mongoWire.CommandError); isCmdErr {
+		return stepErr
+	}
+	return nil
+}
+
+// roleOfState maps a replSetGetStatus stateStr to a role
+func roleOfState(state string) string {
+	switch state {
+	case "PRIMARY":
+		return RolePrimary
+	case "SECONDARY":
+		return RoleSecondary
+	case "ARBITER":
+		return RoleArbiter
+	}
+	return RoleOther
+}
+
+// memberRoles returns the role of every member host as reported by replSetGetStatus on address
+func memberRoles(address string) (map[string]string, error) {
+	status, statusErr := runCommand(address, mongoWire.Doc{{Key: "replSetGetStatus", Value: 1}}, DefaultHealthCheckTimeout)
+	if statusErr != nil {
+		return nil, statusErr
+	}
+	roles := make(map[string]string)
+	for _, raw := range status.Array("members") {
+		member, _ := raw.(mongoWire.Doc)
+		roles[member.String("name")] = roleOfState(member.String("stateStr"))
+	}
+	return roles, nil
+}
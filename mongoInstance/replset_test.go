@@ -0,0 +1,344 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// fakeReplSet is a set of FakeServers that share a replica set config, the first one is primary once initiated
+type fakeReplSet struct {
+	t       *testing.T
+	mutex   sync.Mutex
+	servers []*mongoWire.FakeServer
+	config  mongoWire.Doc
+	primary int
+}
+
+func newFakeReplSet(t *testing.T, count int) *fakeReplSet {
+	f := &fakeReplSet{t: t}
+	for i := 0; i < count; i++ {
+		server, serverErr := mongoWire.NewFakeServer()
+		if serverErr != nil {
+			t.Fatal(serverErr)
+		}
+		server.Handle("replSetInitiate", f.initiate)
+		server.Handle("replSetGetConfig", f.getConfig)
+		server.Handle("replSetReconfig", f.reconfigOn(i))
+		server.Handle("replSetGetStatus", f.status)
+		f.servers = append(f.servers, server)
+	}
+	return f
+}
+
+func (f *fakeReplSet) close() {
+	for _, server := range f.servers {
+		server.Close()
+	}
+}
+
+func ok(doc mongoWire.Doc) mongoWire.Doc {
+	return append(doc, mongoWire.Elem{Key: "ok", Value: float64(1)})
+}
+
+func (f *fakeReplSet) initiate(cmd mongoWire.Doc) mongoWire.Doc {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.config = cmd.Doc("replSetInitiate")
+	f.config = append(f.config, mongoWire.Elem{Key: "version", Value: 1})
+	for i, server := range f.servers {
+		server.ReplicaSet = f.config.String("_id")
+		if i == 0 {
+			server.SetRole(RolePrimary)
+		} else {
+			server.SetRole(RoleSecondary)
+		}
+	}
+	return ok(mongoWire.Doc{})
+}
+
+func (f *fakeReplSet) getConfig(cmd mongoWire.Doc) mongoWire.Doc {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return ok(mongoWire.Doc{{Key: "config", Value: f.config}})
+}
+
+// reconfigOn handles replSetReconfig on the i-th server, a forced reconfig makes that server primary
+func (f *fakeReplSet) reconfigOn(i int) mongoWire.CommandHandler {
+	return func(cmd mongoWire.Doc) mongoWire.Doc {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		f.config = cmd.Doc("replSetReconfig")
+		if cmd.Bool("force") {
+			f.primary = i
+			f.servers[i].SetRole(RolePrimary)
+		}
+		return ok(mongoWire.Doc{})
+	}
+}
+
+func (f *fakeReplSet) status(cmd mongoWire.Doc) mongoWire.Doc {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	members := []interface{}{}
+	for _, raw := range f.config.Array("members") {
+		host := raw.(mongoWire.Doc).String("host")
+		state := "SECONDARY"
+		if host == f.servers[0].Addr() {
+			state = "PRIMARY"
+		}
+		members = append(members, mongoWire.Doc{{Key: "name", Value: host}, {Key: "stateStr", Value: state}})
+	}
+	return ok(mongoWire.Doc{{Key: "members", Value: members}})
+}
+
+func (f *fakeReplSet) configHosts() map[string]mongoWire.Doc {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	hosts := make(map[string]mongoWire.Doc)
+	for _, raw := range f.config.Array("members") {
+		hosts[raw.(mongoWire.Doc).String("host")] = raw.(mongoWire.Doc)
+	}
+	return hosts
+}
+
+// fakeHost hands out the fake servers as instances in order
+type fakeHost struct {
+	hostProvider.HostProvider
+	mutex   sync.Mutex
+	replSet *fakeReplSet
+	created int
+	deleted []string
+	// deleteErr fails DeleteServer while it is set
+	deleteErr error
+}
+
+func (h *fakeHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (hostProvider.Instance, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.created >= len(h.replSet.servers) {
+		return nil, errors.New("out of fake servers")
+	}
+	host, port, _ := net.SplitHostPort(h.replSet.servers[h.created].Addr())
+	portNum, _ := strconv.Atoi(port)
+	h.created++
+	return &hostProvider.LocalInstance{Name: name, IP: host, ProcessPort: portNum, Zone: zone}, nil
+}
+
+func (h *fakeHost) DeleteServer(namespace, zone, name string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.deleteErr != nil {
+		return h.deleteErr
+	}
+	h.deleted = append(h.deleted, name)
+	return nil
+}
+
+func TestReplicaSetMembership(t *testing.T) {
+	replSet := newFakeReplSet(t, 3)
+	defer replSet.close()
+	fake := &fakeHost{replSet: replSet}
+	var host hostProvider.HostProvider = fake
+	registry := metadata.NewRegistry()
+	manager := NewManager("test", "test", &host, registry)
+	manager.ReplicaSet = "rs0"
+	manager.BootstrapTimeout = 2 * time.Second
+
+	_, createErr := manager.CreateReplicaSet(&InstanceTemplate{Kind: "Create", Name: "db"}, 2)
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	hosts := replSet.configHosts()
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 members to be initiated, got %v", hosts)
+	}
+	roles := make(map[string]string)
+	for _, member := range manager.members() {
+		roles[member.GetName()] = member.Role
+	}
+	if roles["db-0"] != RolePrimary || roles["db-1"] != RoleSecondary {
+		t.Errorf("expected db-0 primary and db-1 secondary, got %v", roles)
+	}
+
+	_, addErr := manager.Create(&InstanceTemplate{Kind: "Create", Name: "db-2", Options: metadata.MemberOptions{Hidden: true}})
+	if addErr != nil {
+		t.Fatal(addErr)
+	}
+	added := replSet.configHosts()[replSet.servers[2].Addr()]
+	if added == nil || added.Int("_id") != 2 || !added.Bool("hidden") || added.Get("priority") != float64(0) {
+		t.Errorf("expected db-2 to be added hidden with _id 2 and priority 0, got %v", added)
+	}
+
+	fake.deleteErr = errors.New("quota exceeded")
+	if removeErr := manager.Remove("", "db-1"); removeErr == nil {
+		t.Fatal("expected removing db-1 to fail while its server cannot be deleted")
+	}
+	if _, registered := registry.Get("db-1"); !registered {
+		t.Fatal("expected db-1 to stay registered when its server could not be deleted")
+	}
+	fake.deleteErr = nil
+	removeErr := manager.Remove("", "db-1")
+	if removeErr != nil {
+		t.Fatal(removeErr)
+	}
+	if len(fake.deleted) != 1 || fake.deleted[0] != "db-1" {
+		t.Errorf("expected retrying to delete db-1, deleted %v", fake.deleted)
+	}
+	hosts = replSet.configHosts()
+	if _, stillMember := hosts[replSet.servers[1].Addr()]; stillMember || len(hosts) != 2 {
+		t.Errorf("expected db-1 to be removed from the config, got %v", hosts)
+	}
+	if registry.Len() != 2 {
+		t.Errorf("expected 2 registered instances, got %d", registry.Len())
+	}
+}
+
+func TestFailoverPromotesSecondary(t *testing.T) {
+	replSet := newFakeReplSet(t, 3)
+	defer replSet.close()
+	fake := &fakeHost{replSet: replSet}
+	var host hostProvider.HostProvider = fake
+	registry := metadata.NewRegistry()
+	manager := NewManager("test", "test", &host, registry)
+	manager.ReplicaSet = "rs0"
+	manager.BootstrapTimeout = 2 * time.Second
+	manager.ElectionTimeout = time.Second
+
+	_, createErr := manager.CreateReplicaSet(&InstanceTemplate{Kind: "Create", Name: "db"}, 2)
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	deadHost := replSet.servers[0].Addr()
+	replSet.servers[0].Close()
+
+	primary, failErr := manager.failover(deadHost)
+	if failErr != nil {
+		t.Fatal(failErr)
+	}
+	if primary != replSet.servers[1].Addr() {
+		t.Errorf("expected db-1 at %s to be promoted, got %s", replSet.servers[1].Addr(), primary)
+	}
+	if promoted := replSet.configHosts()[primary]; promoted.Get("priority") != float64(2) {
+		t.Errorf("expected db-1 to be given priority 2, got %v", promoted)
+	}
+	if _, kept := replSet.configHosts()[deadHost]; kept {
+		t.Error("expected the forced reconfig to drop the unreachable db-0")
+	}
+
+	// the dead member is replaced in the background by a new secondary under the same name
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hosts := replSet.configHosts()
+		_, deadStays := hosts[deadHost]
+		_, replaced := hosts[replSet.servers[2].Addr()]
+		if !deadStays && replaced {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected db-0 to be replaced by %s, config has %v", replSet.servers[2].Addr(), hosts)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if len(fake.deleted) != 1 || fake.deleted[0] != "db-0" {
+		t.Errorf("expected db-0 to be deleted, got %v", fake.deleted)
+	}
+}
+
+func TestForcePrimaryRefusesWhenEveryMemberIsReachable(t *testing.T) {
+	replSet := newFakeReplSet(t, 2)
+	defer replSet.close()
+	var host hostProvider.HostProvider = &fakeHost{replSet: replSet}
+	manager := NewManager("test", "test", &host, metadata.NewRegistry())
+	manager.ReplicaSet = "rs0"
+	manager.BootstrapTimeout = 2 * time.Second
+	_, createErr := manager.CreateReplicaSet(&InstanceTemplate{Kind: "Create", Name: "db"}, 2)
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+	survivors := []*metadata.Member{}
+	for _, member := range manager.members() {
+		if member.GetName() == "db-1" {
+			survivors = append(survivors, member)
+		}
+	}
+	if _, forceErr := manager.forcePrimary(survivors); forceErr == nil || !strings.Contains(forceErr.Error(), "reachable") {
+		t.Errorf("expected a set with every member reachable not to be forced, got %v", forceErr)
+	}
+	if hosts := replSet.configHosts(); len(hosts) != 2 {
+		t.Errorf("expected the config to be left alone, got %v", hosts)
+	}
+}
+
+func TestClusterInstancesAreLeftOutOfTheManagersReplicaSet(t *testing.T) {
+	var host hostProvider.HostProvider = hostProvider.NewLocal()
+	manager := NewManager("test", "local", &host, metadata.NewRegistry())
+	manager.ReplicaSet = "rs0"
+	manager.Registry.Add(&metadata.Member{
+		Instance:   &hostProvider.LocalInstance{Name: "db-0", Zone: "local", IP: "10.0.0.1"},
+		ReplicaSet: "rs0",
+		Host:       "10.0.0.1:27017",
+		Role:       RoleSecondary,
+	}, nil)
+	manager.Registry.Add(&metadata.Member{
+		Instance:   &hostProvider.LocalInstance{Name: "cache-0", Zone: "local", IP: "10.0.0.2"},
+		ReplicaSet: "cache",
+		Host:       "10.0.0.2:27017",
+		Role:       RolePrimary,
+	}, map[string]string{ClusterLabel: "cache"})
+	if members := manager.members(); len(members) != 1 || members[0].GetName() != "db-0" {
+		t.Errorf("expected only db-0 in rs0, got %v", members)
+	}
+	if endpoints := manager.endpointInstances(); len(endpoints) != 1 || endpoints[0].GetName() != "db-0" {
+		t.Errorf("expected the cluster's primary not to be published, got %v", endpoints)
+	}
+	replicaSet, members := manager.replicaSetOf(map[string]string{ClusterLabel: "cache"})
+	if replicaSet != "cache" || len(members) != 1 || members[0].GetName() != "cache-0" {
+		t.Errorf("expected cache-0 to make up the cache replica set, got %s %v", replicaSet, members)
+	}
+}
+
+func TestReplacementTmpl(t *testing.T) {
+	var host hostProvider.HostProvider = hostProvider.NewLocal()
+	manager := NewManager("test", "local", &host, metadata.NewRegistry())
+	registered := &metadata.Member{
+		Instance: &hostProvider.LocalInstance{Name: "db-1", Zone: "local", IP: "127.0.0.1", ProcessPort: 27019},
+		Host:     "127.0.0.1:27019",
+	}
+	if tmpl := manager.replacementTmpl(registered, nil); tmpl.MachineType != "27019" || tmpl.Name != "db-1" || tmpl.Zone != "local" {
+		t.Errorf("expected a local member to be replaced on its own port, got %+v", tmpl)
+	}
+
+	manager.Platform = "EC2"
+	created := &metadata.Member{
+		Instance: &hostProvider.Ec2Instance{Name: "db-2", AvailabilityZone: "us-east-1b"},
+		Origin:   metadata.Origin{MachineType: "r5.large", SourceImage: "ami-mongo"},
+	}
+	tmpl := manager.replacementTmpl(created, map[string]string{"tier": "db"})
+	if tmpl.MachineType != "r5.large" || tmpl.SourceImage != "ami-mongo" || tmpl.Zone != "us-east-1b" || tmpl.Labels["tier"] != "db" {
+		t.Errorf("expected db-2 to be replaced from what it was created from, got %+v", tmpl)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+// instancesPath is the collection, a single instance is at instancesPath/{zone}/{name}
+const instancesPath = "/v1/instances"
+
+// InstanceRes is a single instance as served on /v1/instances/{zone}/{name}
+type InstanceRes struct {
+	Name     string                `json:"name"`
+	Zone     string                `json:"zone"`
+	Role     string                `json:"role,omitempty"`
+	Instance hostProvider.Instance `json:"instance"`
+	Labels   map[string]string     `json:"labels"`
+	Health   *InstanceStatus       `json:"health,omitempty"`
+	// Replication is how far a secondary was behind the primary when last measured
+	Replication *MemberLag `json:"replication,omitempty"`
+}
+
+// InstancePatch is the body of a PATCH on an instance, a label set to null is removed
+// and Options replace the member's replica set options
+type InstancePatch struct {
+	Labels  map[string]*string      `json:"labels,omitempty"`
+	Options *metadata.MemberOptions `json:"options,omitempty"`
+}
+
+// instancePath splits /v1/instances/{zone}/{name}
+func instancePath(urlPath string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(urlPath[len(instancesPath):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// serveInstance serves /v1/instances/{zone}/{name}
+func (m *MongoHandler) serveInstance(res http.ResponseWriter, req *http.Request) {
+	zone, name, ok := instancePath(req.URL.Path)
+	if !ok {
+		writeError(res, http.StatusNotFound, fmt.Errorf("%s is not an instance, expected %s/{zone}/{name}", req.URL.Path, instancesPath))
+		return
+	}
+	switch req.Method {
+	case "GET":
+		m.getInstance(res, zone, name)
+	case "PUT":
+		m.putInstance(res, req, zone, name)
+	case "PATCH":
+		m.patchInstance(res, req, zone, name)
+	case "DELETE":
+		m.deleteInstance(res, req, zone, name)
+	default:
+		methodNotAllowed(res, req, "GET, PUT, PATCH, DELETE")
+	}
+}
+
+// lookup finds name in zone, an instance with the same name in another zone is not found
+func (m *MongoHandler) lookup(zone, name string) (hostProvider.Instance, error) {
+	instance, ok := m.Manager.Registry.Get(name)
+	if !ok || instance.GetZone() != zone {
+		return nil, &metadata.NotFoundError{Name: zone + "/" + name}
+	}
+	return instance, nil
+}
+
+// writeInstance writes the current state of zone/name with status
+func (m *MongoHandler) writeInstance(res http.ResponseWriter, status int, zone, name string) {
+	instance, lookupErr := m.lookup(zone, name)
+	if lookupErr != nil {
+		writeError(res, http.StatusNotFound, lookupErr)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(m.instanceRes(instance))
+}
+
+// instanceRes describes instance with its labels and health
+func (m *MongoHandler) instanceRes(instance hostProvider.Instance) *InstanceRes {
+	name := instance.GetName()
+	labels, _ := m.Manager.Registry.Labels(name)
+	payload := &InstanceRes{
+		Name:     name,
+		Zone:     instance.GetZone(),
+		Role:     metadata.RoleOf(instance),
+		Instance: instance,
+		Labels:   labels,
+	}
+	if health, probed := m.Manager.Prober.Status(name); probed {
+		payload.Health = &health
+	}
+	if member, isMember := instance.(*metadata.Member); isMember {
+		if lag, measured := m.Manager.lag.member(member.Host); measured {
+			payload.Replication = &lag
+		}
+	}
+	return payload
+}
+
+func (m *MongoHandler) getInstance(res http.ResponseWriter, zone, name string) {
+	m.writeInstance(res, http.StatusOK, zone, name)
+}
+
+// putInstance starts an operation creating or registering the instance from an InstanceTemplate if it does not exist,
+// otherwise it replaces the instance's labels and replica set options
+func (m *MongoHandler) putInstance(res http.ResponseWriter, req *http.Request, zone, name string) {
+	defer req.Body.Close()
+	tmpl := &InstanceTemplate{}
+	deErr := json.NewDecoder(req.Body).Decode(tmpl)
+	if deErr != nil {
+		writeError(res, http.StatusBadRequest, deErr)
+		return
+	}
+	if (tmpl.Name != "" && tmpl.Name != name) || (tmpl.Zone != "" && tmpl.Zone != zone) {
+		writeError(res, http.StatusBadRequest, fmt.Errorf("the body names %s/%s but the path names %s/%s", tmpl.Zone, tmpl.Name, zone, name))
+		return
+	}
+	tmpl.Name, tmpl.Zone = name, zone
+	if tmpl.Kind == "" {
+		tmpl.Kind = "Create"
+	}
+	validErr := tmpl.Validate()
+	if validErr == nil && tmpl.Members > 1 {
+		validErr = errors.New("members can not be used on a single instance, POST to the collection instead")
+	}
+	if validErr != nil {
+		writeError(res, http.StatusBadRequest, validErr)
+		return
+	}
+
+	existing, exists := m.Manager.Registry.Get(name)
+	if exists && existing.GetZone() != zone {
+		writeError(res, http.StatusConflict, &metadata.ConflictError{Name: name})
+		return
+	}
+	if exists {
+		if member, isMember := existing.(*metadata.Member); isMember && !sameOptions(member.Options, tmpl.Options) {
+			optsErr := m.Manager.SetMemberOptions(name, tmpl.Options)
+			if optsErr != nil {
+				writeError(res, statusOf(optsErr), optsErr)
+				return
+			}
+		}
+		labelErr := m.Manager.Registry.SetLabels(name, tmpl.Labels)
+		if labelErr != nil {
+			writeError(res, statusOf(labelErr), labelErr)
+			return
+		}
+		m.writeInstance(res, http.StatusOK, zone, name)
+		return
+	}
+
+	writeOperation(res, m.Manager.StartCreate(tmpl, actorOf(req)))
+}
+
+// sameOptions reports whether applying b over a would leave the replica set config unchanged
+func sameOptions(a, b metadata.MemberOptions) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
+
+func (m *MongoHandler) patchInstance(res http.ResponseWriter, req *http.Request, zone, name string) {
+	defer req.Body.Close()
+	patch := &InstancePatch{}
+	deErr := json.NewDecoder(req.Body).Decode(patch)
+	if deErr != nil {
+		writeError(res, http.StatusBadRequest, deErr)
+		return
+	}
+	instance, lookupErr := m.lookup(zone, name)
+	if lookupErr != nil {
+		writeError(res, http.StatusNotFound, lookupErr)
+		return
+	}
+	if patch.Options != nil {
+		if _, isMember := instance.(*metadata.Member); !isMember || m.Manager.ReplicaSet == "" {
+			writeError(res, http.StatusBadRequest, fmt.Errorf("%s is not a replica set member and has no options", name))
+			return
+		}
+		optsErr := m.Manager.SetMemberOptions(name, *patch.Options)
+		if optsErr != nil {
+			writeError(res, statusOf(optsErr), optsErr)
+			return
+		}
+	}
+	if len(patch.Labels) > 0 {
+		labels, registered := m.Manager.Registry.Labels(name)
+		if !registered {
+			writeError(res, http.StatusNotFound, &metadata.NotFoundError{Name: zone + "/" + name})
+			return
+		}
+		for key, value := range patch.Labels {
+			if value == nil {
+				delete(labels, key)
+			} else {
+				labels[key] = *value
+			}
+		}
+		labelErr := m.Manager.Registry.SetLabels(name, labels)
+		if labelErr != nil {
+			writeError(res, statusOf(labelErr), labelErr)
+			return
+		}
+	}
+	m.writeInstance(res, http.StatusOK, zone, name)
+}
+
+func (m *MongoHandler) deleteInstance(res http.ResponseWriter, req *http.Request, zone, name string) {
+	_, lookupErr := m.lookup(zone, name)
+	if lookupErr != nil {
+		writeError(res, http.StatusNotFound, lookupErr)
+		return
+	}
+	writeOperation(res, m.Manager.StartRemove(zone, name, actorOf(req)))
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// Outcomes of a failover attempt
+const (
+	FailoverExecuted   = "executed"
+	FailoverSuppressed = "suppressed"
+	FailoverFailed     = "failed"
+)
+
+// maxFailoverRecords is how many failover records are kept
+const maxFailoverRecords = 100
+
+// FailoverPolicy guards automatic failover against flapping primaries and partitions of kubongo itself,
+// a primary is only down once the Prober's failure threshold is reached
+type FailoverPolicy struct {
+	// ManualOnly suppresses every automatic failover, only Failover triggers one
+	ManualOnly bool `json:"manualOnly"`
+	// MinInterval is the least time between two automatic failover attempts, executed or failed
+	MinInterval time.Duration `json:"minInterval"`
+	// MaxFailovers caps automatic failover attempts per Window, 0 is no cap
+	MaxFailovers int           `json:"maxFailovers"`
+	Window       time.Duration `json:"window"`
+	// RequireQuorum only fails over if a strict majority of the replica set's votes see the primary down
+	RequireQuorum bool `json:"requireQuorum"`
+}
+
+// DefaultFailoverPolicy allows one automatic failover every 5 minutes and 3 an hour, with quorum
+func DefaultFailoverPolicy() FailoverPolicy {
+	return FailoverPolicy{
+		MinInterval:   5 * time.Minute,
+		MaxFailovers:  3,
+		Window:        time.Hour,
+		RequireQuorum: true,
+	}
+}
+
+// FailoverRecord is a single executed, suppressed or failed failover
+type FailoverRecord struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from"`
+	To      string    `json:"to,omitempty"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason"`
+	Manual  bool      `json:"manual"`
+	// Count is how many times in a row the same failover was suppressed for the same reason
+	Count int `json:"count"`
+}
+
+// failoverHistory is shared by copies of a Manager
+type failoverHistory struct {
+	mutex   sync.Mutex
+	records []FailoverRecord
+	// notify is called with every new or updated record, it must not block
+	notify func(record FailoverRecord)
+}
+
+// add appends record, collapsing it into the last record if that was the same suppression, it reports whether record was new
+func (h *failoverHistory) add(record FailoverRecord) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	record.Count = 1
+	if last := len(h.records) - 1; last >= 0 && record.Outcome == FailoverSuppressed {
+		prev := &h.records[last]
+		if prev.Outcome == record.Outcome && prev.From == record.From && prev.Reason == record.Reason {
+			prev.Time = record.Time
+			prev.Count++
+			h.publish(*prev)
+			return false
+		}
+	}
+	log.Println("safeguard:94 failover", record.Outcome, "from", record.From, "to", record.To, record.Reason)
+	h.records = append(h.records, record)
+	if len(h.records) > maxFailoverRecords {
+		h.records = h.records[len(h.records)-maxFailoverRecords:]
+	}
+	h.publish(record)
+	return true
+}
+
+func (h *failoverHistory) publish(record FailoverRecord) {
+	if h.notify != nil {
+		h.notify(record)
+	}
+}
+
+func (h *failoverHistory) list() []FailoverRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]FailoverRecord{}, h.records...)
+}
+
+// suppressReason is why policy forbids an automatic failover at now given the past records, or "" if it allows one.
+// Failed attempts count like executed ones, otherwise a failover that keeps failing is retried on every probe.
+func suppressReason(policy FailoverPolicy, records []FailoverRecord, now time.Time) string {
+	if policy.ManualOnly {
+		return "automatic failover is disabled, fail over manually"
+	}
+	inWindow := 0
+	var last time.Time
+	for _, record := range records {
+		if (record.Outcome != FailoverExecuted && record.Outcome != FailoverFailed) || record.Manual {
+			continue
+		}
+		if record.Time.After(last) {
+			last = record.Time
+		}
+		if policy.Window > 0 && now.Sub(record.Time) < policy.Window {
+			inWindow++
+		}
+	}
+	if !last.IsZero() && now.Sub(last) < policy.MinInterval {
+		return fmt.Sprintf("last failover attempt was %v ago, less than the minimum interval of %v", now.Sub(last), policy.MinInterval)
+	}
+	if policy.MaxFailovers > 0 && inWindow >= policy.MaxFailovers {
+		return fmt.Sprintf("%d failover attempts in the last %v reached the cap of %d", inWindow, policy.Window, policy.MaxFailovers)
+	}
+	return ""
+}
+
+// memberVotes is how many votes member has in the replica set, 1 unless configured otherwise
+func memberVotes(member *metadata.Member) int {
+	if member.Options.Votes != nil {
+		return *member.Options.Votes
+	}
+	return 1
+}
+
+// peerVotes asks every voting survivor whether it still reaches deadHost, returning how many votes answered and how many
+// of those see it down. A survivor only sees deadHost down if its status lists deadHost with health 0.
+func peerVotes(deadHost string, survivors []*metadata.Member) (answered, down int) {
+	for _, survivor := range survivors {
+		votes := memberVotes(survivor)
+		if votes == 0 {
+			continue
+		}
+		status, statusErr := runCommand(survivor.Host, mongoWire.Doc{{Key: "replSetGetStatus", Value: 1}}, DefaultHealthCheckTimeout)
+		if statusErr != nil {
+			continue
+		}
+		answered += votes
+		for _, raw := range status.Array("members") {
+			member, _ := raw.(mongoWire.Doc)
+			if member.String("name") == deadHost && member.Get("health") != nil && member.Int("health") == 0 {
+				down += votes
+			}
+		}
+	}
+	return answered, down
+}
+
+// Failovers returns the recorded failovers, oldest first
+func (m *Manager) Failovers() []FailoverRecord {
+	return m.history.list()
+}
+
+// autoFailover fails over from the primary at deadHost after the Prober marked it down, unless the policy suppresses it
+func (m *Manager) autoFailover(deadHost string) (string, error) {
+	record := FailoverRecord{Time: time.Now(), From: deadHost}
+	if m.ReplicaSet == "" {
+		record.Outcome, record.Reason = FailoverSuppressed, "there is no replica set to fail over to"
+		m.addFailover(record, record.Time)
+		return "", fmt.Errorf("%s is down: %s", deadHost, record.Reason)
+	}
+	if reason := suppressReason(m.Policy, m.history.list(), record.Time); reason != "" {
+		record.Outcome, record.Reason = FailoverSuppressed, reason
+		m.addFailover(record, record.Time)
+		return "", fmt.Errorf("%s is down: %s", deadHost, reason)
+	}
+	if m.Policy.RequireQuorum {
+		voting := 0
+		survivors := []*metadata.Member{}
+		for _, member := range m.members() {
+			voting += memberVotes(member)
+			if member.Host != deadHost {
+				survivors = append(survivors, member)
+			}
+		}
+		// kubongo may be the one that is partitioned, so only the members' own view of the primary counts
+		answered, down := peerVotes(deadHost, survivors)
+		switch {
+		case answered == 0:
+			record.Reason = "no quorum, no surviving member answered"
+		case down*2 <= voting:
+			record.Reason = fmt.Sprintf("no quorum, only %d of %d votes see the primary down", down, voting)
+		}
+		if record.Reason != "" {
+			record.Outcome = FailoverSuppressed
+			m.addFailover(record, record.Time)
+			return "", fmt.Errorf("%s is down: %s", deadHost, record.Reason)
+		}
+	}
+	record, failErr := m.recordFailover(record, "primary failed its health checks")
+	return record.To, failErr
+}
+
+// Failover manually fails over from the primary at from, or the current primary if from is empty,
+// it is not subject to the FailoverPolicy
+func (m *Manager) Failover(from string) (FailoverRecord, error) {
+	if m.ReplicaSet == "" {
+		return FailoverRecord{}, fmt.Errorf("there is no replica set to fail over")
+	}
+	if from == "" {
+		primary, primaryErr := findPrimary(memberAddresses(m.members()))
+		if primaryErr != nil {
+			return FailoverRecord{}, primaryErr
+		}
+		from = primary
+	}
+	record := FailoverRecord{Time: time.Now(), From: from, Manual: true}
+	return m.recordFailover(record, "manually requested")
+}
+
+// recordFailover fails over from record.From and records the outcome
+func (m *Manager) recordFailover(record FailoverRecord, reason string) (FailoverRecord, error) {
+	started := time.Now()
+	m.notifyFailover(record, reason)
+	newPrimary, failErr := m.failover(record.From)
+	if failErr != nil {
+		record.Outcome, record.Reason = FailoverFailed, failErr.Error()
+	} else {
+		record.Outcome, record.Reason, record.To = FailoverExecuted, reason, newPrimary
+	}
+	m.addFailover(record, started)
+	m.notifyFailover(record, reason)
+	return record, failErr
+}
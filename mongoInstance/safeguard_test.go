@@ -0,0 +1,167 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+func TestSuppressReason(t *testing.T) {
+	now := time.Unix(10000, 0)
+	executed := func(ago time.Duration, manual bool) FailoverRecord {
+		return FailoverRecord{Time: now.Add(-ago), Outcome: FailoverExecuted, Manual: manual}
+	}
+	policy := FailoverPolicy{MinInterval: 5 * time.Minute, MaxFailovers: 2, Window: time.Hour}
+	cases := []struct {
+		name       string
+		policy     FailoverPolicy
+		records    []FailoverRecord
+		suppressed bool
+	}{
+		{"first failover", policy, nil, false},
+		{"manual only", FailoverPolicy{ManualOnly: true}, nil, true},
+		{"too soon", policy, []FailoverRecord{executed(time.Minute, false)}, true},
+		{"manual failovers do not count", policy, []FailoverRecord{executed(time.Minute, true)}, false},
+		{"suppressed failovers do not count", policy, []FailoverRecord{{Time: now, Outcome: FailoverSuppressed}}, false},
+		{"failed failovers count", policy, []FailoverRecord{{Time: now.Add(-time.Minute), Outcome: FailoverFailed}}, true},
+		{"cap reached", policy, []FailoverRecord{executed(50*time.Minute, false), executed(10*time.Minute, false)}, true},
+		{"cap window passed", policy, []FailoverRecord{executed(2*time.Hour, false), executed(10*time.Minute, false)}, false},
+	}
+	for _, c := range cases {
+		reason := suppressReason(c.policy, c.records, now)
+		if (reason != "") != c.suppressed {
+			t.Errorf("%s: expected suppressed %v, got reason %q", c.name, c.suppressed, reason)
+		}
+	}
+}
+
+func TestAutoFailoverRecordsSuppression(t *testing.T) {
+	var host hostProvider.HostProvider = &fakeHost{}
+	manager := NewManager("test", "test", &host, metadata.NewRegistry())
+	manager.ReplicaSet = "rs0"
+	manager.Policy = FailoverPolicy{ManualOnly: true}
+	for i := 0; i < 3; i++ {
+		_, failErr := manager.autoFailover("127.0.0.1:27017")
+		if failErr == nil {
+			t.Fatal("expected manual only mode to suppress the failover")
+		}
+	}
+	records := manager.Failovers()
+	if len(records) != 1 || records[0].Outcome != FailoverSuppressed || records[0].Count != 3 {
+		t.Errorf("expected a single suppression recorded 3 times, got %v", records)
+	}
+}
+
+func TestAutoFailoverBacksOffAfterAFailedPromotion(t *testing.T) {
+	manager, primary := newLagManager(t)
+	defer primary.Close()
+	manager.Policy = FailoverPolicy{MinInterval: 5 * time.Minute}
+	// every secondary is lagging so promoting one fails
+	manager.LagThresholds.MaxLag = time.Nanosecond
+	manager.checkReplication(primary.Addr())
+	_, firstErr := manager.autoFailover(primary.Addr())
+	_, retryErr := manager.autoFailover(primary.Addr())
+	if firstErr == nil || retryErr == nil {
+		t.Fatalf("expected both failovers to fail, got %v and %v", firstErr, retryErr)
+	}
+	records := manager.Failovers()
+	if len(records) != 2 || records[0].Outcome != FailoverFailed || records[1].Outcome != FailoverSuppressed {
+		t.Fatalf("expected a failed attempt then a suppressed retry, got %v", records)
+	}
+	if !strings.Contains(records[1].Reason, "minimum interval") {
+		t.Errorf("expected the retry to be held back by the minimum interval, got %s", records[1].Reason)
+	}
+}
+
+// quorumSurvivor answers replSetGetStatus with deadHost at health, or without deadHost if health is nil
+func quorumSurvivor(t *testing.T, deadHost string, health interface{}) *mongoWire.FakeServer {
+	server, serverErr := mongoWire.NewFakeServer()
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	server.Handle("replSetGetStatus", func(cmd mongoWire.Doc) mongoWire.Doc {
+		members := []interface{}{mongoWire.Doc{{Key: "name", Value: server.Addr()}, {Key: "health", Value: float64(1)}}}
+		if health != nil {
+			members = append(members, mongoWire.Doc{{Key: "name", Value: deadHost}, {Key: "health", Value: health}})
+		}
+		return ok(mongoWire.Doc{{Key: "members", Value: members}})
+	})
+	return server
+}
+
+func TestAutoFailoverNeedsAQuorum(t *testing.T) {
+	deadHost := "127.0.0.1:1"
+	cases := []struct {
+		name    string
+		healths []interface{}
+		reason  string
+	}{
+		{"no survivor answers", nil, "no surviving member answered"},
+		{"survivors do not know the primary", []interface{}{nil, nil}, "only 0 of 3 votes"},
+		{"a single survivor sees it down", []interface{}{float64(0), float64(1)}, "only 1 of 3 votes"},
+	}
+	for _, c := range cases {
+		var host hostProvider.HostProvider = &fakeHost{}
+		manager := NewManager("test", "test", &host, metadata.NewRegistry())
+		manager.ReplicaSet = "rs0"
+		manager.Policy = FailoverPolicy{RequireQuorum: true}
+		hosts := []string{deadHost, "127.0.0.1:2", "127.0.0.1:3"}
+		for i, health := range c.healths {
+			survivor := quorumSurvivor(t, deadHost, health)
+			defer survivor.Close()
+			hosts[i+1] = survivor.Addr()
+		}
+		for i, memberHost := range hosts {
+			manager.Registry.Add(&metadata.Member{
+				Instance:   &hostProvider.LocalInstance{Name: "db-" + strconv.Itoa(i), Zone: "local"},
+				ReplicaSet: "rs0",
+				Host:       memberHost,
+			}, nil)
+		}
+		_, failErr := manager.autoFailover(deadHost)
+		records := manager.Failovers()
+		if failErr == nil || len(records) != 1 || records[0].Outcome != FailoverSuppressed || !strings.Contains(records[0].Reason, c.reason) {
+			t.Errorf("%s: expected the failover to be suppressed with %q, got %v", c.name, c.reason, records)
+		}
+	}
+}
+
+func TestPeerVotes(t *testing.T) {
+	deadHost := "127.0.0.1:1"
+	down := quorumSurvivor(t, deadHost, float64(0))
+	defer down.Close()
+	up := quorumSurvivor(t, deadHost, float64(1))
+	defer up.Close()
+	unaware := quorumSurvivor(t, deadHost, nil)
+	defer unaware.Close()
+	noVotes := 0
+	survivors := []*metadata.Member{
+		{Host: down.Addr()},
+		{Host: up.Addr()},
+		{Host: unaware.Addr()},
+		{Host: down.Addr(), Options: metadata.MemberOptions{Votes: &noVotes}},
+		{Host: "127.0.0.1:2"},
+	}
+	answered, seenDown := peerVotes(deadHost, survivors)
+	if answered != 3 || seenDown != 1 {
+		t.Errorf("expected 3 votes to answer and 1 to see the primary down, got %d and %d", answered, seenDown)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"time"
+
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// ServerStats are the figures kubongo reads from a mongod's serverStatus, replSetGetStatus and oplog
+type ServerStats struct {
+	Address              string           `json:"address"`
+	ConnectionsCurrent   int64            `json:"connectionsCurrent"`
+	ConnectionsAvailable int64            `json:"connectionsAvailable"`
+	Opcounters           map[string]int64 `json:"opcounters"`
+	// ResidentMB and VirtualMB are serverStatus' mem figures, mongod reports them in megabytes
+	ResidentMB int64 `json:"residentMB"`
+	VirtualMB  int64 `json:"virtualMB"`
+	// ReplicaSet is false for a mongod that is not in a replica set, the fields below are only set if it is
+	ReplicaSet bool `json:"replicaSet"`
+	// MemberState is replSetGetStatus' myState, 1 is PRIMARY and 2 SECONDARY
+	MemberState int `json:"memberState"`
+	// Lag is how far the member's last applied optime is behind the primary's
+	Lag time.Duration `json:"lag"`
+	// OplogWindow is the time between the oldest and newest entry of the oplog, how long a member can be down and still catch up
+	OplogWindow time.Duration `json:"oplogWindow"`
+}
+
+// FetchStats reads the ServerStats of the mongod at address, a mongod outside a replica set only fails serverStatus
+func FetchStats(address string, timeout time.Duration) (ServerStats, error) {
+	stats := ServerStats{Address: address, Opcounters: make(map[string]int64)}
+	conn, dialErr := dial(address, timeout)
+	if dialErr != nil {
+		return stats, dialErr
+	}
+	defer conn.Close()
+	status, statusErr := conn.RunCommand("admin", mongoWire.Doc{{Key: "serverStatus", Value: 1}})
+	if statusErr != nil {
+		return stats, statusErr
+	}
+	connections := status.Doc("connections")
+	stats.ConnectionsCurrent = connections.Int("current")
+	stats.ConnectionsAvailable = connections.Int("available")
+	for _, counter := range status.Doc("opcounters") {
+		stats.Opcounters[counter.Key] = int64(mongoWire.ToFloat(counter.Value))
+	}
+	stats.ResidentMB = status.Doc("mem").Int("resident")
+	stats.VirtualMB = status.Doc("mem").Int("virtual")
+
+	replStatus, replErr := conn.RunCommand("admin", mongoWire.Doc{{Key: "replSetGetStatus", Value: 1}})
+	if replErr != nil {
+		// NoReplicationEnabled or NotYetInitialized, neither is a failure of the mongod
+		return stats, nil
+	}
+	stats.ReplicaSet = true
+	stats.MemberState = int(replStatus.Int("myState"))
+	stats.Lag = replicationLag(replStatus)
+	stats.OplogWindow = oplogWindow(conn)
+	return stats, nil
+}
+
+// replicationLag is how far the member replSetGetStatus was run on is behind the primary, 0 if either is not in status
+func replicationLag(status mongoWire.Doc) time.Duration {
+	var self, primary time.Time
+	for _, raw := range status.Array("members") {
+		member, _ := raw.(mongoWire.Doc)
+		optime, _ := member.Get("optimeDate").(time.Time)
+		if member.Bool("self") {
+			self = optime
+		}
+		if member.String("stateStr") == "PRIMARY" {
+			primary = optime
+		}
+	}
+	if self.IsZero() || primary.IsZero() || !primary.After(self) {
+		return 0
+	}
+	return primary.Sub(self)
+}
+
+// oplogWindow is the time between the first and last entry of local.oplog.rs, 0 if it cannot be read
+func oplogWindow(conn *mongoWire.Conn) time.Duration {
+	var bounds [2]uint32
+	for i, order := range []int32{1, -1} {
+		reply, findErr := conn.RunCommand("local", mongoWire.Doc{
+			{Key: "find", Value: "oplog.rs"},
+			{Key: "sort", Value: mongoWire.Doc{{Key: "$natural", Value: order}}},
+			{Key: "limit", Value: int32(1)},
+			{Key: "projection", Value: mongoWire.Doc{{Key: "ts", Value: int32(1)}}},
+		})
+		if findErr != nil {
+			return 0
+		}
+		batch := reply.Doc("cursor").Array("firstBatch")
+		if len(batch) == 0 {
+			return 0
+		}
+		entry, _ := batch[0].(mongoWire.Doc)
+		ts, _ := entry.Get("ts").(mongoWire.Timestamp)
+		bounds[i] = ts.T
+	}
+	if bounds[1] < bounds[0] {
+		return 0
+	}
+	return time.Duration(bounds[1]-bounds[0]) * time.Second
+}
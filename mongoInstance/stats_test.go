@@ -0,0 +1,116 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"github.com/cpg1111/kubongo/metrics"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// newStatsServer is a secondary 90s behind its primary with a 2h oplog
+func newStatsServer(t *testing.T) *mongoWire.FakeServer {
+	server, serverErr := mongoWire.NewFakeServer()
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	server.Handle("serverStatus", func(cmd mongoWire.Doc) mongoWire.Doc {
+		return ok(mongoWire.Doc{
+			{Key: "connections", Value: mongoWire.Doc{{Key: "current", Value: int32(12)}, {Key: "available", Value: int32(800)}}},
+			{Key: "opcounters", Value: mongoWire.Doc{{Key: "insert", Value: int64(5)}, {Key: "query", Value: int64(40)}}},
+			{Key: "mem", Value: mongoWire.Doc{{Key: "resident", Value: int32(256)}, {Key: "virtual", Value: int32(1024)}}},
+		})
+	})
+	primaryOptime := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.Handle("replSetGetStatus", func(cmd mongoWire.Doc) mongoWire.Doc {
+		return ok(mongoWire.Doc{
+			{Key: "myState", Value: int32(2)},
+			{Key: "members", Value: []interface{}{
+				mongoWire.Doc{{Key: "name", Value: "db-0:27017"}, {Key: "stateStr", Value: "PRIMARY"}, {Key: "optimeDate", Value: primaryOptime}},
+				mongoWire.Doc{{Key: "name", Value: server.Addr()}, {Key: "stateStr", Value: "SECONDARY"}, {Key: "self", Value: true}, {Key: "optimeDate", Value: primaryOptime.Add(-90 * time.Second)}},
+			}},
+		})
+	})
+	server.Handle("find", func(cmd mongoWire.Doc) mongoWire.Doc {
+		ts := mongoWire.Timestamp{T: uint32(primaryOptime.Unix()), I: 1}
+		if cmd.Doc("sort").Int("$natural") == 1 {
+			ts.T -= 2 * 60 * 60
+		}
+		batch := []interface{}{mongoWire.Doc{{Key: "ts", Value: ts}}}
+		return ok(mongoWire.Doc{{Key: "cursor", Value: mongoWire.Doc{{Key: "firstBatch", Value: batch}}}})
+	})
+	return server
+}
+
+func TestFetchStats(t *testing.T) {
+	server := newStatsServer(t)
+	defer server.Close()
+	stats, statsErr := FetchStats(server.Addr(), time.Second)
+	if statsErr != nil {
+		t.Fatal(statsErr)
+	}
+	if stats.ConnectionsCurrent != 12 || stats.ConnectionsAvailable != 800 || stats.Opcounters["query"] != 40 || stats.ResidentMB != 256 {
+		t.Errorf("expected serverStatus to be read, got %+v", stats)
+	}
+	if !stats.ReplicaSet || stats.MemberState != 2 || stats.Lag != 90*time.Second || stats.OplogWindow != 2*time.Hour {
+		t.Errorf("expected a secondary 90s behind with a 2h oplog, got %+v", stats)
+	}
+
+	standalone, _ := mongoWire.NewFakeServer()
+	defer standalone.Close()
+	if _, statsErr = FetchStats(standalone.Addr(), time.Second); statsErr == nil {
+		t.Error("expected a mongod without serverStatus to fail")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	server := newStatsServer(t)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr())
+	portNum, _ := strconv.Atoi(port)
+	var host hostProvider.HostProvider = hostProvider.NewLocal()
+	manager := NewManager("test", "local", &host, metadata.NewRegistry())
+	manager.Registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local-a", IP: "127.0.0.1", ProcessPort: portNum}, nil)
+	manager.Registry.Add(&hostProvider.LocalInstance{Name: "db-2", Zone: "local-a", IP: "127.0.0.1", ProcessPort: 1}, nil)
+
+	registry := metrics.NewRegistry()
+	registry.Register(manager.Collector())
+	out := &bytes.Buffer{}
+	registry.WriteTo(out)
+	for _, line := range []string{
+		"# TYPE mongodb_replset_lag_seconds gauge",
+		`mongodb_replset_lag_seconds{name="db-1",zone="local-a",role="unknown"} 90`,
+		`mongodb_oplog_window_seconds{name="db-1",zone="local-a",role="unknown"} 7200`,
+		`mongodb_connections{name="db-1",zone="local-a",role="unknown",state="current"} 12`,
+		`mongodb_opcounters_total{name="db-1",zone="local-a",role="unknown",type="insert"} 5`,
+		`mongodb_memory_bytes{name="db-1",zone="local-a",role="unknown",type="resident"} 2.68435456e+08`,
+		`mongodb_up{name="db-1",zone="local-a",role="unknown"} 1`,
+		`mongodb_up{name="db-2",zone="local-a",role="unknown"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %s in\n%s", line, out)
+		}
+	}
+	if strings.Contains(out.String(), `mongodb_connections{name="db-2"`) {
+		t.Errorf("expected no figures for the unreachable db-2, got\n%s", out)
+	}
+}
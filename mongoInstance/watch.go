@@ -0,0 +1,165 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+)
+
+// Watch event types besides the registry's ADDED, MODIFIED and DELETED
+const (
+	// EventHealth carries an InstanceStatus whenever an instance changes state
+	EventHealth = "HEALTH"
+	// EventFailover carries a FailoverRecord whenever a failover is executed, suppressed or fails
+	EventFailover = "FAILOVER"
+	// EventError ends a watch, Message says why
+	EventError = "ERROR"
+)
+
+// WatchEvent is a single line of a watch stream, which fields are set depends on Type
+type WatchEvent struct {
+	Type            string                `json:"type"`
+	ResourceVersion uint64                `json:"resourceVersion,omitempty"`
+	Name            string                `json:"name,omitempty"`
+	Instance        hostProvider.Instance `json:"instance,omitempty"`
+	Labels          map[string]string     `json:"labels,omitempty"`
+	Health          *InstanceStatus       `json:"health,omitempty"`
+	Failover        *FailoverRecord       `json:"failover,omitempty"`
+	Message         string                `json:"message,omitempty"`
+}
+
+// watchEncoder writes WatchEvents to a response as JSON lines or as Server-Sent Events
+type watchEncoder struct {
+	res     http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+}
+
+func (w *watchEncoder) encode(event *WatchEvent) error {
+	data, jErr := json.Marshal(event)
+	if jErr != nil {
+		return jErr
+	}
+	var writeErr error
+	if w.sse {
+		if event.ResourceVersion != 0 {
+			fmt.Fprintf(w.res, "id: %d\n", event.ResourceVersion)
+		}
+		_, writeErr = fmt.Fprintf(w.res, "event: %s\ndata: %s\n\n", event.Type, data)
+	} else {
+		_, writeErr = fmt.Fprintf(w.res, "%s\n", data)
+	}
+	w.flusher.Flush()
+	return writeErr
+}
+
+func wantsEventStream(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+}
+
+// Watch for GET method on /instances?watch=true, streams every registry change, instance health transition and failover
+// as JSON lines, or as Server-Sent Events if the client accepts text/event-stream.
+// Registry changes after ?resourceVersion=, or an SSE client's Last-Event-ID, are replayed first.
+func (m *MongoHandler) Watch(res http.ResponseWriter, req *http.Request) {
+	flusher, canFlush := res.(http.Flusher)
+	if !canFlush {
+		writeError(res, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	sse := wantsEventStream(req)
+	from := req.URL.Query().Get("resourceVersion")
+	if lastID := req.Header.Get("Last-Event-ID"); sse && lastID != "" {
+		from = lastID
+	}
+	resourceVersion := uint64(0)
+	if from != "" {
+		var parseErr error
+		resourceVersion, parseErr = strconv.ParseUint(from, 10, 64)
+		if parseErr != nil {
+			writeError(res, http.StatusBadRequest, fmt.Errorf("invalid resourceVersion %q", from))
+			return
+		}
+	}
+	// subscribe before watching so no health transition between the two is lost
+	events, unsubscribe := m.Manager.Subscribe()
+	defer unsubscribe()
+	watcher, watchErr := m.Manager.Registry.Watch(resourceVersion)
+	if watchErr != nil {
+		status := http.StatusInternalServerError
+		if metadata.IsGone(watchErr) {
+			status = http.StatusGone
+		}
+		writeError(res, status, watchErr)
+		return
+	}
+	defer watcher.Stop()
+
+	if sse {
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+	} else {
+		res.Header().Set("Content-Type", "application/json")
+	}
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := &watchEncoder{res: res, flusher: flusher, sse: sse}
+	for {
+		var event *WatchEvent
+		select {
+		case <-req.Context().Done():
+			return
+		case change, ok := <-watcher.ResultChan():
+			if !ok {
+				if watcher.Err() != nil {
+					encoder.encode(&WatchEvent{Type: EventError, Message: watcher.Err().Error()})
+				}
+				return
+			}
+			event = &WatchEvent{
+				Type:            change.Type,
+				ResourceVersion: change.ResourceVersion,
+				Name:            change.Name,
+				Instance:        change.Instance,
+				Labels:          change.Labels,
+			}
+		case managerEvent, ok := <-events:
+			if !ok {
+				encoder.encode(&WatchEvent{Type: EventError, Message: "watcher fell too far behind the health checks"})
+				return
+			}
+			switch castEvent := managerEvent.(type) {
+			case InstanceStatus:
+				event = &WatchEvent{Type: EventHealth, Name: castEvent.Name, Health: &castEvent}
+			case FailoverRecord:
+				event = &WatchEvent{Type: EventFailover, Failover: &castEvent}
+			default:
+				continue
+			}
+		}
+		encodeErr := encoder.encode(event)
+		if encodeErr != nil {
+			log.Println("watch:161 watch stream ended:", encodeErr)
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoInstance
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	"github.com/cpg1111/kubongo/metadata"
+	"golang.org/x/net/context"
+)
+
+// readLine reads the next line of a stream, failing the test if none arrives in time
+func readLine(t *testing.T, lines chan string) string {
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the stream")
+	}
+	return ""
+}
+
+func openStream(t *testing.T, url string, header http.Header) (chan string, func()) {
+	req, _ := http.NewRequest("GET", url, nil)
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	res, resErr := http.DefaultClient.Do(req)
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %s", res.Status)
+	}
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				lines <- scanner.Text()
+			}
+		}
+	}()
+	return lines, func() { res.Body.Close() }
+}
+
+func TestWatchStream(t *testing.T) {
+	registry := metadata.NewRegistry()
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, nil)
+	handler := NewHandler("local", "test", "", registry)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	lines, stop := openStream(t, server.URL+"/instances?watch=true", nil)
+	defer stop()
+	event := &WatchEvent{}
+	json.Unmarshal([]byte(readLine(t, lines)), event)
+	if event.Type != metadata.Added || event.Name != "db-0" {
+		t.Errorf("expected db-0 to be listed first, got %+v", event)
+	}
+
+	registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local"}, nil)
+	event = &WatchEvent{}
+	json.Unmarshal([]byte(readLine(t, lines)), event)
+	if event.Type != metadata.Added || event.Name != "db-1" || event.ResourceVersion != 2 {
+		t.Errorf("expected db-1 to be added at 2, got %+v", event)
+	}
+
+	handler.Manager.Prober.Sync(map[string]string{"db-1": "127.0.0.1:1"})
+	defer handler.Manager.Prober.Stop()
+	handler.Manager.Prober.SetConfig(ProbeConfig{Interval: time.Hour, FailureThreshold: 1, SuccessThreshold: 1})
+	handler.Manager.Prober.record("db-1", Health{Healthy: false})
+	for {
+		event = &WatchEvent{}
+		json.Unmarshal([]byte(readLine(t, lines)), event)
+		if event.Type == EventHealth && event.Health.State == StateDown {
+			break
+		}
+	}
+	if event.Name != "db-1" {
+		t.Errorf("expected db-1 to go down, got %+v", event)
+	}
+}
+
+func TestWatchServerSentEvents(t *testing.T) {
+	registry := metadata.NewRegistry()
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, nil)
+	registry.Add(&hostProvider.LocalInstance{Name: "db-1", Zone: "local"}, nil)
+	server := httptest.NewServer(NewHandler("local", "test", "", registry))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	header.Set("Last-Event-ID", "1")
+	lines, stop := openStream(t, server.URL+"/instances", header)
+	defer stop()
+	expected := []string{"id: 2", "event: ADDED", "data: {\"type\":\"ADDED\",\"resourceVersion\":2,\"name\":\"db-1\""}
+	for _, want := range expected {
+		if line := readLine(t, lines); !strings.HasPrefix(line, want) {
+			t.Errorf("expected %s, got %s", want, line)
+		}
+	}
+
+	res, resErr := http.Get(server.URL + "/instances?watch=true&resourceVersion=9")
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusGone {
+		t.Errorf("expected watching from a future version to be 410, got %s", res.Status)
+	}
+}
+
+func TestWatchEndsWhenTheClientGoes(t *testing.T) {
+	registry := metadata.NewRegistry()
+	registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, nil)
+	handler := NewHandler("local", "test", "", registry)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/instances?watch=true", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		handler.Watch(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("watch kept running after the request was cancelled")
+	}
+}
@@ -0,0 +1,460 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// This is just enough BSON to run admin commands against mongod and read their replies
+
+// BSON element types kubongo encodes or decodes
+const (
+	typeDouble    = 0x01
+	typeString    = 0x02
+	typeDocument  = 0x03
+	typeArray     = 0x04
+	typeBinary    = 0x05
+	typeObjectID  = 0x07
+	typeBool      = 0x08
+	typeDateTime  = 0x09
+	typeNull      = 0x0A
+	typeInt32     = 0x10
+	typeTimestamp = 0x11
+	typeInt64     = 0x12
+)
+
+// BSON element types kubongo skips over when decoding because no command it runs needs them
+const (
+	typeUndefined  = 0x06
+	typeRegex      = 0x0B
+	typeDBPointer  = 0x0C
+	typeJavaScript = 0x0D
+	typeSymbol     = 0x0E
+	typeCodeScope  = 0x0F
+	typeDecimal128 = 0x13
+	typeMaxKey     = 0x7F
+	typeMinKey     = 0xFF
+)
+
+// skipped stands in for a value of a type kubongo does not decode, readDoc leaves it out of the document
+type skipped struct{}
+
+// Elem is a single key and value of a Doc
+type Elem struct {
+	Key   string
+	Value interface{}
+}
+
+// Doc is an ordered BSON document, order matters because a command's name must be its first key
+type Doc []Elem
+
+// Timestamp is the BSON timestamp type used by the oplog
+type Timestamp struct {
+	T uint32
+	I uint32
+}
+
+// ObjectID is a BSON ObjectId
+type ObjectID [12]byte
+
+// Get returns the value of key or nil
+func (d Doc) Get(key string) interface{} {
+	for i := range d {
+		if d[i].Key == key {
+			return d[i].Value
+		}
+	}
+	return nil
+}
+
+// Doc returns the sub document under key or nil
+func (d Doc) Doc(key string) Doc {
+	sub, _ := d.Get(key).(Doc)
+	return sub
+}
+
+// String returns the string under key or ""
+func (d Doc) String(key string) string {
+	str, _ := d.Get(key).(string)
+	return str
+}
+
+// Bool returns the value under key as a bool, numbers are true when not 0 the way mongod treats them
+func (d Doc) Bool(key string) bool {
+	switch v := d.Get(key).(type) {
+	case bool:
+		return v
+	case int32, int64, float64:
+		return ToFloat(v) != 0
+	}
+	return false
+}
+
+// Int returns the numeric value under key as an int64
+func (d Doc) Int(key string) int64 {
+	return int64(ToFloat(d.Get(key)))
+}
+
+// Array returns the array under key or nil
+func (d Doc) Array(key string) []interface{} {
+	arr, _ := d.Get(key).([]interface{})
+	return arr
+}
+
+// ToFloat converts any BSON number to a float64, anything else is 0
+func ToFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+// Marshal encodes a Doc into BSON
+func Marshal(doc Doc) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := writeDoc(buf, doc)
+	return buf.Bytes(), err
+}
+
+func writeCString(buf *bytes.Buffer, s string) error {
+	if bytes.IndexByte([]byte(s), 0) >= 0 {
+		return fmt.Errorf("bson: key %q contains a null byte", s)
+	}
+	buf.WriteString(s)
+	buf.WriteByte(0)
+	return nil
+}
+
+func writeInt32(buf *bytes.Buffer, i int32) {
+	binary.Write(buf, binary.LittleEndian, i)
+}
+
+func writeDoc(buf *bytes.Buffer, doc Doc) error {
+	start := buf.Len()
+	writeInt32(buf, 0) // length, filled in below
+	for i := range doc {
+		elemErr := writeElem(buf, doc[i].Key, doc[i].Value)
+		if elemErr != nil {
+			return elemErr
+		}
+	}
+	buf.WriteByte(0)
+	binary.LittleEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start))
+	return nil
+}
+
+func mapToDoc(m map[string]interface{}) Doc {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	doc := make(Doc, len(keys))
+	for i, k := range keys {
+		doc[i] = Elem{k, m[k]}
+	}
+	return doc
+}
+
+func writeElem(buf *bytes.Buffer, key string, value interface{}) error {
+	typePos := buf.Len()
+	buf.WriteByte(0) // type, filled in below
+	keyErr := writeCString(buf, key)
+	if keyErr != nil {
+		return keyErr
+	}
+	var elemType byte
+	switch v := value.(type) {
+	case nil:
+		elemType = typeNull
+	case float64:
+		elemType = typeDouble
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+	case string:
+		elemType = typeString
+		writeInt32(buf, int32(len(v)+1))
+		buf.WriteString(v)
+		buf.WriteByte(0)
+	case Doc:
+		elemType = typeDocument
+		if docErr := writeDoc(buf, v); docErr != nil {
+			return docErr
+		}
+	case map[string]interface{}:
+		elemType = typeDocument
+		if docErr := writeDoc(buf, mapToDoc(v)); docErr != nil {
+			return docErr
+		}
+	case []interface{}:
+		elemType = typeArray
+		arr := make(Doc, len(v))
+		for i := range v {
+			arr[i] = Elem{fmt.Sprintf("%d", i), v[i]}
+		}
+		if docErr := writeDoc(buf, arr); docErr != nil {
+			return docErr
+		}
+	case []Doc:
+		elemType = typeArray
+		arr := make(Doc, len(v))
+		for i := range v {
+			arr[i] = Elem{fmt.Sprintf("%d", i), v[i]}
+		}
+		if docErr := writeDoc(buf, arr); docErr != nil {
+			return docErr
+		}
+	case []string:
+		elemType = typeArray
+		arr := make(Doc, len(v))
+		for i := range v {
+			arr[i] = Elem{fmt.Sprintf("%d", i), v[i]}
+		}
+		if docErr := writeDoc(buf, arr); docErr != nil {
+			return docErr
+		}
+	case []byte:
+		elemType = typeBinary
+		writeInt32(buf, int32(len(v)))
+		buf.WriteByte(0) // generic subtype
+		buf.Write(v)
+	case ObjectID:
+		elemType = typeObjectID
+		buf.Write(v[:])
+	case bool:
+		elemType = typeBool
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case time.Time:
+		elemType = typeDateTime
+		binary.Write(buf, binary.LittleEndian, v.UnixNano()/int64(time.Millisecond))
+	case int:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			elemType = typeInt32
+			writeInt32(buf, int32(v))
+		} else {
+			elemType = typeInt64
+			binary.Write(buf, binary.LittleEndian, int64(v))
+		}
+	case int32:
+		elemType = typeInt32
+		writeInt32(buf, v)
+	case Timestamp:
+		elemType = typeTimestamp
+		binary.Write(buf, binary.LittleEndian, v.I)
+		binary.Write(buf, binary.LittleEndian, v.T)
+	case int64:
+		elemType = typeInt64
+		binary.Write(buf, binary.LittleEndian, v)
+	default:
+		return fmt.Errorf("bson: cannot encode %s of type %T", key, value)
+	}
+	buf.Bytes()[typePos] = elemType
+	return nil
+}
+
+var errShort = errors.New("bson: document is truncated")
+
+// Unmarshal decodes a single BSON document
+func Unmarshal(data []byte) (Doc, error) {
+	doc, _, err := readDoc(data)
+	return doc, err
+}
+
+// readDoc decodes the document at the start of data and returns it with the number of bytes it took
+func readDoc(data []byte) (Doc, int, error) {
+	if len(data) < 5 {
+		return nil, 0, errShort
+	}
+	length := int(binary.LittleEndian.Uint32(data))
+	if length < 5 || length > len(data) || data[length-1] != 0 {
+		return nil, 0, errShort
+	}
+	doc := Doc{}
+	pos := 4
+	for pos < length-1 {
+		elemType := data[pos]
+		pos++
+		keyEnd := bytes.IndexByte(data[pos:length], 0)
+		if keyEnd < 0 {
+			return nil, 0, errShort
+		}
+		key := string(data[pos : pos+keyEnd])
+		pos += keyEnd + 1
+		value, n, valErr := readValue(elemType, data[pos:length-1])
+		if valErr != nil {
+			return nil, 0, valErr
+		}
+		pos += n
+		if _, skip := value.(skipped); skip {
+			continue
+		}
+		doc = append(doc, Elem{key, value})
+	}
+	return doc, length, nil
+}
+
+func readValue(elemType byte, data []byte) (interface{}, int, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return errShort
+		}
+		return nil
+	}
+	switch elemType {
+	case typeDouble:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), 8, nil
+	case typeString:
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		strLen := int(binary.LittleEndian.Uint32(data))
+		if strLen < 1 || len(data) < 4+strLen {
+			return nil, 0, errShort
+		}
+		return string(data[4 : 4+strLen-1]), 4 + strLen, nil
+	case typeDocument:
+		return readDoc(data)
+	case typeArray:
+		arrDoc, n, err := readDoc(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		arr := make([]interface{}, len(arrDoc))
+		for i := range arrDoc {
+			arr[i] = arrDoc[i].Value
+		}
+		return arr, n, nil
+	case typeBinary:
+		if err := need(5); err != nil {
+			return nil, 0, err
+		}
+		binLen := int(binary.LittleEndian.Uint32(data))
+		if binLen < 0 || len(data) < 5+binLen {
+			return nil, 0, errShort
+		}
+		return append([]byte{}, data[5:5+binLen]...), 5 + binLen, nil
+	case typeObjectID:
+		if err := need(12); err != nil {
+			return nil, 0, err
+		}
+		var oid ObjectID
+		copy(oid[:], data)
+		return oid, 12, nil
+	case typeBool:
+		if err := need(1); err != nil {
+			return nil, 0, err
+		}
+		return data[0] != 0, 1, nil
+	case typeDateTime:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		ms := int64(binary.LittleEndian.Uint64(data))
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC(), 8, nil
+	case typeNull:
+		return nil, 0, nil
+	case typeInt32:
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		return int32(binary.LittleEndian.Uint32(data)), 4, nil
+	case typeTimestamp:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return Timestamp{I: binary.LittleEndian.Uint32(data), T: binary.LittleEndian.Uint32(data[4:])}, 8, nil
+	case typeInt64:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return int64(binary.LittleEndian.Uint64(data)), 8, nil
+	}
+	n, skipErr := skipValue(elemType, data)
+	if skipErr != nil {
+		return nil, 0, skipErr
+	}
+	return skipped{}, n, nil
+}
+
+// skipValue returns the length of a value of a type kubongo does not decode, so a reply carrying e.g. a decimal128
+// still decodes
+func skipValue(elemType byte, data []byte) (int, error) {
+	stringLen := func() (int, error) {
+		if len(data) < 4 {
+			return 0, errShort
+		}
+		n := 4 + int(binary.LittleEndian.Uint32(data))
+		if n < 5 || n > len(data) {
+			return 0, errShort
+		}
+		return n, nil
+	}
+	switch elemType {
+	case typeUndefined, typeMaxKey, typeMinKey:
+		return 0, nil
+	case typeDecimal128:
+		if len(data) < 16 {
+			return 0, errShort
+		}
+		return 16, nil
+	case typeJavaScript, typeSymbol:
+		return stringLen()
+	case typeDBPointer:
+		n, err := stringLen()
+		if err != nil || len(data) < n+12 {
+			return 0, errShort
+		}
+		return n + 12, nil
+	case typeCodeScope:
+		if len(data) < 4 {
+			return 0, errShort
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		if n < 4 || n > len(data) {
+			return 0, errShort
+		}
+		return n, nil
+	case typeRegex:
+		pattern := bytes.IndexByte(data, 0)
+		if pattern < 0 {
+			return 0, errShort
+		}
+		options := bytes.IndexByte(data[pattern+1:], 0)
+		if options < 0 {
+			return 0, errShort
+		}
+		return pattern + options + 2, nil
+	}
+	return 0, fmt.Errorf("bson: unsupported element type 0x%x", elemType)
+}
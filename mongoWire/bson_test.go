@@ -0,0 +1,88 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	now := time.Unix(1450000000, 123000000).UTC()
+	doc := Doc{
+		{"replSetInitiate", Doc{
+			{"_id", "rs0"},
+			{"members", []interface{}{Doc{{"_id", int32(0)}, {"host", "10.0.0.1:27017"}}}},
+		}},
+		{"ok", float64(1)},
+		{"flag", true},
+		{"big", int64(1) << 40},
+		{"when", now},
+		{"ts", Timestamp{T: 1450000000, I: 3}},
+		{"nothing", nil},
+		{"tags", []string{"a", "b"}},
+	}
+	raw, marshErr := Marshal(doc)
+	if marshErr != nil {
+		t.Fatal(marshErr)
+	}
+	decoded, unmarshErr := Unmarshal(raw)
+	if unmarshErr != nil {
+		t.Fatal(unmarshErr)
+	}
+	if decoded.Doc("replSetInitiate").String("_id") != "rs0" {
+		t.Error("nested document did not survive the round trip")
+	}
+	members := decoded.Doc("replSetInitiate").Array("members")
+	if len(members) != 1 || members[0].(Doc).String("host") != "10.0.0.1:27017" {
+		t.Errorf("array did not survive the round trip: %v", members)
+	}
+	if !decoded.Bool("ok") || !decoded.Bool("flag") || decoded.Int("big") != int64(1)<<40 {
+		t.Error("scalars did not survive the round trip")
+	}
+	if !decoded.Get("when").(time.Time).Equal(now) {
+		t.Errorf("expected %v, got %v", now, decoded.Get("when"))
+	}
+	if !reflect.DeepEqual(decoded.Get("ts"), Timestamp{T: 1450000000, I: 3}) {
+		t.Errorf("timestamp did not survive the round trip: %v", decoded.Get("ts"))
+	}
+	if _, truncErr := Unmarshal(raw[:len(raw)-3]); truncErr == nil {
+		t.Error("expected an error for a truncated document")
+	}
+}
+
+func TestUnmarshalSkipsTypesItDoesNotDecode(t *testing.T) {
+	body := &bytes.Buffer{}
+	body.WriteString("\x10before\x00\x01\x00\x00\x00")
+	body.WriteString("\x13amount\x00" + strings.Repeat("\x00", 16))
+	body.WriteString("\x0bpattern\x00^db\x00i\x00")
+	body.WriteString("\xfflow\x00")
+	body.WriteString("\x02after\x00\x02\x00\x00\x00x\x00")
+	body.WriteByte(0)
+	data := append([]byte{byte(body.Len() + 4), 0, 0, 0}, body.Bytes()...)
+
+	decoded, unmarshErr := Unmarshal(data)
+	if unmarshErr != nil {
+		t.Fatal(unmarshErr)
+	}
+	if len(decoded) != 2 || decoded.Int("before") != 1 || decoded.String("after") != "x" {
+		t.Errorf("expected the decimal128, regex and minKey to be skipped, got %v", decoded)
+	}
+	if _, unknownErr := Unmarshal([]byte{8, 0, 0, 0, 0x42, 'k', 0, 0}); unknownErr == nil {
+		t.Error("expected an element type with no known length to fail")
+	}
+}
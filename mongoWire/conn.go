@@ -0,0 +1,179 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// opMsg is the OP_MSG opcode, supported by mongod 3.6 and later and the only one left in 6.0
+const opMsg = 2013
+
+// maxMessageSize guards against reading garbage lengths, mongod's own limit is 48MB
+const maxMessageSize = 48 * 1000 * 1000
+
+// header is the standard message header in front of every wire protocol message
+type header struct {
+	MessageLength int32
+	RequestID     int32
+	ResponseTo    int32
+	OpCode        int32
+}
+
+// CommandError is a reply to a command with ok: 0
+type CommandError struct {
+	Code     int64
+	CodeName string
+	Message  string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("mongo command failed: %s (%s %d)", e.Message, e.CodeName, e.Code)
+}
+
+// IsCommandNotFound returns true if err is mongod telling us it does not know the command, e.g. hello before 4.4.2
+func IsCommandNotFound(err error) bool {
+	cmdErr, ok := err.(*CommandError)
+	return ok && (cmdErr.Code == 59 || cmdErr.CodeName == "CommandNotFound")
+}
+
+// Conn is a connection to a single mongod, commands are sent one at a time
+type Conn struct {
+	conn      net.Conn
+	mutex     sync.Mutex
+	requestID int32
+	// Timeout bounds each command's round trip
+	Timeout time.Duration
+}
+
+// Dial opens a connection to the mongod at address, a host:port
+func Dial(address string, timeout time.Duration) (*Conn, error) {
+	conn, dialErr := net.DialTimeout("tcp", address, timeout)
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	return &Conn{conn: conn, Timeout: timeout}, nil
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func writeMessage(w io.Writer, requestID, responseTo int32, doc Doc) error {
+	body, bErr := Marshal(doc)
+	if bErr != nil {
+		return bErr
+	}
+	msg := &bytes.Buffer{}
+	binary.Write(msg, binary.LittleEndian, header{
+		MessageLength: int32(16 + 4 + 1 + len(body)),
+		RequestID:     requestID,
+		ResponseTo:    responseTo,
+		OpCode:        opMsg,
+	})
+	binary.Write(msg, binary.LittleEndian, uint32(0)) // flagBits
+	msg.WriteByte(0)                                  // section kind 0, a single body document
+	msg.Write(body)
+	_, wErr := w.Write(msg.Bytes())
+	return wErr
+}
+
+// readMessage reads an OP_MSG and returns its header and body document, document sequences are skipped
+func readMessage(r io.Reader) (header, Doc, error) {
+	hdr := header{}
+	hdrErr := binary.Read(r, binary.LittleEndian, &hdr)
+	if hdrErr != nil {
+		return hdr, nil, hdrErr
+	}
+	if hdr.MessageLength < 21 || hdr.MessageLength > maxMessageSize {
+		return hdr, nil, fmt.Errorf("mongo wire: invalid message length %d", hdr.MessageLength)
+	}
+	rest := make([]byte, hdr.MessageLength-16)
+	_, readErr := io.ReadFull(r, rest)
+	if readErr != nil {
+		return hdr, nil, readErr
+	}
+	if hdr.OpCode != opMsg {
+		return hdr, nil, fmt.Errorf("mongo wire: unsupported opcode %d", hdr.OpCode)
+	}
+	flags := binary.LittleEndian.Uint32(rest)
+	end := len(rest)
+	if flags&1 != 0 { // checksumPresent
+		end -= 4
+	}
+	pos := 4
+	var body Doc
+	for pos < end {
+		kind := rest[pos]
+		pos++
+		switch kind {
+		case 0:
+			doc, n, docErr := readDoc(rest[pos:end])
+			if docErr != nil {
+				return hdr, nil, docErr
+			}
+			body = doc
+			pos += n
+		case 1:
+			if end-pos < 4 {
+				return hdr, nil, errShort
+			}
+			pos += int(binary.LittleEndian.Uint32(rest[pos:]))
+		default:
+			return hdr, nil, fmt.Errorf("mongo wire: unknown section kind %d", kind)
+		}
+	}
+	if body == nil {
+		return hdr, nil, errors.New("mongo wire: message has no body section")
+	}
+	return hdr, body, nil
+}
+
+// RunCommand runs cmd against db and returns the reply, a reply with ok: 0 is returned as a *CommandError
+func (c *Conn) RunCommand(db string, cmd Doc) (Doc, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	c.requestID++
+	withDB := append(append(Doc{}, cmd...), Elem{"$db", db})
+	writeErr := writeMessage(c.conn, c.requestID, 0, withDB)
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	hdr, reply, readErr := readMessage(c.conn)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if hdr.ResponseTo != c.requestID {
+		return nil, fmt.Errorf("mongo wire: reply to request %d, expected %d", hdr.ResponseTo, c.requestID)
+	}
+	if !reply.Bool("ok") {
+		return reply, &CommandError{
+			Code:     reply.Int("code"),
+			CodeName: reply.String("codeName"),
+			Message:  reply.String("errmsg"),
+		}
+	}
+	return reply, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// CommandHandler answers a single command for a FakeServer, it is given the whole command document
+type CommandHandler func(cmd Doc) Doc
+
+// FakeServer is a tiny in-process mongod responder for tests, it answers hello, isMaster and ping
+// from its fields and any other command registered with Handle
+type FakeServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	handlers map[string]CommandHandler
+	received []Doc
+	// Role is one of "primary", "secondary", "arbiter" or "standalone"
+	Role       string
+	ReplicaSet string
+	// Delay is added before every reply
+	Delay time.Duration
+}
+
+// NewFakeServer starts a FakeServer on a random local port
+func NewFakeServer() (*FakeServer, error) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		return nil, listenErr
+	}
+	f := &FakeServer{
+		listener: listener,
+		handlers: make(map[string]CommandHandler),
+		Role:     "standalone",
+	}
+	go f.serve()
+	return f, nil
+}
+
+// Addr is the host:port the FakeServer listens on
+func (f *FakeServer) Addr() string {
+	return f.listener.Addr().String()
+}
+
+// Close stops the FakeServer, open connections are closed when they next read
+func (f *FakeServer) Close() error {
+	return f.listener.Close()
+}
+
+// Handle registers handler for the command name, replacing the built in ones for hello, isMaster and ping
+func (f *FakeServer) Handle(name string, handler CommandHandler) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.handlers[name] = handler
+}
+
+// SetRole changes the role reported by hello and isMaster
+func (f *FakeServer) SetRole(role string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.Role = role
+}
+
+// Received returns every command the FakeServer was sent
+func (f *FakeServer) Received() []Doc {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Doc{}, f.received...)
+}
+
+func (f *FakeServer) serve() {
+	for {
+		conn, acceptErr := f.listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		go f.serveConn(conn)
+	}
+}
+
+func (f *FakeServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		hdr, cmd, readErr := readMessage(conn)
+		if readErr != nil {
+			return
+		}
+		reply := f.reply(cmd)
+		writeErr := writeMessage(conn, hdr.RequestID+1, hdr.RequestID, reply)
+		if writeErr != nil {
+			return
+		}
+	}
+}
+
+func (f *FakeServer) reply(cmd Doc) Doc {
+	f.mutex.Lock()
+	f.received = append(f.received, cmd)
+	delay := f.Delay
+	var handler CommandHandler
+	name := ""
+	if len(cmd) > 0 {
+		name = cmd[0].Key
+		handler = f.handlers[name]
+	}
+	role, setName := f.Role, f.ReplicaSet
+	f.mutex.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if handler != nil {
+		return handler(cmd)
+	}
+	switch name {
+	case "hello", "isMaster", "ismaster":
+		reply := Doc{
+			{"isWritablePrimary", role == "primary" || role == "standalone"},
+			{"ismaster", role == "primary" || role == "standalone"},
+			{"secondary", role == "secondary"},
+			{"maxWireVersion", int32(17)},
+		}
+		if role == "arbiter" {
+			reply = append(reply, Elem{"arbiterOnly", true})
+		}
+		if setName != "" {
+			reply = append(reply, Elem{"setName", setName})
+		}
+		return append(reply, Elem{"ok", float64(1)})
+	case "ping":
+		return Doc{{"ok", float64(1)}}
+	}
+	return Doc{
+		{"ok", float64(0)},
+		{"errmsg", "no such command: '" + name + "'"},
+		{"code", int32(59)},
+		{"codeName", "CommandNotFound"},
+	}
+}
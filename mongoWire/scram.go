@@ -0,0 +1,225 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scramMechanism is the only SASL mechanism kubongo speaks, mongod 4.0 and later enable it by default
+const scramMechanism = "SCRAM-SHA-256"
+
+// minIterations is the lowest iteration count RFC 7677 allows a server to ask for
+const minIterations = 4096
+
+// Credential is a user that commands authenticate as with SCRAM-SHA-256
+type Credential struct {
+	Username string
+	Password string
+	// Source is the database the user is defined in, empty means admin
+	Source string
+}
+
+// newNonce returns the client nonce of a SCRAM conversation, tests replace it to replay known conversations
+var newNonce = func() (string, error) {
+	raw := make([]byte, 24)
+	_, randErr := rand.Read(raw)
+	if randErr != nil {
+		return "", randErr
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// scramClient is the client side of a single SCRAM-SHA-256 conversation as described in RFC 5802 and RFC 7677
+type scramClient struct {
+	username  string
+	password  string
+	nonce     string
+	firstBare string
+	authMsg   string
+	salted    []byte
+}
+
+// first returns the client-first message
+func (s *scramClient) first() string {
+	escaper := strings.NewReplacer("=", "=3D", ",", "=2C")
+	s.firstBare = "n=" + escaper.Replace(s.username) + ",r=" + s.nonce
+	return "n,," + s.firstBare
+}
+
+// parseAttributes splits a SCRAM message into its attributes, an e= attribute is returned as an error
+func parseAttributes(msg string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(msg, ",") {
+		if len(field) < 2 || field[1] != '=' {
+			return nil, fmt.Errorf("scram: malformed message %q", msg)
+		}
+		attrs[field[:1]] = field[2:]
+	}
+	if serverErr, failed := attrs["e"]; failed {
+		return nil, fmt.Errorf("scram: server rejected the authentication: %s", serverErr)
+	}
+	return attrs, nil
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+// final answers the server-first message with the client-final message carrying the proof
+func (s *scramClient) final(serverFirst string) (string, error) {
+	attrs, parseErr := parseAttributes(serverFirst)
+	if parseErr != nil {
+		return "", parseErr
+	}
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return "", errors.New("scram: the server's nonce does not extend ours")
+	}
+	salt, saltErr := base64.StdEncoding.DecodeString(attrs["s"])
+	if saltErr != nil {
+		return "", fmt.Errorf("scram: invalid salt: %v", saltErr)
+	}
+	iterations, iterErr := strconv.Atoi(attrs["i"])
+	if iterErr != nil || iterations < minIterations {
+		return "", fmt.Errorf("scram: invalid iteration count %q", attrs["i"])
+	}
+	salted, keyErr := pbkdf2.Key(sha256.New, s.password, salt, iterations, sha256.Size)
+	if keyErr != nil {
+		return "", keyErr
+	}
+	s.salted = salted
+	withoutProof := "c=biws,r=" + nonce
+	s.authMsg = s.firstBare + "," + serverFirst + "," + withoutProof
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], s.authMsg)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verify checks the server-final message proves the server knows the password too
+func (s *scramClient) verify(serverFinal string) error {
+	attrs, parseErr := parseAttributes(serverFinal)
+	if parseErr != nil {
+		return parseErr
+	}
+	signature, sigErr := base64.StdEncoding.DecodeString(attrs["v"])
+	if sigErr != nil {
+		return fmt.Errorf("scram: invalid server signature: %v", sigErr)
+	}
+	expected := hmacSHA256(hmacSHA256(s.salted, "Server Key"), s.authMsg)
+	if !hmac.Equal(signature, expected) {
+		return errors.New("scram: the server's signature does not match, it does not know the password")
+	}
+	return nil
+}
+
+// isASCII reports whether SASLprep leaves s unchanged, which holds for printable ASCII
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticate logs the connection in as cred with SCRAM-SHA-256, only printable ASCII usernames and passwords are
+// supported because they are the ones SASLprep leaves alone
+func (c *Conn) Authenticate(cred Credential) error {
+	if !isASCII(cred.Username) || !isASCII(cred.Password) {
+		return errors.New("scram: only printable ASCII usernames and passwords are supported")
+	}
+	source := cred.Source
+	if source == "" {
+		source = "admin"
+	}
+	nonce, nonceErr := newNonce()
+	if nonceErr != nil {
+		return nonceErr
+	}
+	client := &scramClient{username: cred.Username, password: cred.Password, nonce: nonce}
+	reply, startErr := c.RunCommand(source, Doc{
+		{"saslStart", int32(1)},
+		{"mechanism", scramMechanism},
+		{"payload", []byte(client.first())},
+		{"options", Doc{{"skipEmptyExchange", true}}},
+	})
+	if startErr != nil {
+		return startErr
+	}
+	conversationID := reply.Get("conversationId")
+	serverFirst, _ := reply.Get("payload").([]byte)
+	clientFinal, finalErr := client.final(string(serverFirst))
+	if finalErr != nil {
+		return finalErr
+	}
+	reply, continueErr := c.RunCommand(source, Doc{
+		{"saslContinue", int32(1)},
+		{"conversationId", conversationID},
+		{"payload", []byte(clientFinal)},
+	})
+	if continueErr != nil {
+		return continueErr
+	}
+	serverFinal, _ := reply.Get("payload").([]byte)
+	verifyErr := client.verify(string(serverFinal))
+	if verifyErr != nil {
+		return verifyErr
+	}
+	if reply.Bool("done") {
+		return nil
+	}
+	// servers that ignore skipEmptyExchange want one more empty round
+	reply, continueErr = c.RunCommand(source, Doc{
+		{"saslContinue", int32(1)},
+		{"conversationId", conversationID},
+		{"payload", []byte{}},
+	})
+	if continueErr != nil {
+		return continueErr
+	}
+	if !reply.Bool("done") {
+		return errors.New("scram: the server did not finish the conversation")
+	}
+	return nil
+}
+
+// DialAuth opens a connection like Dial and authenticates it as cred, nil connects without authenticating
+func DialAuth(address string, timeout time.Duration, cred *Credential) (*Conn, error) {
+	conn, dialErr := Dial(address, timeout)
+	if dialErr != nil || cred == nil {
+		return conn, dialErr
+	}
+	authErr := conn.Authenticate(*cred)
+	if authErr != nil {
+		conn.Close()
+		return nil, authErr
+	}
+	return conn, nil
+}
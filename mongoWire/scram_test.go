@@ -0,0 +1,83 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongoWire
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SCRAM-SHA-256 conversation from RFC 7677 section 3
+const (
+	rfcNonce       = "rOprNGfwEbeRWgbNEkqO"
+	rfcServerFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	rfcClientFinal = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfcServerFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+// newSCRAMServer answers saslStart and saslContinue with the RFC 7677 conversation, ending it with serverFinal
+func newSCRAMServer(t *testing.T, serverFinal string) (*FakeServer, *string) {
+	server, serverErr := NewFakeServer()
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	var clientFinal string
+	server.Handle("saslStart", func(cmd Doc) Doc {
+		if cmd.String("mechanism") != scramMechanism || string(cmd.Get("payload").([]byte)) != "n,,n=user,r="+rfcNonce {
+			return Doc{{"ok", float64(0)}, {"errmsg", "unexpected saslStart"}, {"code", int32(18)}}
+		}
+		return Doc{{"conversationId", int32(1)}, {"done", false}, {"payload", []byte(rfcServerFirst)}, {"ok", float64(1)}}
+	})
+	server.Handle("saslContinue", func(cmd Doc) Doc {
+		clientFinal = string(cmd.Get("payload").([]byte))
+		return Doc{{"conversationId", int32(1)}, {"done", true}, {"payload", []byte(serverFinal)}, {"ok", float64(1)}}
+	})
+	return server, &clientFinal
+}
+
+func TestAuthenticate(t *testing.T) {
+	defer func(original func() (string, error)) { newNonce = original }(newNonce)
+	newNonce = func() (string, error) { return rfcNonce, nil }
+	server, clientFinal := newSCRAMServer(t, rfcServerFinal)
+	defer server.Close()
+
+	conn, dialErr := DialAuth(server.Addr(), time.Second, &Credential{Username: "user", Password: "pencil"})
+	if dialErr != nil {
+		t.Fatal(dialErr)
+	}
+	conn.Close()
+	if *clientFinal != rfcClientFinal {
+		t.Errorf("expected the proof from RFC 7677, got %s", *clientFinal)
+	}
+	received := server.Received()
+	if len(received) != 2 || received[0].String("$db") != "admin" {
+		t.Errorf("expected saslStart and saslContinue against admin, got %v", received)
+	}
+}
+
+func TestAuthenticateRejectsAServerThatDoesNotKnowThePassword(t *testing.T) {
+	defer func(original func() (string, error)) { newNonce = original }(newNonce)
+	newNonce = func() (string, error) { return rfcNonce, nil }
+	server, _ := newSCRAMServer(t, "v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	defer server.Close()
+
+	_, dialErr := DialAuth(server.Addr(), time.Second, &Credential{Username: "user", Password: "pencil"})
+	if dialErr == nil || !strings.Contains(dialErr.Error(), "signature") {
+		t.Errorf("expected a mismatched server signature to fail, got %v", dialErr)
+	}
+	if _, asciiErr := DialAuth(server.Addr(), time.Second, &Credential{Username: "user", Password: "pässword"}); asciiErr == nil {
+		t.Error("expected a password SASLprep would change to be refused")
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SinkConfig configures a single sink, Type is webhook, slack or smtp and only that type's fields are used
+type SinkConfig struct {
+	Type string `yaml:"type"`
+	// Events are the event types sent to the sink, empty sends every event
+	Events []string `yaml:"events,omitempty"`
+	// URL is the webhook or Slack incoming webhook
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Template is the webhook's body, see ParseTemplate
+	Template string `yaml:"template,omitempty"`
+	Channel  string `yaml:"channel,omitempty"`
+	// Username is Slack's bot name or the SMTP user
+	Username string   `yaml:"username,omitempty"`
+	Addr     string   `yaml:"addr,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	// PasswordEnv names the environment variable holding the SMTP password so it stays out of the file
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+}
+
+// Config is a notification config file
+//
+//	dedupWindow: 5m
+//	retries: 3
+//	timeout: 30s
+//	sinks:
+//	- type: slack
+//	  url: https://hooks.slack.com/services/...
+//	  events: ["instance.down", "failover.failed"]
+//	- type: smtp
+//	  addr: smtp.example.com:587
+//	  from: kubongo@example.com
+//	  to: ["oncall@example.com"]
+//	  username: kubongo
+//	  passwordEnv: SMTP_PASSWORD
+type Config struct {
+	DedupWindow  time.Duration `yaml:"dedupWindow,omitempty"`
+	Retries      *int          `yaml:"retries,omitempty"`
+	RetryBackoff time.Duration `yaml:"retryBackoff,omitempty"`
+	// Timeout bounds every send to a sink
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Sinks   []SinkConfig  `yaml:"sinks"`
+}
+
+var eventTypes = map[string]bool{
+	EventInstanceDown:      true,
+	EventInstanceRecovered: true,
+	EventFailoverStarted:   true,
+	EventFailoverCompleted: true,
+	EventFailoverFailed:    true,
+	EventProviderError:     true,
+}
+
+// sink builds the Sink c configures
+func (c SinkConfig) sink() (Sink, error) {
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("a webhook needs a url")
+		}
+		webhook := &Webhook{URL: c.URL, Headers: c.Headers}
+		if c.Template != "" {
+			tmpl, tmplErr := ParseTemplate(c.Template)
+			if tmplErr != nil {
+				return nil, tmplErr
+			}
+			webhook.Template = tmpl
+		}
+		return webhook, nil
+	case "slack":
+		if c.URL == "" {
+			return nil, fmt.Errorf("slack needs an incoming webhook url")
+		}
+		return &Slack{URL: c.URL, Channel: c.Channel, Username: c.Username}, nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("smtp needs an addr, from and to")
+		}
+		return &SMTP{Addr: c.Addr, From: c.From, To: c.To, Username: c.Username, Password: os.Getenv(c.PasswordEnv)}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", c.Type)
+}
+
+// New creates a Notifier sending to every sink of c
+func (c *Config) New() (*Notifier, error) {
+	n := NewNotifier()
+	if c.DedupWindow > 0 {
+		n.DedupWindow = c.DedupWindow
+	}
+	if c.Retries != nil {
+		n.Retries = *c.Retries
+	}
+	if c.RetryBackoff > 0 {
+		n.RetryBackoff = c.RetryBackoff
+	}
+	if c.Timeout > 0 {
+		n.Timeout = c.Timeout
+	}
+	for i, sinkConf := range c.Sinks {
+		for _, eventType := range sinkConf.Events {
+			if !eventTypes[eventType] {
+				n.Close()
+				return nil, fmt.Errorf("sink %d has unknown event %q", i, eventType)
+			}
+		}
+		sink, sinkErr := sinkConf.sink()
+		if sinkErr != nil {
+			n.Close()
+			return nil, fmt.Errorf("sink %d: %v", i, sinkErr)
+		}
+		n.Add(sink, sinkConf.Events...)
+	}
+	return n, nil
+}
+
+// LoadConfig reads a YAML or JSON Config file and creates its Notifier
+func LoadConfig(path string) (*Notifier, error) {
+	data, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	conf := &Config{}
+	yamlErr := yaml.Unmarshal(data, conf)
+	if yamlErr != nil {
+		return nil, yamlErr
+	}
+	return conf.New()
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify sends kubongo's events, such as an instance going down or a failover, to webhooks, Slack and email
+package notify
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Types of Event
+const (
+	EventInstanceDown      = "instance.down"
+	EventInstanceRecovered = "instance.recovered"
+	EventFailoverStarted   = "failover.started"
+	EventFailoverCompleted = "failover.completed"
+	EventFailoverFailed    = "failover.failed"
+	EventProviderError     = "provider.error"
+)
+
+// Event is something kubongo notifies about
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Subject is what the event is about, such as an instance's name or a primary's address
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+	// Details are extra facts about the event, such as the zone or the new primary
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// key is what repeats of the same event have in common
+func (e Event) key() string {
+	return e.Type + "/" + e.Subject
+}
+
+// Sink delivers events somewhere
+type Sink interface {
+	Name() string
+	Send(event Event) error
+}
+
+// Defaults of a Notifier
+const (
+	DefaultDedupWindow  = 5 * time.Minute
+	DefaultRetries      = 3
+	DefaultRetryBackoff = time.Second
+	DefaultTimeout      = 30 * time.Second
+	// queueSize is how many events may wait for delivery to a sink before new ones are dropped
+	queueSize = 256
+)
+
+// route sends the events a sink subscribed to, every event if it subscribed to none, from its own queue
+// so that a slow sink only holds up itself
+type route struct {
+	sink   Sink
+	events map[string]bool
+	queue  chan Event
+}
+
+func (r route) wants(event Event) bool {
+	return len(r.events) == 0 || r.events[event.Type]
+}
+
+// Notifier delivers events to each of its sinks in the background, repeats of an event within DedupWindow are dropped,
+// a send taking longer than Timeout counts as failed and failed sends are retried with a doubling backoff
+type Notifier struct {
+	DedupWindow  time.Duration
+	Retries      int
+	RetryBackoff time.Duration
+	Timeout      time.Duration
+	mutex        sync.Mutex
+	routes       []*route
+	lastSent     map[string]time.Time
+	closed       bool
+	workers      sync.WaitGroup
+}
+
+// NewNotifier creates a Notifier with no sinks
+func NewNotifier() *Notifier {
+	return &Notifier{
+		DedupWindow:  DefaultDedupWindow,
+		Retries:      DefaultRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		Timeout:      DefaultTimeout,
+		lastSent:     make(map[string]time.Time),
+	}
+}
+
+// Add sends the events of the given types to sink, every event if no types are given, and starts delivering to it
+func (n *Notifier) Add(sink Sink, types ...string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	r := &route{sink: sink, events: make(map[string]bool), queue: make(chan Event, queueSize)}
+	for _, eventType := range types {
+		r.events[eventType] = true
+	}
+	n.routes = append(n.routes, r)
+	n.workers.Add(1)
+	go n.deliver(r)
+}
+
+// Notify queues event for delivery without blocking, it is safe to call on a nil Notifier
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		return
+	}
+	last, seen := n.lastSent[event.key()]
+	if seen && event.Time.Sub(last) < n.DedupWindow {
+		return
+	}
+	n.lastSent[event.key()] = event.Time
+	for _, r := range n.routes {
+		if !r.wants(event) {
+			continue
+		}
+		select {
+		case r.queue <- event:
+		default:
+			log.Println("notify:141 dropped", event.Type, "for", event.Subject, "the queue of", r.sink.Name(), "is full")
+		}
+	}
+}
+
+// Close stops delivering once the queued events are sent
+func (n *Notifier) Close() {
+	n.mutex.Lock()
+	if !n.closed {
+		n.closed = true
+		for _, r := range n.routes {
+			close(r.queue)
+		}
+	}
+	n.mutex.Unlock()
+	n.workers.Wait()
+}
+
+// deliver sends the events queued for r one at a time until Close
+func (n *Notifier) deliver(r *route) {
+	defer n.workers.Done()
+	for event := range r.queue {
+		n.send(r.sink, event)
+	}
+}
+
+// sendWithin sends event to sink, giving up after timeout, a sink that hangs keeps its goroutine until it returns
+func sendWithin(sink Sink, event Event, timeout time.Duration) error {
+	if timeout <= 0 {
+		return sink.Send(event)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- sink.Send(event)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case sendErr := <-result:
+		return sendErr
+	case <-timer.C:
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// send tries sink until it succeeds or the retries run out
+func (n *Notifier) send(sink Sink, event Event) {
+	backoff := n.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		sendErr := sendWithin(sink, event, n.Timeout)
+		if sendErr == nil {
+			return
+		}
+		if attempt >= n.Retries {
+			log.Println("notify:195 gave up sending", event.Type, "for", event.Subject, "to", sink.Name(), sendErr)
+			return
+		}
+		log.Println("notify:198 could not send", event.Type, "to", sink.Name(), "retrying in", backoff, sendErr)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a Sink that fails its first failures sends
+type recorder struct {
+	mutex    sync.Mutex
+	failures int
+	attempts int
+	sent     chan Event
+}
+
+func newRecorder(failures int) *recorder {
+	return &recorder{failures: failures, sent: make(chan Event, 10)}
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) Send(event Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		return errors.New("unavailable")
+	}
+	r.sent <- event
+	return nil
+}
+
+func receive(t *testing.T, sent chan Event) Event {
+	select {
+	case event := <-sent:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestDedupAndRetry(t *testing.T) {
+	n := NewNotifier()
+	n.RetryBackoff = time.Millisecond
+	all := newRecorder(2)
+	downs := newRecorder(0)
+	n.Add(all)
+	n.Add(downs, EventInstanceDown)
+
+	n.Notify(Event{Type: EventInstanceDown, Subject: "db-0"})
+	n.Notify(Event{Type: EventInstanceDown, Subject: "db-0"})
+	n.Notify(Event{Type: EventInstanceRecovered, Subject: "db-0"})
+	n.Close()
+
+	if event := receive(t, all.sent); event.Type != EventInstanceDown || event.Time.IsZero() {
+		t.Errorf("expected db-0 down to be retried until it was sent, got %+v", event)
+	}
+	if event := receive(t, all.sent); event.Type != EventInstanceRecovered {
+		t.Errorf("expected the repeated down to be dropped, got %+v", event)
+	}
+	if event := receive(t, downs.sent); event.Type != EventInstanceDown || len(downs.sent) != 0 {
+		t.Errorf("expected the down only sink to get only the down, got %+v and %d more", event, len(downs.sent))
+	}
+	if all.attempts != 4 {
+		t.Errorf("expected 2 failed and 2 sent attempts, got %d", all.attempts)
+	}
+}
+
+// hanging is a Sink whose sends block until release is closed
+type hanging struct {
+	release chan struct{}
+}
+
+func (h *hanging) Name() string {
+	return "hanging"
+}
+
+func (h *hanging) Send(event Event) error {
+	<-h.release
+	return nil
+}
+
+func TestASlowSinkDoesNotHoldUpTheOthers(t *testing.T) {
+	n := NewNotifier()
+	n.Timeout = 0
+	stuck := &hanging{release: make(chan struct{})}
+	fast := newRecorder(0)
+	n.Add(stuck)
+	n.Add(fast)
+
+	n.Notify(Event{Type: EventInstanceDown, Subject: "db-0"})
+	n.Notify(Event{Type: EventInstanceDown, Subject: "db-1"})
+	if event := receive(t, fast.sent); event.Subject != "db-0" {
+		t.Errorf("expected db-0 to reach the fast sink while the other one hangs, got %+v", event)
+	}
+	if event := receive(t, fast.sent); event.Subject != "db-1" {
+		t.Errorf("expected db-1 to reach the fast sink while the other one hangs, got %+v", event)
+	}
+	close(stuck.release)
+	n.Close()
+}
+
+func TestSendsTimeOut(t *testing.T) {
+	n := NewNotifier()
+	n.Retries = 1
+	n.RetryBackoff = time.Millisecond
+	n.Timeout = 10 * time.Millisecond
+	stuck := &hanging{release: make(chan struct{})}
+	defer close(stuck.release)
+	n.Add(stuck)
+
+	n.Notify(Event{Type: EventInstanceDown, Subject: "db-0"})
+	closed := make(chan struct{})
+	go func() {
+		n.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("expected the hanging sends to time out so Close returns")
+	}
+}
+
+func TestWebhookAndSlack(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 10)
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/flaky" && !failed {
+			failed = true
+			http.Error(res, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body := map[string]interface{}{"path": req.URL.Path, "token": req.Header.Get("X-Token")}
+		json.NewDecoder(req.Body).Decode(&body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	tmpl, tmplErr := ParseTemplate(`{"alert": {{json .Type}}, "text": {{json (summary .)}}}`)
+	if tmplErr != nil {
+		t.Fatal(tmplErr)
+	}
+	event := Event{Type: EventFailoverFailed, Subject: "10.0.0.1:27017", Message: `no "healthy" secondary`, Details: map[string]string{"replicaSet": "rs0"}}
+	sendErr := (&Webhook{URL: server.URL + "/templated", Template: tmpl, Headers: map[string]string{"X-Token": "secret"}}).Send(event)
+	if sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	body := <-bodies
+	if body["alert"] != EventFailoverFailed || body["token"] != "secret" || !strings.Contains(body["text"].(string), `no "healthy" secondary`) {
+		t.Errorf("expected the templated body, got %v", body)
+	}
+
+	if sendErr = (&Webhook{URL: server.URL + "/flaky"}).Send(event); sendErr == nil {
+		t.Error("expected a 503 to fail the send so it is retried")
+	}
+	if sendErr = (&Webhook{URL: server.URL + "/flaky"}).Send(event); sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	if body = <-bodies; body["type"] != EventFailoverFailed || body["subject"] != "10.0.0.1:27017" {
+		t.Errorf("expected the event as JSON without a template, got %v", body)
+	}
+
+	sendErr = (&Slack{URL: server.URL + "/slack", Channel: "#mongo"}).Send(event)
+	if sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	body = <-bodies
+	if body["channel"] != "#mongo" || !strings.HasPrefix(body["text"].(string), "[kubongo] failover.failed 10.0.0.1:27017") || !strings.Contains(body["text"].(string), "replicaSet: rs0") {
+		t.Errorf("expected a Slack message with the details, got %v", body)
+	}
+}
+
+// serveSMTP is just enough of an SMTP server to accept mail from net/smtp, every message is sent to messages
+func serveSMTP(t *testing.T, messages chan string) net.Listener {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatal(listenErr)
+	}
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+				reply("220 localhost ESMTP")
+				for {
+					line, readErr := reader.ReadString('\n')
+					if readErr != nil {
+						return
+					}
+					switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+					case "EHLO", "HELO":
+						reply("250-localhost")
+						reply("250 AUTH PLAIN")
+					case "AUTH":
+						reply("235 authenticated")
+					case "DATA":
+						reply("354 go ahead")
+						data := ""
+						for {
+							dataLine, dataErr := reader.ReadString('\n')
+							if dataErr != nil || dataLine == ".\r\n" {
+								break
+							}
+							data += dataLine
+						}
+						messages <- data
+						reply("250 queued")
+					case "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func TestSMTP(t *testing.T) {
+	messages := make(chan string, 1)
+	listener := serveSMTP(t, messages)
+	defer listener.Close()
+	sink := &SMTP{Addr: listener.Addr().String(), From: "kubongo@example.com", To: []string{"oncall@example.com"}, Username: "kubongo", Password: "secret"}
+	sendErr := sink.Send(Event{Type: EventInstanceDown, Time: time.Now(), Subject: "db-0", Message: "db-0 failed\nits health checks", Details: map[string]string{"address": "10.0.0.1:27017"}})
+	if sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	message := <-messages
+	if !strings.Contains(message, "Subject: [kubongo] instance.down db-0: db-0 failed its health checks\r\n") || !strings.Contains(message, "address: 10.0.0.1:27017") {
+		t.Errorf("expected the event with a single line subject, got\n%s", message)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubongo-notify")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify.yaml")
+	ioutil.WriteFile(path, []byte("dedupWindow: 1m\nretries: 0\ntimeout: 5s\nsinks:\n- type: slack\n  url: http://127.0.0.1/slack\n  events: [\"instance.down\"]\n- type: webhook\n  url: http://127.0.0.1/hook\n  template: '{\"text\": {{json .Message}}}'\n"), 0600)
+	n, loadErr := LoadConfig(path)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	defer n.Close()
+	if n.DedupWindow != time.Minute || n.Retries != 0 || n.Timeout != 5*time.Second || len(n.routes) != 2 || !n.routes[0].events[EventInstanceDown] {
+		t.Errorf("expected the config's settings and sinks, got %+v", n)
+	}
+
+	ioutil.WriteFile(path, []byte("sinks:\n- type: slack\n  url: http://127.0.0.1/slack\n  events: [\"instance.gone\"]\n"), 0600)
+	if _, loadErr = LoadConfig(path); loadErr == nil {
+		t.Error("expected an unknown event to be refused")
+	}
+}
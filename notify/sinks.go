@@ -0,0 +1,192 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultTimeout bounds a single delivery to a webhook
+const defaultTimeout = 10 * time.Second
+
+// Summary is a single line describing event, used as Slack's text and the email subject
+func Summary(event Event) string {
+	return fmt.Sprintf("[kubongo] %s %s: %s", event.Type, event.Subject, event.Message)
+}
+
+// templateFuncs are available in a Webhook's Template, json encodes any value so it can be embedded in a JSON body
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, jErr := json.Marshal(value)
+		return string(data), jErr
+	},
+	"summary": Summary,
+}
+
+// ParseTemplate parses a Webhook body template, the Event is its data
+//
+//	{"alert": {{json .Type}}, "instance": {{json .Subject}}, "text": {{json (summary .)}}}
+func ParseTemplate(body string) (*template.Template, error) {
+	return template.New("webhook").Funcs(templateFuncs).Parse(body)
+}
+
+// post sends body to url and fails on any status but 2xx
+func post(client *http.Client, url string, headers map[string]string, body []byte) error {
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	req, reqErr := http.NewRequest("POST", url, bytes.NewReader(body))
+	if reqErr != nil {
+		return reqErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res, resErr := client.Do(req)
+	if resErr != nil {
+		return resErr
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("POST %s received a status code of %d: %s", url, res.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Webhook posts every event as JSON to URL, the body is the Event itself unless a Template is set
+type Webhook struct {
+	URL      string
+	Headers  map[string]string
+	Template *template.Template
+	Client   *http.Client
+}
+
+// Name is the webhook's URL
+func (w *Webhook) Name() string {
+	return "webhook " + w.URL
+}
+
+// Send posts event to the webhook
+func (w *Webhook) Send(event Event) error {
+	var body []byte
+	if w.Template == nil {
+		var jErr error
+		body, jErr = json.Marshal(&event)
+		if jErr != nil {
+			return jErr
+		}
+	} else {
+		buf := &bytes.Buffer{}
+		execErr := w.Template.Execute(buf, &event)
+		if execErr != nil {
+			return execErr
+		}
+		body = buf.Bytes()
+	}
+	return post(w.Client, w.URL, w.Headers, body)
+}
+
+// Slack posts every event to a Slack compatible incoming webhook
+type Slack struct {
+	URL string
+	// Channel and Username override the incoming webhook's own, empty keeps them
+	Channel  string
+	Username string
+	Client   *http.Client
+}
+
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Name is the Slack webhook's URL
+func (s *Slack) Name() string {
+	return "slack " + s.URL
+}
+
+// Send posts the event's Summary and details to Slack
+func (s *Slack) Send(event Event) error {
+	text := Summary(event)
+	for _, name := range sortedKeys(event.Details) {
+		text += fmt.Sprintf("\n• %s: %s", name, event.Details[name])
+	}
+	body, jErr := json.Marshal(&slackMessage{Text: text, Channel: s.Channel, Username: s.Username})
+	if jErr != nil {
+		return jErr
+	}
+	return post(s.Client, s.URL, nil, body)
+}
+
+// SMTP emails every event to To through the server at Addr
+type SMTP struct {
+	// Addr is the server's host:port
+	Addr string
+	From string
+	To   []string
+	// Username and Password authenticate with PLAIN, empty sends without authenticating
+	Username string
+	Password string
+}
+
+// Name is the SMTP server's address
+func (s *SMTP) Name() string {
+	return "smtp " + s.Addr
+}
+
+// Send emails the event's Summary as the subject with its details as the body
+func (s *SMTP) Send(event Event) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := strings.Split(s.Addr, ":")[0]
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", headerEscaper.Replace(Summary(event)))
+	fmt.Fprintf(msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(msg, "%s\r\n\r\n", event.Message)
+	fmt.Fprintf(msg, "type: %s\r\nsubject: %s\r\ntime: %s\r\n", event.Type, event.Subject, event.Time.Format(time.RFC3339))
+	for _, name := range sortedKeys(event.Details) {
+		fmt.Fprintf(msg, "%s: %s\r\n", name, event.Details[name])
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, s.To, msg.Bytes())
+}
+
+// headerEscaper keeps a message with newlines, such as an error, from adding headers
+var headerEscaper = strings.NewReplacer("\r", " ", "\n", " ")
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
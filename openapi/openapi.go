@@ -0,0 +1,309 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/cpg1111/kubongo/audit"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+)
+
+// Path is where the document is served
+const Path = "/openapi.json"
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+// Info describes the api
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lower case http methods to what they do on a path
+type PathItem map[string]*Operation
+
+// Operation is a single method on a path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation takes
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response is one possible answer of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Components holds the schemas operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way to authenticate
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+func stringParam(name, in, description string) Parameter {
+	return Parameter{Name: name, In: in, Description: description, Required: in == "path", Schema: &Schema{Type: "string"}}
+}
+
+// builder adds operations to a document, every one of them can answer with an ErrorRes
+type builder struct {
+	doc      *Document
+	gen      *Generator
+	errorRes *Schema
+}
+
+func (b *builder) add(path, method string, op *Operation) {
+	if b.doc.Paths[path] == nil {
+		b.doc.Paths[path] = PathItem{}
+	}
+	op.Responses["default"] = &Response{Description: "an error", Content: jsonContent(b.errorRes)}
+	b.doc.Paths[path][method] = op
+}
+
+// accepted is the 202 answer of a change that runs as an operation
+func (b *builder) accepted() *Response {
+	return &Response{
+		Description: "the change was started as an operation",
+		Headers:     map[string]Header{"Location": {Description: "where the operation can be followed", Schema: &Schema{Type: "string"}}},
+		Content:     jsonContent(b.gen.Of(mongo.Operation{})),
+	}
+}
+
+// Kubongo describes every path of Kubongo's api server, the schemas are generated from the types the handlers encode and decode
+func Kubongo() *Document {
+	gen := NewGenerator()
+	b := &builder{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info: Info{
+				Title:       "Kubongo",
+				Description: "Manages mongo instances on cloud platforms and publishes them to Kubernetes. /instances is an alias of /v1/instances.",
+				Version:     "v1",
+			},
+			Paths:    map[string]PathItem{},
+			Security: []map[string][]string{{"bearer": {}}, {}},
+		},
+		gen:      gen,
+		errorRes: gen.Of(mongo.ErrorRes{}),
+	}
+	zoneName := []Parameter{stringParam("zone", "path", ""), stringParam("name", "path", "")}
+	instance := &Response{Description: "the instance", Content: jsonContent(gen.Of(mongo.InstanceRes{}))}
+
+	b.add("/v1/instances", "get", &Operation{
+		OperationID: "listInstances",
+		Summary:     "List every registered instance, or watch them with watch=true",
+		Tags:        []string{"instances"},
+		Parameters: []Parameter{
+			{Name: "watch", In: "query", Description: "stream WatchEvents as JSON lines, or as Server-Sent Events if text/event-stream is accepted", Schema: &Schema{Type: "boolean"}},
+			{Name: "resourceVersion", In: "query", Description: "replay registry changes after this version first", Schema: &Schema{Type: "integer", Format: "int64"}},
+		},
+		Responses: map[string]*Response{"200": {
+			Description: "the instances, or a stream of WatchEvents when watching",
+			Content: map[string]MediaType{
+				"application/json":  {Schema: gen.Of(mongo.InstanceList{})},
+				"text/event-stream": {Schema: gen.Of(mongo.WatchEvent{})},
+			},
+		}},
+	})
+	b.add("/v1/instances", "post", &Operation{
+		OperationID: "createInstance",
+		Summary:     "Create or register an instance depending on kind, or a replica set if members is more than 1",
+		Tags:        []string{"instances"},
+		RequestBody: jsonBody(gen.Of(mongo.InstanceTemplate{})),
+		Responses:   map[string]*Response{"202": b.accepted()},
+	})
+	b.add("/v1/instances", "delete", &Operation{
+		OperationID: "deleteInstances",
+		Summary:     "Delete the instance named in the body",
+		Tags:        []string{"instances"},
+		RequestBody: jsonBody(gen.Of(mongo.DeleteData{})),
+		Responses:   map[string]*Response{"202": b.accepted()},
+	})
+	b.add("/v1/instances/{zone}/{name}", "get", &Operation{
+		OperationID: "getInstance",
+		Summary:     "Get an instance with its labels and health",
+		Tags:        []string{"instances"},
+		Parameters:  zoneName,
+		Responses:   map[string]*Response{"200": instance},
+	})
+	b.add("/v1/instances/{zone}/{name}", "put", &Operation{
+		OperationID: "putInstance",
+		Summary:     "Create the instance, or replace the labels and member options of an existing one",
+		Tags:        []string{"instances"},
+		Parameters:  zoneName,
+		RequestBody: jsonBody(gen.Of(mongo.InstanceTemplate{})),
+		Responses:   map[string]*Response{"200": instance, "202": b.accepted()},
+	})
+	b.add("/v1/instances/{zone}/{name}", "patch", &Operation{
+		OperationID: "patchInstance",
+		Summary:     "Change some labels, a null label is removed, or the member options of an instance",
+		Tags:        []string{"instances"},
+		Parameters:  zoneName,
+		RequestBody: jsonBody(gen.Of(mongo.InstancePatch{})),
+		Responses:   map[string]*Response{"200": instance},
+	})
+	b.add("/v1/instances/{zone}/{name}", "delete", &Operation{
+		OperationID: "deleteInstance",
+		Summary:     "Delete an instance",
+		Tags:        []string{"instances"},
+		Parameters:  zoneName,
+		Responses:   map[string]*Response{"202": b.accepted()},
+	})
+
+	b.add("/failover", "get", &Operation{
+		OperationID: "listFailovers",
+		Summary:     "List executed, suppressed and failed failovers",
+		Tags:        []string{"failover"},
+		Responses:   map[string]*Response{"200": {Description: "the failovers, oldest first", Content: jsonContent(gen.ArrayOf(mongo.FailoverRecord{}))}},
+	})
+	b.add("/failover", "post", &Operation{
+		OperationID: "failover",
+		Summary:     "Fail over from the primary at from, or the current primary if it is empty",
+		Tags:        []string{"failover"},
+		RequestBody: &RequestBody{Content: jsonContent(gen.Of(mongo.FailoverData{}))},
+		Responses:   map[string]*Response{"200": {Description: "the executed failover", Content: jsonContent(gen.Of(mongo.FailoverRecord{}))}},
+	})
+
+	opID := []Parameter{stringParam("id", "path", "")}
+	b.add("/v1/operations", "get", &Operation{
+		OperationID: "listOperations",
+		Summary:     "List running and recently finished operations",
+		Tags:        []string{"operations"},
+		Responses:   map[string]*Response{"200": {Description: "the operations, oldest first", Content: jsonContent(gen.ArrayOf(mongo.Operation{}))}},
+	})
+	b.add("/v1/operations/{id}", "get", &Operation{
+		OperationID: "getOperation",
+		Summary:     "Get an operation",
+		Tags:        []string{"operations"},
+		Parameters:  opID,
+		Responses:   map[string]*Response{"200": {Description: "the operation", Content: jsonContent(gen.Of(mongo.Operation{}))}},
+	})
+	b.add("/v1/operations/{id}/cancel", "post", &Operation{
+		OperationID: "cancelOperation",
+		Summary:     "Cancel a running operation",
+		Tags:        []string{"operations"},
+		Parameters:  opID,
+		Responses:   map[string]*Response{"202": b.accepted()},
+	})
+
+	b.add("/v1/audit", "get", &Operation{
+		OperationID: "queryAudit",
+		Summary:     "Query audited api calls and actions of the manager",
+		Tags:        []string{"audit"},
+		Parameters: []Parameter{
+			{Name: "since", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			stringParam("actor", "query", ""),
+			stringParam("source", "query", "api or manager"),
+			{Name: "limit", In: "query", Description: "keep only the newest entries", Schema: &Schema{Type: "integer"}},
+		},
+		Responses: map[string]*Response{"200": {Description: "the entries, oldest first", Content: jsonContent(gen.ArrayOf(audit.Entry{}))}},
+	})
+
+	b.add(Path, "get", &Operation{
+		OperationID: "openAPI",
+		Summary:     "This document",
+		Responses:   map[string]*Response{"200": {Description: "the OpenAPI document", Content: jsonContent(&Schema{Type: "object"})}},
+	})
+
+	b.doc.Components = Components{
+		Schemas: gen.Schemas,
+		SecuritySchemes: map[string]SecurityScheme{
+			"bearer": {Type: "http", Scheme: "bearer", Description: "a token from --auth-token-file, client certificates are accepted too"},
+		},
+	}
+	return b.doc
+}
+
+// Handler serves a Document as JSON
+type Handler struct {
+	body []byte
+}
+
+// NewHandler creates a Handler serving doc
+func NewHandler(doc *Document) *Handler {
+	body, jErr := json.MarshalIndent(doc, "", "  ")
+	if jErr != nil {
+		log.Println("openapi:294 could not encode the document:", jErr)
+	}
+	return &Handler{body: body}
+}
+
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		res.Header().Set("Allow", "GET, HEAD")
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(res).Encode(&mongo.ErrorRes{Error: "method " + req.Method + " is not allowed on " + req.URL.Path, Code: http.StatusMethodNotAllowed})
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Write(h.body)
+}
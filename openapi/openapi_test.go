@@ -0,0 +1,80 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// refs collects every $ref in a decoded document
+func refs(value interface{}, found map[string]bool) {
+	switch cast := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range cast {
+			if ref, isString := inner.(string); key == "$ref" && isString {
+				found[ref] = true
+			}
+			refs(inner, found)
+		}
+	case []interface{}:
+		for _, inner := range cast {
+			refs(inner, found)
+		}
+	}
+}
+
+func TestKubongoDocument(t *testing.T) {
+	server := httptest.NewServer(NewHandler(Kubongo()))
+	defer server.Close()
+	res, resErr := http.Get(server.URL + Path)
+	if resErr != nil {
+		t.Fatal(resErr)
+	}
+	doc := map[string]interface{}{}
+	decodeErr := json.NewDecoder(res.Body).Decode(&doc)
+	res.Body.Close()
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("expected an OpenAPI 3 document, got %v", doc["openapi"])
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, path := range []string{"/v1/instances", "/v1/instances/{zone}/{name}", "/v1/operations/{id}/cancel", "/failover", "/v1/audit"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("expected %s to be described", path)
+		}
+	}
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	found := map[string]bool{}
+	refs(doc, found)
+	for ref := range found {
+		if _, ok := schemas[strings.TrimPrefix(ref, refPrefix)]; !ok {
+			t.Errorf("%s does not resolve", ref)
+		}
+	}
+	template, _ := schemas["InstanceTemplate"].(map[string]interface{})
+	properties, _ := template["properties"].(map[string]interface{})
+	if _, ok := properties["kind"]; !ok {
+		t.Errorf("expected InstanceTemplate to be generated from its json tags, got %v", template)
+	}
+	if _, ok := schemas["hostProviderOperation"]; !ok {
+		t.Error("expected hostProvider.Operation to be told apart from mongoInstance.Operation")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3 schema object, with only what kubongo's types need
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// refPrefix is where Generator puts the schemas of named structs
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// Generator derives schemas from Go types the way encoding/json encodes them,
+// named structs are added to Schemas once and referred to from then on
+type Generator struct {
+	Schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+// NewGenerator creates a Generator with no schemas
+func NewGenerator() *Generator {
+	return &Generator{Schemas: make(map[string]*Schema), types: make(map[string]reflect.Type)}
+}
+
+// Of returns the schema of value's type
+func (g *Generator) Of(value interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(value))
+}
+
+// ArrayOf returns the schema of a JSON array of value's type
+func (g *Generator) ArrayOf(value interface{}) *Schema {
+	return &Schema{Type: "array", Items: g.Of(value)}
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case rawType:
+		return &Schema{Description: "any JSON value"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return &Schema{Type: "object", Description: "described by the platform the instance runs on"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + g.define(t)}
+	}
+	return &Schema{}
+}
+
+// define adds the schema of the named struct t if it is not there yet and returns its name,
+// types of the same name from different packages are told apart by their package
+func (g *Generator) define(t reflect.Type) string {
+	name := t.Name()
+	if known, taken := g.types[name]; taken && known != t {
+		name = path.Base(t.PkgPath()) + name
+	}
+	if _, defined := g.types[name]; defined {
+		return name
+	}
+	g.types[name] = t
+	// set a placeholder first so a struct that refers to itself ends
+	g.Schemas[name] = &Schema{}
+	*g.Schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the fields of t to schema, inlining embedded structs like encoding/json does
+func (g *Generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, opts = tag[:comma], tag[comma:]
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.addFields(schema, fieldType)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schemaOf(field.Type)
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			// these can be null
+		default:
+			if !strings.Contains(opts, "omitempty") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	kube "github.com/cpg1111/kubongo/kubeClient"
+	"github.com/cpg1111/kubongo/metadata"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+)
+
+// Finalizer is put on every MongoCluster so its members are removed before Kubernetes deletes it
+const Finalizer = "kubongo.io/members"
+
+// ClusterLabel is the registry label holding the name of the MongoCluster an instance was created for,
+// the Manager puts such instances in a replica set of their own
+const ClusterLabel = mongo.ClusterLabel
+
+// MongoCluster phases written into the status
+const (
+	PhasePending     = "Pending"
+	PhaseReady       = "Ready"
+	PhaseDegraded    = "Degraded"
+	PhaseUnavailable = "Unavailable"
+	PhaseDeleting    = "Deleting"
+)
+
+// retryInterval is how long to wait before listing again after the api server failed us
+const retryInterval = 5 * time.Second
+
+// Operator converges MongoCluster custom resources onto the instances of a Manager
+type Operator struct {
+	kubeCtl *kube.Controller
+	manager *mongo.Manager
+	// ResyncInterval is how often every MongoCluster is reconciled again, refreshing member health in its status
+	ResyncInterval time.Duration
+}
+
+// New creates a new Operator struct
+func New(ktl *kube.Controller, manager *mongo.Manager) *Operator {
+	return &Operator{
+		kubeCtl:        ktl,
+		manager:        manager,
+		ResyncInterval: 30 * time.Second,
+	}
+}
+
+// Run registers the MongoCluster CRD then lists and watches MongoClusters, reconciling each, until stop is closed
+func (o *Operator) Run(stop <-chan struct{}) error {
+	crdErr := o.kubeCtl.EnsureMongoClusterCRD()
+	if crdErr != nil {
+		return crdErr
+	}
+	for {
+		list, listErr := o.kubeCtl.ListMongoClusters()
+		if listErr != nil {
+			log.Println("operator:73 could not list MongoClusters:", listErr)
+			if o.wait(stop) {
+				return nil
+			}
+			continue
+		}
+		for i := range list.Items {
+			o.reconcile(&list.Items[i])
+		}
+		if o.watch(list.Metadata.ResourceVersion, stop) {
+			return nil
+		}
+	}
+}
+
+// wait sleeps for retryInterval, returning true if stop was closed meanwhile
+func (o *Operator) wait(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	case <-time.After(retryInterval):
+		return false
+	}
+}
+
+// watch reconciles every MongoCluster event until the watch fails or it is time to resync,
+// returning true if stop was closed
+func (o *Operator) watch(resourceVersion string, stop <-chan struct{}) bool {
+	watcher, watchErr := o.kubeCtl.WatchMongoClusters(resourceVersion)
+	if watchErr != nil {
+		log.Println("operator:103 could not watch MongoClusters:", watchErr)
+		return o.wait(stop)
+	}
+	defer watcher.Stop()
+	resync := time.NewTicker(o.ResyncInterval)
+	defer resync.Stop()
+	for {
+		select {
+		case <-stop:
+			return true
+		case <-resync.C:
+			return false
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				if watcher.Err() != nil {
+					log.Println("operator:118 MongoCluster watch ended:", watcher.Err())
+				}
+				return false
+			}
+			switch event.Type {
+			case kube.Added, kube.Modified:
+				cluster := &kube.MongoCluster{}
+				decodeErr := json.Unmarshal(event.Object, cluster)
+				if decodeErr != nil {
+					log.Println("operator:127 could not decode MongoCluster:", decodeErr)
+					continue
+				}
+				o.reconcile(cluster)
+			case kube.Error:
+				// most likely our resourceVersion is too old, relist
+				log.Println("operator:133 MongoCluster watch error:", string(event.Object))
+				return false
+			}
+		}
+	}
+}
+
+func memberName(cluster *kube.MongoCluster, index int) string {
+	return fmt.Sprintf("%s-%d", cluster.Metadata.Name, index)
+}
+
+func hasFinalizer(cluster *kube.MongoCluster) bool {
+	for _, f := range cluster.Metadata.Finalizers {
+		if f == Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutFinalizer(cluster *kube.MongoCluster) []string {
+	finalizers := []string{}
+	for _, f := range cluster.Metadata.Finalizers {
+		if f != Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	return finalizers
+}
+
+// scale creates the missing members of the first count and removes all others, returning the members that exist afterwards.
+// Members are the instances registered with the cluster's ClusterLabel, so none are lost when writing the status fails.
+func (o *Operator) scale(cluster *kube.MongoCluster, count int) ([]kube.MongoClusterMember, error) {
+	selector := map[string]string{ClusterLabel: cluster.Metadata.Name}
+	desired := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		desired[memberName(cluster, i)] = true
+	}
+	existing := make(map[string]bool)
+	var scaleErr error
+	for _, instance := range o.manager.Registry.ByLabels(selector) {
+		name := instance.GetName()
+		existing[name] = true
+		if desired[name] {
+			continue
+		}
+		log.Println("operator:179 removing", name, "of MongoCluster", cluster.Metadata.Name)
+		removeErr := o.manager.Remove(instance.GetZone(), name)
+		if removeErr != nil {
+			// it stays registered so the next reconcile tries again
+			scaleErr = removeErr
+		}
+	}
+	for i := 0; i < count; i++ {
+		name := memberName(cluster, i)
+		if existing[name] {
+			continue
+		}
+		log.Println("operator:191 creating", name, "of MongoCluster", cluster.Metadata.Name)
+		_, createErr := o.manager.Create(&mongo.InstanceTemplate{
+			Kind:        "Create",
+			Name:        name,
+			Zone:        cluster.Spec.Zone,
+			MachineType: cluster.Spec.MachineType,
+			SourceImage: cluster.Spec.SourceImage,
+			Labels:      selector,
+		})
+		if metadata.IsConflict(createErr) {
+			// already registered under the member's name without the label, e.g. by hand, so adopt it
+			createErr = o.adopt(cluster, name, selector)
+		}
+		if createErr != nil {
+			scaleErr = createErr
+			break
+		}
+	}
+	members := []kube.MongoClusterMember{}
+	for _, instance := range o.manager.Registry.ByLabels(selector) {
+		members = append(members, kube.MongoClusterMember{Name: instance.GetName(), Zone: instance.GetZone()})
+	}
+	return members, scaleErr
+}
+
+// adopt adds the labels of selector to the instance registered as name,
+// unless it belongs to another MongoCluster or is not in the cluster's zone
+func (o *Operator) adopt(cluster *kube.MongoCluster, name string, selector map[string]string) error {
+	instance, registered := o.manager.Registry.Get(name)
+	labels, _ := o.manager.Registry.Labels(name)
+	if !registered {
+		return &metadata.NotFoundError{Name: name}
+	}
+	if owner := labels[ClusterLabel]; owner != "" && owner != cluster.Metadata.Name {
+		return fmt.Errorf("%s is already a member of MongoCluster %s, not adopting it", name, owner)
+	}
+	if instance.GetZone() != cluster.Spec.Zone {
+		return fmt.Errorf("%s is registered in zone %s instead of %s, not adopting it", name, instance.GetZone(), cluster.Spec.Zone)
+	}
+	adopted := make(map[string]string)
+	for k, v := range labels {
+		adopted[k] = v
+	}
+	for k, v := range selector {
+		adopted[k] = v
+	}
+	return o.manager.Registry.SetLabels(name, adopted)
+}
+
+// observe fills in each member's IP and health and returns the instances to publish
+func (o *Operator) observe(members []kube.MongoClusterMember) (metadata.Instances, int) {
+	published := metadata.Instances{}
+	ready := 0
+	for i := range members {
+		instance, registered := o.manager.Registry.Get(members[i].Name)
+		if !registered {
+			members[i].Healthy = false
+			continue
+		}
+		members[i].IP = instance.GetInternalIP()
+		members[i].Healthy = members[i].IP != "" && o.manager.CheckInstanceHealth(instance).Healthy
+		if members[i].Healthy {
+			ready++
+		}
+		published = append(published, instance)
+	}
+	return published, ready
+}
+
+func (o *Operator) reconcile(cluster *kube.MongoCluster) {
+	name := cluster.Metadata.Name
+	deleting := cluster.Metadata.DeletionTimestamp != ""
+	if !deleting && !hasFinalizer(cluster) {
+		finErr := o.kubeCtl.SetMongoClusterFinalizers(name, append(cluster.Metadata.Finalizers, Finalizer))
+		if finErr != nil {
+			log.Println("operator:266 could not add finalizer to MongoCluster", name, finErr)
+			return
+		}
+	}
+	count := cluster.Spec.Members
+	if deleting {
+		count = 0
+	}
+	members, scaleErr := o.scale(cluster, count)
+	published, ready := o.observe(members)
+	status := kube.MongoClusterStatus{
+		ObservedGeneration: cluster.Metadata.Generation,
+		ReadyMembers:       ready,
+		Members:            members,
+	}
+	switch {
+	case deleting:
+		status.Phase = PhaseDeleting
+	case len(members) < count:
+		status.Phase = PhasePending
+	case ready == len(members):
+		status.Phase = PhaseReady
+	case ready == 0:
+		status.Phase = PhaseUnavailable
+	default:
+		status.Phase = PhaseDegraded
+	}
+	if scaleErr != nil {
+		status.Message = scaleErr.Error()
+	}
+	if deleting && len(members) == 0 {
+		finErr := o.kubeCtl.SetMongoClusterFinalizers(name, withoutFinalizer(cluster))
+		if finErr != nil {
+			log.Println("operator:299 could not remove finalizer from MongoCluster", name, finErr)
+		}
+		return
+	}
+	if !deleting && cluster.Spec.ServiceName != "" && len(published) > 0 {
+		pubErr := o.kubeCtl.WithServiceName(cluster.Spec.ServiceName).UpdateServiceEndPoint(published)
+		if pubErr != nil {
+			log.Println("operator:306 could not publish MongoCluster", name, pubErr)
+		}
+	}
+	// writing an unchanged status would trigger another watch event and so another reconcile
+	if reflect.DeepEqual(status, cluster.Status) {
+		return
+	}
+	statusErr := o.kubeCtl.UpdateMongoClusterStatus(name, status)
+	if statusErr != nil {
+		log.Println("operator:315 could not update status of MongoCluster", name, statusErr)
+	}
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2015 Christian Grabowski All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpg1111/kubongo/hostProvider"
+	kube "github.com/cpg1111/kubongo/kubeClient"
+	"github.com/cpg1111/kubongo/metadata"
+	mongo "github.com/cpg1111/kubongo/mongoInstance"
+	"github.com/cpg1111/kubongo/mongoWire"
+)
+
+// fakeHost starts a tiny mongod for every server it creates, they keep one config per replica set
+// and whichever member initiated its replica set is the primary
+type fakeHost struct {
+	mutex     sync.Mutex
+	servers   map[string]*mongoWire.FakeServer
+	configs   map[string]mongoWire.Doc
+	primaries map[string]string
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{
+		servers:   make(map[string]*mongoWire.FakeServer),
+		configs:   make(map[string]mongoWire.Doc),
+		primaries: make(map[string]string),
+	}
+}
+
+func (h *fakeHost) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, server := range h.servers {
+		server.Close()
+	}
+}
+
+func fakeOk(doc mongoWire.Doc) mongoWire.Doc {
+	return append(doc, mongoWire.Elem{Key: "ok", Value: float64(1)})
+}
+
+// setOf returns the replica set the server at addr belongs to, the caller holds the lock
+func (h *fakeHost) setOf(addr string) string {
+	for set, config := range h.configs {
+		for _, raw := range config.Array("members") {
+			if raw.(mongoWire.Doc).String("host") == addr {
+				return set
+			}
+		}
+	}
+	return ""
+}
+
+// configure stores config as its replica set's and gives every server in it its role, the caller holds the lock
+func (h *fakeHost) configure(config mongoWire.Doc) {
+	set := config.String("_id")
+	h.configs[set] = config
+	for _, server := range h.servers {
+		if h.setOf(server.Addr()) == set {
+			server.ReplicaSet = set
+			if h.primaries[set] == server.Addr() {
+				server.SetRole("primary")
+			} else {
+				server.SetRole("secondary")
+			}
+		}
+	}
+}
+
+func (h *fakeHost) handle(server *mongoWire.FakeServer) {
+	server.Handle("replSetInitiate", func(cmd mongoWire.Doc) mongoWire.Doc {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		config := append(cmd.Doc("replSetInitiate"), mongoWire.Elem{Key: "version", Value: 1})
+		h.primaries[config.String("_id")] = server.Addr()
+		h.configure(config)
+		return fakeOk(mongoWire.Doc{})
+	})
+	server.Handle("replSetGetConfig", func(cmd mongoWire.Doc) mongoWire.Doc {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		return fakeOk(mongoWire.Doc{{Key: "config", Value: h.configs[h.setOf(server.Addr())]}})
+	})
+	server.Handle("replSetReconfig", func(cmd mongoWire.Doc) mongoWire.Doc {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.configure(cmd.Doc("replSetReconfig"))
+		return fakeOk(mongoWire.Doc{})
+	})
+	server.Handle("replSetStepDown", func(cmd mongoWire.Doc) mongoWire.Doc {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		set := h.setOf(server.Addr())
+		for _, raw := range h.configs[set].Array("members") {
+			if host := raw.(mongoWire.Doc).String("host"); host != server.Addr() {
+				h.primaries[set] = host
+				break
+			}
+		}
+		h.configure(h.configs[set])
+		return fakeOk(mongoWire.Doc{})
+	})
+	server.Handle("replSetGetStatus", func(cmd mongoWire.Doc) mongoWire.Doc {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		set := h.setOf(server.Addr())
+		members := []interface{}{}
+		for _, raw := range h.configs[set].Array("members") {
+			host, state := raw.(mongoWire.Doc).String("host"), "SECONDARY"
+			if host == h.primaries[set] {
+				state = "PRIMARY"
+			}
+			members = append(members, mongoWire.Doc{{Key: "name", Value: host}, {Key: "stateStr", Value: state}})
+		}
+		return fakeOk(mongoWire.Doc{{Key: "members", Value: members}})
+	})
+}
+
+func (h *fakeHost) GetServers(namespace string) ([]hostProvider.Instance, error) {
+	return nil, nil
+}
+
+func (h *fakeHost) GetServer(project, zone, name string) (hostProvider.Instance, error) {
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: "127.0.0.1"}, nil
+}
+
+func (h *fakeHost) CreateServer(namespace, zone, name, machineType, sourceImage, source string) (hostProvider.Instance, error) {
+	server, serverErr := mongoWire.NewFakeServer()
+	if serverErr != nil {
+		return nil, serverErr
+	}
+	h.handle(server)
+	h.mutex.Lock()
+	h.servers[name] = server
+	h.mutex.Unlock()
+	ip, port, _ := net.SplitHostPort(server.Addr())
+	portNum, _ := strconv.Atoi(port)
+	return &hostProvider.LocalInstance{Name: name, Zone: zone, IP: ip, ProcessPort: portNum}, nil
+}
+
+func (h *fakeHost) DeleteServer(namespace, zone, name string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if server, ok := h.servers[name]; ok {
+		server.Close()
+		delete(h.servers, name)
+	}
+	return nil
+}
+
+// configHosts are the hosts in the config of replicaSet
+func (h *fakeHost) configHosts(replicaSet string) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hosts := []string{}
+	for _, raw := range h.configs[replicaSet].Array("members") {
+		hosts = append(hosts, raw.(mongoWire.Doc).String("host"))
+	}
+	return hosts
+}
+
+const clusterPath = "/apis/kubongo.io/v1alpha1/namespaces/default/mongoclusters/db"
+
+// fakeAPI stands in for the Kubernetes api server, keeping the last patch sent to every path
+type fakeAPI struct {
+	mutex      sync.Mutex
+	patches    map[string][]byte
+	failStatus bool
+}
+
+func (f *fakeAPI) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PATCH" {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if f.failStatus && strings.HasSuffix(req.URL.Path, "/status") {
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	f.patches[req.URL.Path] = body
+}
+
+// status returns the last status written for the MongoCluster db, or nil if none was
+func (f *fakeAPI) status(t *testing.T) *kube.MongoClusterStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	body, ok := f.patches[clusterPath+"/status"]
+	if !ok {
+		return nil
+	}
+	delete(f.patches, clusterPath+"/status")
+	patch := &struct {
+		Status kube.MongoClusterStatus `json:"status"`
+	}{}
+	if jErr := json.Unmarshal(body, patch); jErr != nil {
+		t.Fatal(jErr)
+	}
+	return &patch.Status
+}
+
+func newTestOperator(t *testing.T) (*Operator, *fakeAPI, *fakeHost, func()) {
+	api := &fakeAPI{patches: make(map[string][]byte)}
+	server := httptest.NewServer(api)
+	ktl := kube.New(strings.TrimPrefix(server.URL, "http://"), "default", "DB_CONNECT_STRING", "mongo")
+	// the Service's port is not where the members listen
+	ktl.ServicePort = 1
+	fake := newFakeHost()
+	var host hostProvider.HostProvider = fake
+	manager := mongo.NewManager("test", "local", &host, metadata.NewRegistry())
+	manager.BootstrapTimeout = 2 * time.Second
+	return New(ktl, manager), api, fake, func() {
+		server.Close()
+		fake.close()
+	}
+}
+
+func newCluster(members int) *kube.MongoCluster {
+	return &kube.MongoCluster{
+		Metadata: kube.ObjectMeta{Name: "db", Finalizers: []string{Finalizer}, Generation: 1},
+		Spec:     kube.MongoClusterSpec{Zone: "local", MachineType: "27017", Members: members},
+	}
+}
+
+// clusterMembers are the names of the instances registered for the MongoCluster db
+func clusterMembers(o *Operator) []string {
+	names := []string{}
+	for _, instance := range o.manager.Registry.ByLabels(map[string]string{ClusterLabel: "db"}) {
+		names = append(names, instance.GetName())
+	}
+	return names
+}
+
+func TestReconcileScales(t *testing.T) {
+	o, api, _, closer := newTestOperator(t)
+	defer closer()
+	cluster := newCluster(3)
+	o.reconcile(cluster)
+	if names := strings.Join(clusterMembers(o), ","); names != "db-0,db-1,db-2" {
+		t.Fatalf("expected db-0,db-1,db-2 to be created, got %s", names)
+	}
+	status := api.status(t)
+	if status == nil || len(status.Members) != 3 || status.Phase != PhaseReady || status.ReadyMembers != 3 || status.Message != "" {
+		t.Fatalf("unexpected status after scaling up %+v", status)
+	}
+
+	cluster.Status = *status
+	cluster.Spec.Members = 1
+	o.reconcile(cluster)
+	if names := strings.Join(clusterMembers(o), ","); names != "db-0" {
+		t.Errorf("expected only db-0 to be left, got %s", names)
+	}
+	if status = api.status(t); status == nil || len(status.Members) != 1 || status.Members[0].Name != "db-0" {
+		t.Errorf("unexpected status after scaling down %+v", status)
+	}
+}
+
+func TestReconcileSurvivesAFailedStatusWrite(t *testing.T) {
+	o, api, _, closer := newTestOperator(t)
+	defer closer()
+	api.failStatus = true
+	cluster := newCluster(2)
+	o.reconcile(cluster)
+	if api.status(t) != nil {
+		t.Fatal("expected the status write to fail")
+	}
+
+	// the status still has no members, they are found in the registry
+	api.failStatus = false
+	o.reconcile(cluster)
+	status := api.status(t)
+	if status == nil || len(status.Members) != 2 || status.Message != "" {
+		t.Errorf("expected 2 members and no error, got %+v", status)
+	}
+	if o.manager.Registry.Len() != 2 {
+		t.Errorf("expected 2 registered instances, got %d", o.manager.Registry.Len())
+	}
+}
+
+func TestReconcileAdoptsRegisteredInstances(t *testing.T) {
+	o, api, _, closer := newTestOperator(t)
+	defer closer()
+	o.manager.Registry.Add(&hostProvider.LocalInstance{Name: "db-0", Zone: "local", IP: "127.0.0.1"}, map[string]string{"tier": "db"})
+	o.reconcile(newCluster(1))
+	status := api.status(t)
+	if status == nil || len(status.Members) != 1 || status.Message != "" {
+		t.Errorf("expected db-0 to be adopted, got %+v", status)
+	}
+	if labels, _ := o.manager.Registry.Labels("db-0"); labels[ClusterLabel] != "db" || labels["tier"] != "db" {
+		t.Errorf("expected db-0 to keep its labels and gain the cluster's, got %v", labels)
+	}
+}
+
+func TestReconcileRefusesToAdoptForeignInstances(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance *hostProvider.LocalInstance
+		labels   map[string]string
+		message  string
+	}{
+		{"another cluster's", &hostProvider.LocalInstance{Name: "db-0", Zone: "local"}, map[string]string{ClusterLabel: "cache"}, "member of MongoCluster cache"},
+		{"another zone's", &hostProvider.LocalInstance{Name: "db-0", Zone: "us-east1-b"}, nil, "zone us-east1-b"},
+	}
+	for _, c := range cases {
+		o, api, _, closer := newTestOperator(t)
+		o.manager.Registry.Add(c.instance, c.labels)
+		o.reconcile(newCluster(1))
+		status := api.status(t)
+		if status == nil || len(status.Members) != 0 || !strings.Contains(status.Message, c.message) {
+			t.Errorf("%s: expected db-0 not to be adopted, got %+v", c.name, status)
+		}
+		if labels, _ := o.manager.Registry.Labels("db-0"); labels[ClusterLabel] != c.labels[ClusterLabel] {
+			t.Errorf("%s: expected the labels of db-0 to be left alone, got %v", c.name, labels)
+		}
+		closer()
+	}
+}
+
+func TestReconcileDeletion(t *testing.T) {
+	o, api, _, closer := newTestOperator(t)
+	defer closer()
+	cluster := newCluster(2)
+	o.reconcile(cluster)
+	o.manager.Registry.Add(&hostProvider.LocalInstance{Name: "other", Zone: "local"}, nil)
+
+	cluster.Metadata.DeletionTimestamp = "2016-01-01T00:00:00Z"
+	o.reconcile(cluster)
+	if names := clusterMembers(o); len(names) != 0 {
+		t.Errorf("expected every member to be removed, got %v", names)
+	}
+	if _, ok := o.manager.Registry.Get("other"); !ok {
+		t.Error("an instance of no MongoCluster was removed")
+	}
+	patch := &struct {
+		Metadata kube.ObjectMeta `json:"metadata"`
+	}{}
+	json.Unmarshal(api.patches[clusterPath], patch)
+	if _, patched := api.patches[clusterPath]; !patched || len(patch.Metadata.Finalizers) != 0 {
+		t.Errorf("expected the finalizer to be removed, got %s", api.patches[clusterPath])
+	}
+}
+
+func TestClustersHaveTheirOwnReplicaSets(t *testing.T) {
+	o, api, fake, closer := newTestOperator(t)
+	defer closer()
+	o.manager.ReplicaSet = "rs0"
+	o.reconcile(newCluster(2))
+	cache := newCluster(1)
+	cache.Metadata.Name = "cache"
+	o.reconcile(cache)
+	if status := api.status(t); status == nil || status.Message != "" {
+		t.Fatalf("expected db to be created without errors, got %+v", status)
+	}
+	if hosts := fake.configHosts("db"); len(hosts) != 2 {
+		t.Errorf("expected db's replica set to have 2 members, got %v", hosts)
+	}
+	if hosts := fake.configHosts("cache"); len(hosts) != 1 {
+		t.Errorf("expected cache's replica set to have 1 member, got %v", hosts)
+	}
+	if hosts := fake.configHosts("rs0"); len(hosts) != 0 {
+		t.Errorf("expected no member to join the Manager's replica set, got %v", hosts)
+	}
+	for _, name := range []string{"db-0", "db-1", "cache-0"} {
+		instance, _ := o.manager.Registry.Get(name)
+		member, isMember := instance.(*metadata.Member)
+		if !isMember || member.ReplicaSet != strings.Split(name, "-")[0] {
+			t.Errorf("expected %s to be a member of its cluster's replica set, got %+v", name, instance)
+		}
+	}
+}